@@ -0,0 +1,56 @@
+package main
+
+import (
+	"deconz-homekit/internal/kvStorage"
+	"errors"
+	"os"
+)
+
+// exportStorage writes the stored data to an encrypted archive file.
+// The file is only readable by the owner, as it contains the HomeKit pairings and the deCONZ API key.
+//
+// Parameters:
+//   - storage: The storage to export
+//   - file: The path of the archive
+//   - passphrase: The passphrase to encrypt the archive with
+//
+// Returns:
+//   - error: Any error encountered while exporting
+func exportStorage(storage kvStorage.Store, file string, passphrase string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err = kvStorage.Export(storage, f, passphrase); err != nil {
+		_ = f.Close()
+		_ = os.Remove(file)
+		return err
+	}
+	return f.Close()
+}
+
+// importStorage restores the data of an encrypted archive file.
+//
+// Parameters:
+//   - storage: The storage to restore the data to
+//   - file: The path of the archive
+//   - passphrase: The passphrase the archive was encrypted with
+//   - force: Whether existing data may be overwritten
+//
+// Returns:
+//   - int: The number of restored entries
+//   - error: Any error encountered while importing
+func importStorage(storage kvStorage.Store, file string, passphrase string, force bool) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count, err := kvStorage.Import(storage, f, passphrase, force)
+	if errors.Is(err, kvStorage.ErrStoreNotEmpty) {
+		return 0, errors.New("the storage already contains data, use -force to overwrite it")
+	}
+	return count, err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envFlag is a command-line flag which sets an environment variable.
+// The configuration is read from the environment, so a flag overrides the
+// corresponding environment variable, while the variable remains the fallback
+// for deployments which only use the environment (e.g. Docker).
+type envFlag string
+
+// String returns the current value of the environment variable.
+// This method implements the flag.Value interface.
+//
+// Returns:
+//   - string: The value of the environment variable
+func (f envFlag) String() string {
+	return os.Getenv(string(f))
+}
+
+// Set stores the value of the flag in the environment variable.
+// This method implements the flag.Value interface.
+//
+// Parameters:
+//   - value: The value passed on the command line
+//
+// Returns:
+//   - error: Any error encountered while setting the environment variable
+func (f envFlag) Set(value string) error {
+	return os.Setenv(string(f), value)
+}
+
+// boolEnvFlag is an envFlag which can be passed without a value (e.g. -dry-run).
+type boolEnvFlag string
+
+// String returns the current value of the environment variable.
+// This method implements the flag.Value interface.
+//
+// Returns:
+//   - string: The value of the environment variable
+func (f boolEnvFlag) String() string {
+	return envFlag(f).String()
+}
+
+// Set stores the value of the flag in the environment variable as "true" or "false".
+// This method implements the flag.Value interface.
+//
+// Parameters:
+//   - value: The value passed on the command line ("true" if the flag has no value)
+//
+// Returns:
+//   - error: An error if the value is not a boolean
+func (f boolEnvFlag) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	return os.Setenv(string(f), strconv.FormatBool(b))
+}
+
+// IsBoolFlag allows the flag to be passed without a value.
+//
+// Returns:
+//   - bool: Always true
+func (f boolEnvFlag) IsBoolFlag() bool {
+	return true
+}
+
+// parseFlags parses the command-line flags and stores their values in the
+// corresponding environment variables. The usage is printed for -h.
+//
+// Parameters:
+//   - args: The command-line arguments without the program name
+func parseFlags(args []string) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		_, _ = fmt.Fprintf(out, "Usage: %s [flags]\n\n", flags.Name())
+		_, _ = fmt.Fprintln(out, "Each flag overrides the environment variable in brackets, which is used if the flag is not set.")
+		_, _ = fmt.Fprintln(out)
+		flags.PrintDefaults()
+	}
+
+	// Connection to the deCONZ gateway
+	flags.Var(envFlag("DECONZ_IP"), "deconz-ip", "`IP` address of the deCONZ gateway, searched via mDNS if not set (DECONZ_IP)")
+	flags.Var(envFlag("DECONZ_PORT"), "deconz-port", "`Port` of the deCONZ gateway (DECONZ_PORT, default 80)")
+	flags.Var(envFlag("DECONZ_WS_PORT"), "deconz-ws-port", "WebSocket `port` of the deCONZ gateway (DECONZ_WS_PORT, default reported by the gateway)")
+	flags.Var(boolEnvFlag("DECONZ_WS_TLS"), "deconz-ws-tls", "Connect to the WebSocket of the deCONZ gateway via wss:// (DECONZ_WS_TLS)")
+	flags.Var(envFlag("DECONZ_WS_CA"), "deconz-ws-ca", "PEM `file` with the CA certificates the WebSocket certificate is verified with, enables wss:// (DECONZ_WS_CA)")
+	flags.Var(boolEnvFlag("DECONZ_WS_INSECURE"), "deconz-ws-insecure", "Accept any WebSocket certificate without verification, enables wss:// (DECONZ_WS_INSECURE)")
+	flags.Var(envFlag("DECONZ_API_KEY"), "api-key", "API `key` for the deCONZ gateway (DECONZ_API_KEY)")
+	flags.Var(envFlag("DECONZ_API_KEY_TIMEOUT"), "api-key-timeout", "Maximum `duration` to wait for the link button (DECONZ_API_KEY_TIMEOUT, default 5m)")
+	flags.Var(envFlag("DECONZ_STARTUP_TIMEOUT"), "startup-timeout", "Maximum `duration` to wait for the gateway on startup (DECONZ_STARTUP_TIMEOUT, default 2m)")
+	flags.Var(envFlag("DECONZ_RATE_LIMIT"), "rate-limit", "Maximum `number` of commands per second, 0 disables the limit (DECONZ_RATE_LIMIT, default 10)")
+	flags.Var(envFlag("DECONZ_MAX_REQUESTS"), "max-requests", "Maximum `number` of concurrent requests to the gateway (DECONZ_MAX_REQUESTS, default 8)")
+	flags.Var(envFlag("POLL_INTERVAL"), "poll-interval", "`Interval` in which all states are polled, 0 disables polling (POLL_INTERVAL)")
+	flags.Var(envFlag("DISCOVERY_INTERVAL"), "discovery-interval", "`Interval` in which new devices are searched, 0 disables the search (DISCOVERY_INTERVAL)")
+	flags.Var(envFlag("EVENT_BUFFER_SIZE"), "event-buffer", "Maximum `number` of events buffered during bursts, the oldest are dropped (EVENT_BUFFER_SIZE, default 256)")
+	flags.Var(envFlag("EVENT_WATCHDOG_TIMEOUT"), "watchdog-timeout", "Maximum `duration` without data from the event stream before reconnecting, 0 disables the watchdog (EVENT_WATCHDOG_TIMEOUT, default 2m)")
+	flags.Var(envFlag("EVENT_WATCHDOG_RETRIES"), "watchdog-retries", "Maximum `number` of reconnects of a stale event stream before exiting (EVENT_WATCHDOG_RETRIES, default 3)")
+
+	// Storage and HomeKit server
+	flags.Var(envFlag("STORAGE_BACKEND"), "storage", "Storage `backend`, sqlite or memory (STORAGE_BACKEND, default sqlite)")
+	flags.Var(envFlag("STORAGE_PATH"), "storage-path", "`Directory` of the sqlite database (STORAGE_PATH, default ./)")
+	flags.Var(envFlag("STORAGE_ENCRYPTION_KEY"), "encryption-key", "`Secret` the API key and the HomeKit keys are encrypted with in the storage (STORAGE_ENCRYPTION_KEY)")
+	flags.Var(boolEnvFlag("HOMEKIT_RESET"), "reset-homekit", "Remove the HomeKit pairings and identity, but keep the API key, and exit (HOMEKIT_RESET)")
+	flags.Var(envFlag("STORAGE_EXPORT"), "export", "Write the pairings and the API key to an encrypted archive `file` and exit (STORAGE_EXPORT)")
+	flags.Var(envFlag("STORAGE_IMPORT"), "import", "Restore the pairings and the API key from an archive `file` and exit (STORAGE_IMPORT)")
+	flags.Var(boolEnvFlag("STORAGE_FORCE"), "force", "Overwrite existing data when importing an archive and reset without confirmation (STORAGE_FORCE)")
+	flags.Var(envFlag("STORAGE_PASSPHRASE"), "passphrase", "`Passphrase` of the archive (STORAGE_PASSPHRASE)")
+	flags.Var(envFlag("BRIDGE_NAME"), "bridge-name", "`Name` of the bridge shown while pairing (BRIDGE_NAME, default name of the gateway)")
+	flags.Var(envFlag("BRIDGE_MANUFACTURER"), "bridge-manufacturer", "`Manufacturer` of the bridge (BRIDGE_MANUFACTURER, default 0x2321)")
+	flags.Var(envFlag("BRIDGE_MODEL"), "bridge-model", "`Model` of the bridge (BRIDGE_MODEL, default model of the gateway)")
+	flags.Var(envFlag("BRIDGE_SERIAL"), "bridge-serial", "Serial `number` of the bridge (BRIDGE_SERIAL, default ID of the gateway)")
+	flags.Var(envFlag("HOMEKIT_PIN"), "pin", "8-digit HomeKit pairing `code`, random if not set (HOMEKIT_PIN)")
+	flags.Var(boolEnvFlag("PAIRING_QR_PNG"), "pairing-qr-png", "Write the pairing QR code to pairing.png in the storage directory (PAIRING_QR_PNG)")
+	flags.Var(envFlag("LOG_LEVEL"), "log-level", "Log `level`, debug, info, warn or error (LOG_LEVEL, default info)")
+	flags.Var(envFlag("STATUS_PORT"), "status-port", "`Port` of the status server (STATUS_PORT)")
+	flags.Var(boolEnvFlag("METRICS"), "metrics", "Expose Prometheus metrics via the status server (METRICS)")
+	flags.Var(boolEnvFlag("DRY_RUN"), "dry-run", "Print the accessories which would be exposed and exit (DRY_RUN)")
+
+	// MQTT integration
+	flags.Var(envFlag("MQTT_BROKER"), "mqtt-broker", "`URL` of an MQTT broker the state changes are published to, e.g. tcp://192.168.1.2:1883 (MQTT_BROKER)")
+	flags.Var(envFlag("MQTT_USERNAME"), "mqtt-username", "`Username` for the MQTT broker (MQTT_USERNAME)")
+	flags.Var(envFlag("MQTT_PASSWORD"), "mqtt-password", "`Password` for the MQTT broker (MQTT_PASSWORD)")
+	flags.Var(envFlag("MQTT_TOPIC_PREFIX"), "mqtt-topic-prefix", "`Prefix` of the MQTT topics (MQTT_TOPIC_PREFIX, default deconz-homekit)")
+	flags.Var(envFlag("MQTT_QOS"), "mqtt-qos", "MQTT quality of service `level`, 0, 1 or 2 (MQTT_QOS, default 0)")
+
+	// Accessories
+	flags.Var(envFlag("DECONZ_INCLUDE"), "include", "Comma-separated `list` of the devices to expose (DECONZ_INCLUDE)")
+	flags.Var(envFlag("DECONZ_EXCLUDE"), "exclude", "Comma-separated `list` of the devices not to expose (DECONZ_EXCLUDE)")
+	flags.Var(envFlag("DEVICE_OVERRIDES"), "overrides", "`Path` to a JSON file with device overrides (DEVICE_OVERRIDES)")
+	flags.Var(boolEnvFlag("EXPOSE_GROUPS"), "expose-groups", "Expose deCONZ light groups (EXPOSE_GROUPS)")
+	flags.Var(boolEnvFlag("EVE_CHARACTERISTICS"), "eve-characteristics", "Expose additional values via Eve characteristics (EVE_CHARACTERISTICS)")
+	flags.Var(boolEnvFlag("COLORLOOP_SWITCH"), "colorloop-switch", "Add a color loop switch to color lights (COLORLOOP_SWITCH)")
+	flags.Var(envFlag("LOW_BATTERY_THRESHOLD"), "low-battery-threshold", "Battery level in `percent` reported as low (LOW_BATTERY_THRESHOLD, default 20)")
+	flags.Var(envFlag("PRESENCE_COOLDOWN"), "presence-cooldown", "`Time` a cleared motion is held back (PRESENCE_COOLDOWN)")
+	flags.Var(envFlag("VIBRATION_TIMEOUT"), "vibration-timeout", "`Time` after which a detected vibration is cleared (VIBRATION_TIMEOUT, default 10s)")
+	flags.Var(envFlag("TEMPERATURE_UNIT"), "temperature-unit", "`Unit` used to display temperatures, C or F (TEMPERATURE_UNIT, default C)")
+	flags.Var(envFlag("FEEDBACK_WINDOW"), "feedback-window", "`Time` state updates are ignored after a change from HomeKit (FEEDBACK_WINDOW, default 1s)")
+	flags.Var(envFlag("SENSOR_STALE_THRESHOLD"), "stale-threshold", "`Time` after which sensors which aren't seen are reported as faulted (SENSOR_STALE_THRESHOLD)")
+
+	// Errors exit the application, as the flag set uses flag.ExitOnError
+	_ = flags.Parse(args)
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"deconz-homekit/internal/client"
 	deviceConfiguration "deconz-homekit/internal/device_configuration"
 	"fmt"
@@ -28,8 +29,15 @@ type MapFile struct {
 	Maps    map[string]ButtonMap `json:"maps"`
 }
 
+// main generates the device configurations in the devices directory from the button maps
+// of the deCONZ REST plugin. The button maps only cover the buttons of remotes (ZHASwitch).
+// Rotary controls reported as ZHARelativeRotary (e.g. the rotary ring of the Hue tap dial
+// switch) need no configuration, their rotations are exposed by RotaryDevice as two
+// additional switches, which are labeled after the buttons generated here.
+// Remotes which report rotations as button events (e.g. the first IKEA Symfonisk sound
+// controller) get their rotations from the button map, like any other button.
 func main() {
-	data, err := client.Get[MapFile]("https://raw.githubusercontent.com/dresden-elektronik/deconz-rest-plugin/master/button_maps.json")
+	data, err := client.Get[MapFile](context.Background(), "https://raw.githubusercontent.com/dresden-elektronik/deconz-rest-plugin/master/button_maps.json")
 	if err != nil {
 		log.Fatalf("error getting file: %+v", err)
 	}
@@ -76,8 +84,12 @@ func main() {
 					buttonsMap[buttonId].EventMap[fmt.Sprintf("%d", eventId)] = deviceConfiguration.ButtonSinglePress
 				case "S_BUTTON_ACTION_DOUBLE_PRESS":
 					buttonsMap[buttonId].EventMap[fmt.Sprintf("%d", eventId)] = deviceConfiguration.ButtonDoublePress
+				case "S_BUTTON_ACTION_TREBLE_PRESS":
+					buttonsMap[buttonId].EventMap[fmt.Sprintf("%d", eventId)] = deviceConfiguration.ButtonTriplePress
 				case "S_BUTTON_ACTION_LONG_RELEASED":
 					buttonsMap[buttonId].EventMap[fmt.Sprintf("%d", eventId)] = deviceConfiguration.ButtonLongPress
+				case "S_BUTTON_ACTION_HOLD":
+					buttonsMap[buttonId].EventMap[fmt.Sprintf("%d", eventId)] = deviceConfiguration.ButtonHold
 				}
 			}
 		}
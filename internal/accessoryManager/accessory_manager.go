@@ -4,10 +4,18 @@
 package accessoryManager
 
 import (
+	"cmp"
 	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"deconz-homekit/internal/helper"
+	"deconz-homekit/internal/metrics"
 	"github.com/brutella/hap/accessory"
+	"github.com/charmbracelet/log"
 	"maps"
+	"path"
 	"slices"
+	"sync"
+	"time"
 )
 
 // AccessoryManager manages all HomeKit accessories and their services.
@@ -20,27 +28,175 @@ type AccessoryManager struct {
 	// Services is a map of deCONZ device unique IDs to DeviceService interfaces
 	// This provides quick access to services for processing updates
 	Services map[string]DeviceService
+
+	// Groups is a map of deCONZ group IDs to Group objects
+	// Groups are only populated if group exposure is enabled
+	Groups map[string]*Group
+
+	// client is the deCONZ API client for communicating with the gateway
+	client *deconz.ApiClient
+
+	// options contains the settings for exposing devices and groups to HomeKit
+	options Options
+
+	// observers is a map of deCONZ device unique IDs to the services of other devices
+	// which observe their state (e.g. a garage door observing a contact sensor)
+	observers map[string][]DeviceService
+
+	// subscribers are called with every update message received from the gateway
+	subscribers []func(msg *deconz.Messsage)
+
+	// subscribersMu guards the subscribers against concurrent subscriptions
+	subscribersMu sync.RWMutex
+
+	// updateMu serializes the updates of the services, which are processed by the
+	// WebSocket worker, the polling goroutine and the refreshes on reads of the devices
+	updateMu sync.Mutex
+
+	// unsupported is a list of the subdevices which could not be exposed to HomeKit
+	unsupported []UnsupportedDevice
+
+	// skipped is a list of the devices which are not exposed to HomeKit
+	skipped []SkippedDevice
 }
 
+// UnsupportedDevice describes a deCONZ subdevice whose type is not supported
+// and which is therefore not exposed to HomeKit.
+type UnsupportedDevice struct {
+	// UniqueId is the unique identifier of the subdevice
+	UniqueId string `json:"uniqueid"`
+
+	// Name is the user-assigned name of the device
+	Name string `json:"name"`
+
+	// Model is the model identifier of the device
+	Model string `json:"modelid"`
+
+	// Type is the deCONZ type of the subdevice
+	Type deconz.DeviceType `json:"type"`
+
+	// StateFields are the names of the state values reported by the subdevice,
+	// which help to add support for the type
+	StateFields []string `json:"stateFields"`
+}
+
+// SkippedDevice describes a deCONZ device which is not exposed to HomeKit.
+type SkippedDevice struct {
+	// UniqueId is the unique identifier of the device
+	UniqueId string `json:"uniqueid"`
+
+	// Name is the user-assigned name of the device
+	Name string `json:"name"`
+
+	// Model is the model identifier of the device
+	Model string `json:"modelid"`
+
+	// Reason describes why the device is not exposed
+	Reason string `json:"reason"`
+}
+
+// Options contains the settings which control how deCONZ devices are exposed to HomeKit.
+type Options struct {
+	// EveCharacteristics enables custom Eve characteristics (e.g. for power metering and air pressure)
+	// These characteristics are not supported by the Apple Home app
+	EveCharacteristics bool
+
+	// LowBatteryThreshold is the battery level (in percent) below which a battery is reported
+	// as low, if the sensor doesn't report a low battery state itself
+	LowBatteryThreshold int
+
+	// PresenceCooldown is the time a cleared motion is held back before it is reported,
+	// so that a re-detection within this time doesn't trigger HomeKit automations again
+	PresenceCooldown time.Duration
+
+	// VibrationTimeout is the time after which a detected vibration is cleared (0 to wait for deCONZ)
+	// Vibration sensors only report the vibration itself, not when it has ended
+	VibrationTimeout time.Duration
+
+	// TemperatureDisplayUnits is the unit controllers should use to display temperatures
+	// (characteristic.TemperatureDisplayUnitsCelsius or characteristic.TemperatureDisplayUnitsFahrenheit)
+	// HomeKit always transfers temperatures in Celsius, so this only affects the display
+	TemperatureDisplayUnits int
+
+	// Include is a list of unique IDs or model IDs (glob patterns) of the devices to expose
+	// If empty, all devices are exposed
+	Include []string
+
+	// Exclude is a list of unique IDs or model IDs (glob patterns) of the devices not to expose
+	// Exclusion takes precedence over inclusion
+	Exclude []string
+
+	// Overrides is a map of unique IDs to user-defined names and types of devices
+	// The name is looked up by the unique ID of the device, the type by the unique ID
+	// of the subdevice and then by the unique ID of the device
+	Overrides map[string]deviceConfiguration.Override
+
+	// ColorLoopSwitch adds a switch to color lights which starts and stops the color loop effect
+	// The Apple Home app has no native control for light effects
+	ColorLoopSwitch bool
+
+	// FeedbackWindow is the time state updates from deCONZ are ignored after a change from HomeKit,
+	// so the echoed (possibly outdated) state doesn't revert the value shown in HomeKit
+	// It can be overridden per device in the overrides (0 disables the suppression)
+	FeedbackWindow time.Duration
+
+	// StaleThreshold is the time after which the sensors of a device which hasn't been seen
+	// by the gateway are reported as faulted (0 disables the check)
+	StaleThreshold time.Duration
+}
+
+// lightCapabilityAttrs are the light attributes which indicate that the capabilities
+// of a light (e.g. after a firmware update) may have changed.
+var lightCapabilityAttrs = []string{"colorcapabilities", "ctmax", "ctmin", "modelid", "swversion", "type"}
+
 // NewAccessoryManager creates a new AccessoryManager and initializes it with devices
 // from the deCONZ gateway.
 //
 // Parameters:
 //   - client: A pointer to the deCONZ API client for communication with the gateway
 //   - devices: A slice of deCONZ devices to be converted to HomeKit accessories
+//   - options: The settings for exposing the devices to HomeKit
 //
 // Returns:
 //   - *AccessoryManager: A pointer to the initialized AccessoryManager
-func NewAccessoryManager(client *deconz.ApiClient, devices []*deconz.Device) *AccessoryManager {
+func NewAccessoryManager(client *deconz.ApiClient, devices []*deconz.Device, options Options) *AccessoryManager {
 	am := new(AccessoryManager)
+	am.client = client
+	am.options = options
 	am.Devices = make(map[string]*Device)
 	am.Services = make(map[string]DeviceService)
+	am.Groups = make(map[string]*Group)
+	am.observers = make(map[string][]DeviceService)
 
 	// Create HomeKit devices for each deCONZ device
 	for _, config := range devices {
-		device, err := NewDevice(client, config)
+		// Skip devices which are filtered by the configuration
+		if !options.isDeviceIncluded(config) {
+			am.skip(config, "filtered by the include/exclude lists")
+			continue
+		}
+
+		// Remember the subdevices which can't be exposed, so they can be reported at once
+		for _, sub := range config.Subdevices {
+			if t := options.overrideType(config.UniqueId, sub.UniqueId); len(t) > 0 {
+				sub.Type = t
+			}
+			if !isSupportedType(sub.Type) {
+				am.unsupported = append(am.unsupported, UnsupportedDevice{
+					UniqueId: sub.UniqueId,
+					Name:     config.Name,
+					Model:    config.Model,
+					Type:     sub.Type,
+					// Sorted, so that the fields are listed in the same order on every start
+					StateFields: slices.Sorted(maps.Keys(sub.State)),
+				})
+			}
+		}
+
+		device, err := NewDevice(client, config, options)
 		if err != nil {
 			// Skip devices that cannot be converted to HomeKit accessories
+			am.skip(config, err.Error())
 			continue
 		}
 		am.Devices[config.UniqueId] = device
@@ -48,14 +204,206 @@ func NewAccessoryManager(client *deconz.ApiClient, devices []*deconz.Device) *Ac
 
 	// Collect all services from all devices for quick lookup during updates
 	for _, device := range am.Devices {
+		device.updateMu = &am.updateMu
 		maps.Copy(am.Services, device.Services)
+		for id, observer := range device.observers {
+			am.observers[id] = append(am.observers[id], observer)
+		}
 	}
 
 	return am
 }
 
+// isDeviceIncluded reports whether a device should be exposed to HomeKit,
+// based on the include and exclude lists. Exclusion takes precedence over inclusion.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ device configuration
+//
+// Returns:
+//   - bool: True if the device should be exposed
+func (options Options) isDeviceIncluded(config *deconz.Device) bool {
+	if matchesDevice(options.Exclude, config) {
+		return false
+	}
+	return len(options.Include) == 0 || matchesDevice(options.Include, config)
+}
+
+// overrideType returns the user-defined type of a subdevice.
+// An override of the subdevice takes precedence over an override of the device.
+//
+// Parameters:
+//   - deviceId: The unique ID of the device
+//   - subdeviceId: The unique ID of the subdevice
+//
+// Returns:
+//   - deconz.DeviceType: The user-defined type, or an empty string if none is configured
+func (options Options) overrideType(deviceId string, subdeviceId string) deconz.DeviceType {
+	if override := options.Overrides[subdeviceId]; len(override.Type) > 0 {
+		return deconz.DeviceType(override.Type)
+	}
+	return deconz.DeviceType(options.Overrides[deviceId].Type)
+}
+
+// overrideService returns the override selecting the service of a subdevice.
+// An override of the subdevice takes precedence over an override of the device.
+//
+// Parameters:
+//   - deviceId: The unique ID of the device
+//   - subdeviceId: The unique ID of the subdevice
+//
+// Returns:
+//   - deviceConfiguration.Override: The override, whose service is empty if none is configured
+func (options Options) overrideService(deviceId string, subdeviceId string) deviceConfiguration.Override {
+	if override := options.Overrides[subdeviceId]; len(override.Service) > 0 {
+		return override
+	}
+	return options.Overrides[deviceId]
+}
+
+// feedbackWindow returns the feedback window of a subdevice.
+// An override of the subdevice takes precedence over an override of the device
+// and the global setting.
+//
+// Parameters:
+//   - deviceId: The unique ID of the device
+//   - subdeviceId: The unique ID of the subdevice
+//
+// Returns:
+//   - time.Duration: The feedback window
+func (options Options) feedbackWindow(deviceId string, subdeviceId string) time.Duration {
+	for _, id := range []string{subdeviceId, deviceId} {
+		if window := options.Overrides[id].FeedbackWindow; window != nil {
+			return time.Duration(*window)
+		}
+	}
+	return options.FeedbackWindow
+}
+
+// minBrightness returns the raw deCONZ brightness HomeKit's 1% is mapped to for a subdevice.
+// An override of the subdevice takes precedence over an override of the device.
+//
+// Parameters:
+//   - deviceId: The unique ID of the device
+//   - subdeviceId: The unique ID of the subdevice
+//
+// Returns:
+//   - int: The minimum brightness (helper.DefaultMinBrightness if not overridden)
+func (options Options) minBrightness(deviceId string, subdeviceId string) int {
+	for _, id := range []string{subdeviceId, deviceId} {
+		if minimum := options.Overrides[id].MinBrightness; minimum > 0 {
+			return minimum
+		}
+	}
+	return helper.DefaultMinBrightness
+}
+
+// powerUp returns the powerup setting to apply to a light.
+// An override of the subdevice takes precedence over an override of the device.
+//
+// Parameters:
+//   - deviceId: The unique ID of the device
+//   - subdeviceId: The unique ID of the subdevice
+//
+// Returns:
+//   - int: The powerup bitmap
+//   - bool: False if no powerup setting is configured
+func (options Options) powerUp(deviceId string, subdeviceId string) (int, bool) {
+	for _, id := range []string{subdeviceId, deviceId} {
+		if powerUp := options.Overrides[id].PowerUp; powerUp != nil {
+			return *powerUp, true
+		}
+	}
+	return 0, false
+}
+
+// matchesDevice reports whether any of the patterns matches the unique ID or model ID of a device.
+// The patterns support the glob syntax of path.Match (e.g. "TRADFRI*").
+//
+// Parameters:
+//   - patterns: A list of glob patterns
+//   - config: A pointer to the deCONZ device configuration
+//
+// Returns:
+//   - bool: True if any pattern matches
+func matchesDevice(patterns []string, config *deconz.Device) bool {
+	for _, pattern := range patterns {
+		for _, value := range []string{config.UniqueId, config.Model} {
+			if ok, err := path.Match(pattern, value); err == nil && ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skip records a device which is not exposed to HomeKit.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ device configuration
+//   - reason: A description why the device is not exposed
+func (am *AccessoryManager) skip(config *deconz.Device, reason string) {
+	am.skipped = append(am.skipped, SkippedDevice{
+		UniqueId: config.UniqueId,
+		Name:     config.Name,
+		Model:    config.Model,
+		Reason:   reason,
+	})
+}
+
+// SkippedDevices returns all devices which are not exposed to HomeKit,
+// e.g. because they are filtered or have no supported services.
+//
+// Returns:
+//   - []SkippedDevice: A slice of the skipped devices
+func (am *AccessoryManager) SkippedDevices() []SkippedDevice {
+	return slices.Clone(am.skipped)
+}
+
+// UnsupportedDevices returns all subdevices which are not exposed to HomeKit,
+// because their type is not supported.
+//
+// Returns:
+//   - []UnsupportedDevice: A slice of the unsupported subdevices
+func (am *AccessoryManager) UnsupportedDevices() []UnsupportedDevice {
+	return slices.Clone(am.unsupported)
+}
+
+// AddGroups creates HomeKit accessories for deCONZ light groups.
+// Exposing groups is opt-in, because the lights of a group are usually
+// exposed as individual accessories as well.
+//
+// Parameters:
+//   - client: A pointer to the deCONZ API client for communication with the gateway
+//   - groups: A map of deCONZ group IDs to groups to be converted to HomeKit accessories
+func (am *AccessoryManager) AddGroups(client *deconz.ApiClient, groups map[string]*deconz.Group) {
+	for id, config := range groups {
+		group, err := NewGroup(client, config, am.options)
+		if err != nil {
+			// Skip groups that cannot be converted to HomeKit accessories
+			continue
+		}
+		am.Groups[id] = group
+	}
+}
+
+// MaxAccessories is the maximum number of accessories which can be exposed by the bridge.
+// HomeKit allows at most 150 accessories per bridge, including the bridge itself.
+const MaxAccessories = 149
+
+// AccessoryCount returns the number of HomeKit accessories managed by this AccessoryManager,
+// regardless of the MaxAccessories limit.
+//
+// Returns:
+//   - int: The number of accessories
+func (am *AccessoryManager) AccessoryCount() int {
+	return len(am.Devices) + len(am.Groups)
+}
+
 // GetAccessories returns all HomeKit accessories managed by this AccessoryManager.
 // This is used when setting up the HomeKit server.
+// The accessories are sorted by their ID. If there are more than MaxAccessories,
+// the remaining accessories are dropped, so that the same accessories are exposed on every start.
 //
 // Returns:
 //   - []*accessory.A: A slice of pointers to HomeKit accessories
@@ -67,17 +415,98 @@ func (am *AccessoryManager) GetAccessories() []*accessory.A {
 		accessories = append(accessories, device.Accessory)
 	}
 
+	// Collect all accessories from all groups
+	for _, group := range am.Groups {
+		accessories = append(accessories, group.Accessory)
+	}
+
+	// Sort the accessories and respect the HomeKit limit
+	slices.SortFunc(accessories, func(a, b *accessory.A) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	if len(accessories) > MaxAccessories {
+		accessories = accessories[:MaxAccessories]
+	}
+
 	return accessories
 }
 
+// deviceForService finds the device which contains the service of a subdevice.
+//
+// Parameters:
+//   - id: The unique ID of the subdevice
+//
+// Returns:
+//   - *Device: A pointer to the device, or nil if no device contains the service
+func (am *AccessoryManager) deviceForService(id string) *Device {
+	for _, device := range am.Devices {
+		if _, ok := device.Services[id]; ok {
+			return device
+		}
+	}
+	return nil
+}
+
 // ProcessUpdate processes a real-time update message from the deCONZ gateway.
 // It updates the state of the corresponding HomeKit accessory service.
 //
 // Parameters:
 //   - msg: A pointer to the message containing the update information
 func (am *AccessoryManager) ProcessUpdate(msg *deconz.Messsage) {
-	// Only process updates for lights and sensors
-	if !slices.Contains([]deconz.RessourceType{deconz.LightsRessource, deconz.SensorsRessource}, msg.RessourceType) {
+	metrics.CountEvent(string(msg.EventType))
+	am.processUpdate(msg)
+
+	// Notify the subscribers after the HomeKit state has been updated
+	am.subscribersMu.RLock()
+	subscribers := am.subscribers
+	am.subscribersMu.RUnlock()
+	for _, subscriber := range subscribers {
+		notifySubscriber(subscriber, msg)
+	}
+}
+
+// Subscribe registers a callback which is called with every update message received
+// from the gateway, after the HomeKit state has been updated. This allows integrations
+// (e.g. MQTT or webhooks) to react to state changes without depending on HomeKit.
+// The callbacks are called sequentially and should not block.
+//
+// Parameters:
+//   - subscriber: The callback to call for every update message
+func (am *AccessoryManager) Subscribe(subscriber func(msg *deconz.Messsage)) {
+	am.subscribersMu.Lock()
+	defer am.subscribersMu.Unlock()
+
+	// Append to a copy, so that running notifications keep their snapshot
+	am.subscribers = append(slices.Clip(am.subscribers), subscriber)
+}
+
+// notifySubscriber calls a subscriber with an update message.
+// A panicking subscriber is logged instead of stopping the event processing.
+//
+// Parameters:
+//   - subscriber: The callback to call
+//   - msg: A pointer to the update message
+func notifySubscriber(subscriber func(msg *deconz.Messsage), msg *deconz.Messsage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("update subscriber panicked: %v", r)
+		}
+	}()
+	subscriber(msg)
+}
+
+// processUpdate updates the services affected by an update message.
+// This is used for the messages of the WebSocket as well as for polled states.
+// The updates are serialized, because the services aren't safe for concurrent updates.
+//
+// Parameters:
+//   - msg: A pointer to the message containing the update information
+func (am *AccessoryManager) processUpdate(msg *deconz.Messsage) {
+	am.updateMu.Lock()
+	defer am.updateMu.Unlock()
+
+	// Only process updates for lights, sensors and groups
+	if !slices.Contains([]deconz.RessourceType{deconz.LightsRessource, deconz.SensorsRessource, deconz.GroupsRessource}, msg.RessourceType) {
 		// Ignore messages for other resource types
 		return
 	}
@@ -89,14 +518,67 @@ func (am *AccessoryManager) ProcessUpdate(msg *deconz.Messsage) {
 		return
 	}
 
+	// Drop cached light details if the capabilities of a light may have changed
+	if msg.RessourceType == deconz.LightsRessource && msg.Attr != nil && msg.RessourceID != nil {
+		if slices.ContainsFunc(lightCapabilityAttrs, msg.Attr.Has) {
+			am.client.InvalidateLight(*msg.RessourceID)
+			if msg.UniqueID != nil {
+				am.client.InvalidateLight(*msg.UniqueID)
+			}
+		}
+	}
+
+	// Groups have no unique ID and are identified by their resource ID
+	if msg.RessourceType == deconz.GroupsRessource {
+		if msg.RessourceID == nil {
+			return
+		}
+		if group := am.Groups[*msg.RessourceID]; group != nil {
+			if msg.Name != nil {
+				group.SetName(*msg.Name)
+			}
+			if msg.Action != nil {
+				group.UpdateState(msg.Action)
+			}
+			if msg.State != nil {
+				group.UpdateState(msg.State)
+			}
+		}
+		return
+	}
+
 	// Find the service corresponding to the device and update its state
 	id := *msg.UniqueID
+	if msg.Name != nil || (msg.Attr != nil && msg.Attr.Has("lastseen")) {
+		if device := am.deviceForService(id); device != nil {
+			if msg.Name != nil {
+				device.SetName(*msg.Name)
+			}
+			if msg.Attr != nil && msg.Attr.Has("lastseen") {
+				device.UpdateLastSeen(msg.Attr.ValueToString("lastseen"))
+			}
+		}
+	}
 	if service := am.Services[id]; service != nil {
 		if msg.State != nil {
 			service.UpdateState(msg.State)
 		}
 		if msg.Config != nil {
 			service.UpdateConfig(msg.Config)
+
+			// Sensors can be disabled in deCONZ, which stops their reports
+			if msg.RessourceType == deconz.SensorsRessource && msg.Config.Has("on") {
+				if device := am.deviceForService(id); device != nil {
+					device.SetEnabled(id, msg.Config.ValueToBool("on"))
+				}
+			}
+		}
+	}
+
+	// Forward the state to the services observing the device
+	if msg.State != nil {
+		for _, observer := range am.observers[id] {
+			observer.UpdateState(msg.State)
 		}
 	}
 }
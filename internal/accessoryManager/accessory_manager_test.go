@@ -0,0 +1,141 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"encoding/json"
+	"github.com/brutella/hap/service"
+	"slices"
+	"testing"
+)
+
+// message decodes a WebSocket message of the gateway.
+func message(t *testing.T, raw string) *deconz.Messsage {
+	t.Helper()
+
+	msg := new(deconz.Messsage)
+	if err := json.Unmarshal([]byte(raw), msg); err != nil {
+		t.Fatalf("invalid message %s: %v", raw, err)
+	}
+	return msg
+}
+
+func TestProcessUpdateRenamesDevice(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	rename := `{"t":"event","e":"changed","r":"sensors","id":"5","uniqueid":"00:11:22:33:44:55:66:77-01-0500","name":"Bedroom"}`
+
+	tests := []struct {
+		name      string
+		overrides map[string]deviceConfiguration.Override
+		want      string
+	}{
+		{"renamed", nil, "Bedroom"},
+		{"override is kept", map[string]deviceConfiguration.Override{config.UniqueId: {Name: "Kids Room"}}, "Kids Room"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := NewAccessoryManager(nil, []*deconz.Device{config}, Options{Overrides: tt.overrides})
+			am.ProcessUpdate(message(t, rename))
+
+			if got := am.Devices[config.UniqueId].Accessory.Info.Name.Value(); got != tt.want {
+				t.Errorf("Name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribersReceiveUpdates(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	am := NewAccessoryManager(nil, []*deconz.Device{config}, Options{})
+
+	var received []string
+	am.Subscribe(func(*deconz.Messsage) {
+		panic("broken integration")
+	})
+	am.Subscribe(func(msg *deconz.Messsage) {
+		// The HomeKit state is updated before the subscribers are notified
+		sensor := am.Services["00:11:22:33:44:55:66:77-01-0500"].(*TemperatureSensor)
+		if got := sensor.service.CurrentTemperature.Value(); got != 22.5 {
+			t.Errorf("CurrentTemperature when notified = %v, want 22.5", got)
+		}
+		received = append(received, *msg.UniqueID)
+	})
+
+	// A panicking subscriber neither stops the processing nor the other subscribers
+	am.ProcessUpdate(message(t, `{"t":"event","e":"changed","r":"sensors","id":"5","uniqueid":"00:11:22:33:44:55:66:77-01-0500","state":{"temperature":2250}}`))
+	am.ProcessUpdate(message(t, `{"t":"event","e":"changed","r":"sensors","id":"5","uniqueid":"00:11:22:33:44:55:66:77-01-0500","state":{"temperature":2250}}`))
+
+	if len(received) != 2 || received[0] != "00:11:22:33:44:55:66:77-01-0500" {
+		t.Errorf("received = %v, want two updates of the sensor", received)
+	}
+}
+
+func TestGetAccessoriesOrder(t *testing.T) {
+	var configs []*deconz.Device
+	for _, id := range []string{"00:11:22:33:44:55:66:03", "00:11:22:33:44:55:66:01", "00:11:22:33:44:55:66:02"} {
+		config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+		config.UniqueId = id
+		config.Subdevices[0].UniqueId = id + "-01-0402"
+		configs = append(configs, config)
+	}
+	am := NewAccessoryManager(nil, configs, Options{})
+
+	ids := func() []uint64 {
+		var ids []uint64
+		for _, a := range am.GetAccessories() {
+			ids = append(ids, a.Id)
+		}
+		return ids
+	}
+
+	// The accessories are sorted by their ID on every call
+	first := ids()
+	if len(first) != len(configs) {
+		t.Fatalf("%d accessories, want %d", len(first), len(configs))
+	}
+	if !slices.IsSorted(first) {
+		t.Errorf("accessory IDs %v are not sorted", first)
+	}
+	for range 10 {
+		if got := ids(); !slices.Equal(got, first) {
+			t.Fatalf("accessory IDs %v, want %v", got, first)
+		}
+	}
+}
+
+func TestServiceOrderIgnoresSubdeviceOrder(t *testing.T) {
+	subdevices := []deconz.Subdevice{
+		{
+			Type:     deconz.HumiditySensorDevice,
+			UniqueId: "00:15:8d:00:02:7a:3c:11-01-0405",
+			State:    extendedMap(t, `{"humidity": {"value": 4530}}`),
+		},
+		{
+			Type:     deconz.TemperatureDevice,
+			UniqueId: "00:15:8d:00:02:7a:3c:11-01-0402",
+			State:    extendedMap(t, `{"temperature": {"value": 2150}}`),
+		},
+	}
+
+	// types returns the service types of the accessory of a device with the subdevices
+	types := func(subdevices []deconz.Subdevice) []string {
+		config := &deconz.Device{UniqueId: "00:15:8d:00:02:7a:3c:11", Name: "Weather", Subdevices: subdevices}
+		var types []string
+		for _, s := range newTestDevice(t, nil, config, Options{}).Accessory.Ss {
+			types = append(types, s.Type)
+		}
+		return types
+	}
+
+	// The services are ordered by the unique IDs of the subdevices, not by their order in deCONZ
+	want := types(subdevices)
+	reversed := slices.Clone(subdevices)
+	slices.Reverse(reversed)
+	if got := types(reversed); !slices.Equal(got, want) {
+		t.Errorf("service types %v for reversed subdevices, want %v", got, want)
+	}
+	if want[1] != service.TypeTemperatureSensor || want[2] != service.TypeHumiditySensor {
+		t.Errorf("service types %v, want the temperature sensor before the humidity sensor", want)
+	}
+}
@@ -0,0 +1,28 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+// characteristicBatch collects the updates of several characteristics from a single
+// state update, so that they are applied together once the whole state has been evaluated.
+//
+// hap notifies the connected controllers within each SetValue call and offers no way to
+// combine several characteristics into one event, so the updates can't be sent atomically.
+// Applying them in one go sends the events back to back, without conversions or lookups in
+// between, which keeps the intermediate states (e.g. on with the old brightness) short.
+// Values which didn't change are not sent by hap at all.
+type characteristicBatch []func()
+
+// add queues the update of a characteristic.
+//
+// Parameters:
+//   - update: The function setting the new value of the characteristic
+func (b *characteristicBatch) add(update func()) {
+	*b = append(*b, update)
+}
+
+// apply sets all queued values in the order they were added.
+func (b characteristicBatch) apply() {
+	for _, update := range b {
+		update()
+	}
+}
@@ -0,0 +1,103 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// DefaultLowBatteryThreshold is the default battery level (in percent) below which
+// a battery is reported as low, if the sensor doesn't report a low battery state itself.
+const DefaultLowBatteryThreshold = 20
+
+// Battery handles the battery characteristics shared by all battery powered sensors.
+// deCONZ reports the battery level in the config ("battery") and some sensors additionally
+// report a low battery state ("lowbattery"). If the low battery state is missing, it is
+// derived from the battery level using the configured threshold.
+type Battery struct {
+	// lowBatteryCharacteristic is the HomeKit characteristic for low battery status
+	lowBatteryCharacteristic *characteristic.StatusLowBattery
+
+	// batteryLevelCharacteristic is the HomeKit characteristic for the battery level
+	batteryLevelCharacteristic *characteristic.BatteryLevel
+
+	// derivedLowBattery indicates that the low battery status is derived from the battery level
+	derivedLowBattery bool
+
+	// threshold is the battery level (in percent) below which the battery is reported as low
+	threshold int
+}
+
+// newBattery adds the battery characteristics to a service, depending on the values
+// reported by the deCONZ subdevice.
+//
+// Parameters:
+//   - s: The HomeKit service to add the characteristics to
+//   - config: A pointer to the deCONZ subdevice configuration
+//   - threshold: The battery level (in percent) below which the battery is reported as low
+//
+// Returns:
+//   - *Battery: A pointer to the initialized Battery
+func newBattery(s *service.S, config *deconz.Subdevice, threshold int) *Battery {
+	b := new(Battery)
+	b.threshold = threshold
+
+	// Add the low battery characteristic if the sensor reports battery status or level
+	if config.State.Has("lowbattery") || config.Config.Has("battery") {
+		b.lowBatteryCharacteristic = characteristic.NewStatusLowBattery()
+		b.derivedLowBattery = !config.State.Has("lowbattery")
+		s.AddC(b.lowBatteryCharacteristic.C)
+	}
+
+	// Add the battery level characteristic if the sensor reports battery config
+	if config.Config.Has("battery") {
+		b.batteryLevelCharacteristic = characteristic.NewBatteryLevel()
+		s.AddC(b.batteryLevelCharacteristic.C)
+	}
+
+	return b
+}
+
+// present reports whether any battery characteristic was added.
+//
+// Returns:
+//   - bool: True if the subdevice reports battery status or level
+func (b *Battery) present() bool {
+	return b.lowBatteryCharacteristic != nil || b.batteryLevelCharacteristic != nil
+}
+
+// UpdateState updates the low battery status based on state updates from the deCONZ gateway.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (b *Battery) UpdateState(state deconz.MapObject) {
+	if state.Has("lowbattery") && b.lowBatteryCharacteristic != nil && !b.derivedLowBattery {
+		batteryIsLow := state.ValueToBool("lowbattery")
+		// Convert boolean to int (0 = normal, 1 = low)
+		_ = b.lowBatteryCharacteristic.SetValue(boolToInt[batteryIsLow])
+	}
+}
+
+// UpdateConfig updates the battery level based on config updates from the deCONZ gateway.
+// If the sensor doesn't report a low battery state, it is derived from the battery level.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (b *Battery) UpdateConfig(config deconz.MapObject) {
+	if !config.Has("battery") {
+		return
+	}
+	batteryLevel := config.ValueToInt("battery")
+
+	// Update the battery level characteristic if available
+	if b.batteryLevelCharacteristic != nil {
+		_ = b.batteryLevelCharacteristic.SetValue(batteryLevel)
+	}
+
+	// Derive the low battery status from the battery level
+	if b.lowBatteryCharacteristic != nil && b.derivedLowBattery {
+		_ = b.lowBatteryCharacteristic.SetValue(boolToInt[batteryLevel < b.threshold])
+	}
+}
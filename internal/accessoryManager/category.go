@@ -0,0 +1,122 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"github.com/brutella/hap/accessory"
+)
+
+// categoryTypes maps the categories which can be selected in the configuration to HomeKit accessory categories.
+var categoryTypes = map[deviceConfiguration.Category]byte{
+	deviceConfiguration.CategoryLightbulb:          accessory.TypeLightbulb,
+	deviceConfiguration.CategoryOutlet:             accessory.TypeOutlet,
+	deviceConfiguration.CategorySwitch:             accessory.TypeSwitch,
+	deviceConfiguration.CategoryProgrammableSwitch: accessory.TypeProgrammableSwitch,
+	deviceConfiguration.CategorySensor:             accessory.TypeSensor,
+	deviceConfiguration.CategoryFan:                accessory.TypeFan,
+	deviceConfiguration.CategoryGarageDoorOpener:   accessory.TypeGarageDoorOpener,
+	deviceConfiguration.CategoryDoorLock:           accessory.TypeDoorLock,
+	deviceConfiguration.CategoryWindowCovering:     accessory.TypeWindowCovering,
+	deviceConfiguration.CategoryThermostat:         accessory.TypeThermostat,
+	deviceConfiguration.CategoryAirPurifier:        accessory.TypeAirPurifier,
+	deviceConfiguration.CategorySprinkler:          accessory.TypeSprinkler,
+	deviceConfiguration.CategoryFaucet:             accessory.TypeFaucet,
+	deviceConfiguration.CategoryOther:              accessory.TypeOther,
+}
+
+// serviceCategories maps the services which can be selected in the configuration to HomeKit accessory categories.
+var serviceCategories = map[deviceConfiguration.Service]byte{
+	deviceConfiguration.ServiceFan:        accessory.TypeFan,
+	deviceConfiguration.ServiceValve:      accessory.TypeFaucet,
+	deviceConfiguration.ServiceIrrigation: accessory.TypeSprinkler,
+	deviceConfiguration.ServiceGarageDoor: accessory.TypeGarageDoorOpener,
+	deviceConfiguration.ServiceOutlet:     accessory.TypeOutlet,
+	deviceConfiguration.ServiceSwitch:     accessory.TypeSwitch,
+	deviceConfiguration.ServiceLightbulb:  accessory.TypeLightbulb,
+}
+
+// deviceCategories maps deCONZ device types to HomeKit accessory categories.
+// The categories follow the services the types are exposed as, e.g. on/off switches
+// are exposed as contact sensors and remotes as programmable switches.
+var deviceCategories = map[deconz.DeviceType]byte{
+	deconz.OnOffLightDevice:            accessory.TypeLightbulb,
+	deconz.DimmableLightDevice:         accessory.TypeLightbulb,
+	deconz.ColorTemperatureLightDevice: accessory.TypeLightbulb,
+	deconz.ColorLightDevice:            accessory.TypeLightbulb,
+	deconz.ExtendedColorLightDevice:    accessory.TypeLightbulb,
+	deconz.OnOffOutputDevice:           accessory.TypeOutlet,
+	deconz.OnOffPlugInUnitDevice:       accessory.TypeOutlet,
+	deconz.SmartPlugDevice:             accessory.TypeOutlet,
+	deconz.DimmablePlugInUnitDevice:    accessory.TypeOutlet,
+	deconz.SwitchDevice:                accessory.TypeProgrammableSwitch,
+	deconz.DimmerSwitchDevice:          accessory.TypeProgrammableSwitch,
+	deconz.LevelControlSwitchDevice:    accessory.TypeProgrammableSwitch,
+	deconz.RelativeRotaryDevice:        accessory.TypeProgrammableSwitch,
+	deconz.OnOffSwitchDevice:           accessory.TypeSensor,
+	deconz.OnOffLightSwitchDevice:      accessory.TypeSensor,
+	deconz.PresenceSensorDevice:        accessory.TypeSensor,
+	deconz.OpenCloseSensorDevice:       accessory.TypeSensor,
+	deconz.WaterDevice:                 accessory.TypeSensor,
+	deconz.FireSensorDevice:            accessory.TypeSensor,
+	deconz.CarbonMonoxideDevice:        accessory.TypeSensor,
+	deconz.TemperatureDevice:           accessory.TypeSensor,
+	deconz.HumiditySensorDevice:        accessory.TypeSensor,
+	deconz.MoistureSensorDevice:        accessory.TypeSensor,
+	deconz.ParticulateMatterDevice:     accessory.TypeSensor,
+	deconz.PressureDevice:              accessory.TypeSensor,
+	deconz.VibrationDevice:             accessory.TypeSensor,
+	deconz.AlarmDevice:                 accessory.TypeSensor,
+	deconz.AncillaryControlDevice:      accessory.TypeProgrammableSwitch,
+	deconz.LightLevelSensorDevice:      accessory.TypeSensor,
+	deconz.PowerDevice:                 accessory.TypeSensor,
+	deconz.ConsumptionDevice:           accessory.TypeSensor,
+	deconz.ThermostatDevice:            accessory.TypeThermostat,
+	deconz.WindowCoveringDevice:        accessory.TypeWindowCovering,
+	deconz.DoorLockDevice:              accessory.TypeDoorLock,
+	deconz.AirPurifierDevice:           accessory.TypeAirPurifier,
+}
+
+// accessoryCategory returns the HomeKit accessory category of a subdevice.
+// A service selected in the configuration takes precedence over the device type.
+//
+// Parameters:
+//   - t: The deCONZ device type of the subdevice
+//   - service: The service selected for the subdevice (empty if none is selected)
+//
+// Returns:
+//   - byte: The accessory category (e.g. accessory.TypeLightbulb), accessory.TypeOther if the type is unknown
+func accessoryCategory(t deconz.DeviceType, service deviceConfiguration.Service) byte {
+	if category, ok := serviceCategories[service]; ok {
+		return category
+	}
+	if category, ok := deviceCategories[t]; ok {
+		return category
+	}
+	return accessory.TypeOther
+}
+
+// category returns the HomeKit accessory category of the device.
+// The category configured in the overrides or the device configuration of the model
+// takes precedence over the category of the primary subdevice.
+//
+// Parameters:
+//   - primary: A pointer to the subdevice of the primary service
+//
+// Returns:
+//   - byte: The accessory category
+func (device *Device) category(primary *deconz.Subdevice) byte {
+	configured := device.options.Overrides[device.ID].Category
+	if configs, err := loadDeviceConfigurations(); err == nil && len(configured) == 0 {
+		configured = configs[device.model].Category
+	}
+	if len(configured) > 0 {
+		if category, ok := categoryTypes[configured]; ok {
+			return category
+		}
+		device.log.Warnf("ignoring unknown category %q", configured)
+	}
+
+	return accessoryCategory(primary.Type, device.selectedService(primary))
+}
@@ -0,0 +1,77 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"github.com/brutella/hap/accessory"
+	"testing"
+)
+
+func TestAccessoryCategory(t *testing.T) {
+	tests := []struct {
+		name       string
+		deviceType deconz.DeviceType
+		service    deviceConfiguration.Service
+		want       byte
+	}{
+		{"color light", deconz.ExtendedColorLightDevice, "", accessory.TypeLightbulb},
+		{"plug", deconz.OnOffPlugInUnitDevice, "", accessory.TypeOutlet},
+		{"output", deconz.OnOffOutputDevice, "", accessory.TypeOutlet},
+		{"remote", deconz.SwitchDevice, "", accessory.TypeProgrammableSwitch},
+		{"temperature sensor", deconz.TemperatureDevice, "", accessory.TypeSensor},
+		{"thermostat", deconz.ThermostatDevice, "", accessory.TypeThermostat},
+		{"unknown type", deconz.DeviceType("ZHAUnknown"), "", accessory.TypeOther},
+		{"output as switch", deconz.OnOffOutputDevice, deviceConfiguration.ServiceSwitch, accessory.TypeSwitch},
+		{"output as garage door", deconz.OnOffOutputDevice, deviceConfiguration.ServiceGarageDoor, accessory.TypeGarageDoorOpener},
+		{"light as fan", deconz.DimmableLightDevice, deviceConfiguration.ServiceFan, accessory.TypeFan},
+		{"unknown service", deconz.OnOffPlugInUnitDevice, deviceConfiguration.Service("blender"), accessory.TypeOutlet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accessoryCategory(tt.deviceType, tt.service); got != tt.want {
+				t.Errorf("accessoryCategory(%q, %q) = %d, want %d", tt.deviceType, tt.service, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceCategory(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	config.Model = "lumi.weather"
+
+	tests := []struct {
+		name      string
+		configs   map[string]deviceConfiguration.DeviceConfiguration
+		overrides map[string]deviceConfiguration.Override
+		want      byte
+	}{
+		{"type of the primary subdevice", nil, nil, accessory.TypeSensor},
+		{
+			name:    "configuration of the model",
+			configs: map[string]deviceConfiguration.DeviceConfiguration{"lumi.weather": {Category: deviceConfiguration.CategoryThermostat}},
+			want:    accessory.TypeThermostat,
+		},
+		{
+			name:      "override",
+			configs:   map[string]deviceConfiguration.DeviceConfiguration{"lumi.weather": {Category: deviceConfiguration.CategoryThermostat}},
+			overrides: map[string]deviceConfiguration.Override{config.UniqueId: {Category: deviceConfiguration.CategoryOther}},
+			want:      accessory.TypeOther,
+		},
+		{
+			name:      "unknown category",
+			overrides: map[string]deviceConfiguration.Override{config.UniqueId: {Category: "toaster"}},
+			want:      accessory.TypeSensor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDeviceConfigurations(t, tt.configs)
+			device := newTestDevice(t, nil, config, Options{Overrides: tt.overrides})
+			if got := device.Accessory.Type; got != tt.want {
+				t.Errorf("Type = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
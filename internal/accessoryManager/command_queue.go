@@ -0,0 +1,53 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import "github.com/charmbracelet/log"
+
+// commandQueue is a queue of commands for the deCONZ gateway.
+// The commands are sent in order by a background goroutine, so HomeKit doesn't
+// have to wait for the gateway before showing the new value.
+type commandQueue chan func()
+
+// newCommandQueue creates a new command queue and starts sending its commands.
+// The goroutine runs for the lifetime of the service owning the queue.
+//
+// Returns:
+//   - commandQueue: The initialized command queue
+func newCommandQueue() commandQueue {
+	queue := make(commandQueue, commandQueueSize)
+	go func() {
+		for command := range queue {
+			command()
+		}
+	}()
+	return queue
+}
+
+// commandQueueSize is the number of commands which can wait for the gateway.
+const commandQueueSize = 16
+
+// send queues a command for the deCONZ gateway.
+// HomeKit already shows the new value (optimistic update). If the command fails,
+// revert restores the previous value, so HomeKit reflects the real state of the device.
+// The caller is never blocked: if the queue is full because the gateway doesn't keep up
+// (e.g. while it is unreachable), the command is dropped and reverted immediately.
+//
+// Parameters:
+//   - log: The logger of the device
+//   - description: A description of the command for the error log (e.g. "set brightness")
+//   - command: The function sending the command to the gateway
+//   - revert: The function restoring the previous value of the characteristic
+func (queue commandQueue) send(log *log.Logger, description string, command func() error, revert func()) {
+	select {
+	case queue <- func() {
+		if err := command(); err != nil {
+			log.Errorf("failed to %s: %+v", description, err)
+			revert()
+		}
+	}:
+	default:
+		log.Errorf("failed to %s: too many commands are waiting for the gateway", description)
+		revert()
+	}
+}
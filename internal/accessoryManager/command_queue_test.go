@@ -0,0 +1,57 @@
+package accessoryManager
+
+import (
+	"errors"
+	"github.com/charmbracelet/log"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCommandQueueRevertsFailedCommands(t *testing.T) {
+	queue := newCommandQueue()
+	reverted := make(chan struct{})
+
+	queue.send(log.New(io.Discard), "fail", func() error {
+		return errors.New("unreachable")
+	}, func() {
+		close(reverted)
+	})
+
+	select {
+	case <-reverted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed command was not reverted")
+	}
+}
+
+func TestCommandQueueDropsCommandsWhenFull(t *testing.T) {
+	// A queue without a sender, so that it stays full
+	queue := make(commandQueue, 1)
+	logger := log.New(io.Discard)
+
+	reverts := 0
+	queue.send(logger, "first", func() error { return nil }, func() { reverts++ })
+	if reverts != 0 {
+		t.Fatalf("the queued command was reverted")
+	}
+
+	// The second command must neither block nor be queued
+	done := make(chan struct{})
+	go func() {
+		queue.send(logger, "second", func() error { return nil }, func() { reverts++ })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send() blocked on a full queue")
+	}
+
+	if reverts != 1 {
+		t.Errorf("reverts = %d, want 1", reverts)
+	}
+	if len(queue) != 1 {
+		t.Errorf("queued commands = %d, want 1", len(queue))
+	}
+}
@@ -0,0 +1,87 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"sync"
+	"time"
+)
+
+// debounceDelay is the time to wait for further changes before a value is sent to the gateway.
+const debounceDelay = 200 * time.Millisecond
+
+// debouncer coalesces rapid changes of a characteristic (e.g. while dragging a slider),
+// so that only the latest value within the delay is sent to the deCONZ gateway.
+// It also remembers the value from before the first coalesced change, so a failed
+// command can restore the value HomeKit showed before the user started the change.
+// If the final value equals this value (e.g. a slider was dragged back), nothing is sent,
+// unless the debouncer was forced to deliver it (e.g. after a failed command).
+type debouncer[T comparable] struct {
+	// delay is the time to wait for further changes
+	delay time.Duration
+
+	// mu guards the timer, the previous value and forced
+	mu sync.Mutex
+
+	// timer calls the pending function once the delay has expired
+	timer *time.Timer
+
+	// previous is the value from before the first pending change
+	previous T
+
+	// forced makes the next delivery call the function even if the value is unchanged
+	forced bool
+}
+
+// newDebouncer creates a new debouncer.
+//
+// Parameters:
+//   - delay: The time to wait for further changes
+//
+// Returns:
+//   - *debouncer[T]: A pointer to the initialized debouncer
+func newDebouncer[T comparable](delay time.Duration) *debouncer[T] {
+	return &debouncer[T]{delay: delay}
+}
+
+// call schedules fn to be called after the delay. A pending call is cancelled
+// and replaced, so the timer restarts on every change and the final value is
+// always delivered, unless it is the value from before the first pending change.
+// HomeKit already ignores writes of the current value, this also skips changes
+// which are undone within the delay, so no redundant command is sent to the gateway.
+// After force, the value is delivered even if it is unchanged.
+//
+// Parameters:
+//   - value: The new value of the characteristic
+//   - previous: The value of the characteristic before this change
+//   - fn: The function to call with the value from before the first pending change
+func (d *debouncer[T]) call(value T, previous T, fn func(previous T)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Keep the original value if a pending call is replaced
+	if d.timer != nil && d.timer.Stop() {
+		previous = d.previous
+	}
+	d.previous = previous
+
+	d.timer = time.AfterFunc(d.delay, func() {
+		d.mu.Lock()
+		forced := d.forced
+		d.forced = false
+		d.mu.Unlock()
+
+		if forced || value != previous {
+			fn(previous)
+		}
+	})
+}
+
+// force makes the pending call, or the next one if none is pending, deliver its value even
+// if it equals the value from before the change. This is used when the known value may differ
+// from the state of the device, e.g. after a failed command, which may have been applied anyway.
+func (d *debouncer[T]) force() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.forced = true
+}
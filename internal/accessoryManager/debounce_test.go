@@ -0,0 +1,51 @@
+package accessoryManager
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncerSkipsUnchangedValue(t *testing.T) {
+	d := newDebouncer[int](10 * time.Millisecond)
+	var calls atomic.Int32
+
+	// A change which is undone within the delay isn't delivered
+	d.call(60, 50, func(int) { calls.Add(1) })
+	d.call(50, 60, func(int) { calls.Add(1) })
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 0 {
+		t.Fatalf("delivered %d times, want 0", got)
+	}
+
+	// Only the final value of a change is delivered, with the value from before the change
+	var previous atomic.Int32
+	d.call(60, 50, func(int) { calls.Add(1) })
+	d.call(70, 60, func(p int) { calls.Add(1); previous.Store(int32(p)) })
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 1 || previous.Load() != 50 {
+		t.Errorf("delivered %d times with previous %d, want once with 50", got, previous.Load())
+	}
+}
+
+func TestDebouncerForce(t *testing.T) {
+	d := newDebouncer[int](10 * time.Millisecond)
+	var calls atomic.Int32
+
+	// A forced debouncer delivers the unchanged value once
+	d.force()
+	d.call(60, 50, func(int) { calls.Add(1) })
+	d.call(50, 60, func(int) { calls.Add(1) })
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("delivered %d times after force, want 1", got)
+	}
+
+	// The next unchanged value is skipped again
+	d.call(60, 50, func(int) { calls.Add(1) })
+	d.call(50, 60, func(int) { calls.Add(1) })
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("delivered %d times, want no further delivery", got)
+	}
+}
@@ -3,13 +3,17 @@
 package accessoryManager
 
 import (
+	"cmp"
 	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
 	"errors"
-	"fmt"
 	"github.com/brutella/hap/accessory"
 	"github.com/brutella/hap/service"
 	"github.com/charmbracelet/log"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,35 +41,76 @@ type Device struct {
 	// Services is a map of deCONZ device unique IDs to DeviceService interfaces
 	Services map[string]DeviceService
 
+	// model is the model identifier of the device
+	model string
+
+	// status reports whether the sensors of the device are still reporting
+	status *SensorStatus
+
+	// mergeLights indicates whether the two lights of the device may be merged into one service
+	mergeLights bool
+
+	// hasButtons indicates whether the device has a switch subdevice, whose buttons are
+	// labeled before the directions of a rotary control of the same device
+	hasButtons bool
+
+	// observers is a map of unique IDs of other subdevices to the services observing their state
+	observers map[string]DeviceService
+
+	// updateMu serializes the updates of the services from outside the accessory manager
+	// (e.g. refreshes on reads) with the updates of the manager, which shares its mutex
+	updateMu *sync.Mutex
+
 	// client is the deCONZ API client for communicating with the gateway
 	client *deconz.ApiClient
 
+	// options contains the settings for exposing the device to HomeKit
+	options Options
+
 	// log is the logger for this device
 	log *log.Logger
 }
 
+// unknownInfo is the placeholder for accessory information which is not reported by deCONZ.
+const unknownInfo = "Unknown"
+
 // NewDevice creates a new Device from a deCONZ device configuration.
 // It initializes the HomeKit accessory and adds services for each subdevice.
 //
 // Parameters:
 //   - client: A pointer to the deCONZ API client for communication with the gateway
 //   - config: A pointer to the deCONZ device configuration
+//   - options: The settings for exposing the device to HomeKit
 //
 // Returns:
 //   - *Device: A pointer to the initialized Device
 //   - error: An error if the device could not be created or has no services
-func NewDevice(client *deconz.ApiClient, config *deconz.Device) (*Device, error) {
+func NewDevice(client *deconz.ApiClient, config *deconz.Device, options Options) (*Device, error) {
 	d := new(Device)
 	d.client = client
+	d.options = options
 	d.ID = config.UniqueId
+	d.model = config.Model
 	d.Services = make(map[string]DeviceService)
+	d.observers = make(map[string]DeviceService)
+	d.updateMu = new(sync.Mutex)
+
+	// Track when the device was last seen, so stale sensors can be reported as faulted
+	lastSeen, _ := deconz.ParseTimestamp(config.LastSeen)
+	d.status = newSensorStatus(options.StaleThreshold, lastSeen)
+
+	// Replace the deCONZ name and information with the user-defined values if configured
+	// Some devices don't report a manufacturer, model or firmware, which some controllers
+	// display oddly, so these fall back to a placeholder
+	override := options.Overrides[config.UniqueId]
+	name := cmp.Or(override.Name, config.Name)
 
 	// Create a new HomeKit accessory with information from the deCONZ device
 	d.Accessory = accessory.New(accessory.Info{
-		Name:         config.Name,
-		Manufacturer: config.Manufacturer,
-		Model:        config.Model,
-		Firmware:     config.SwVersion,
+		Name:         name,
+		Manufacturer: cmp.Or(override.Manufacturer, config.Manufacturer, unknownInfo),
+		Model:        cmp.Or(override.Model, config.Model, unknownInfo),
+		Firmware:     cmp.Or(override.Firmware, config.SwVersion, unknownInfo),
 		SerialNumber: config.UniqueId,
 	}, accessory.TypeUnknown)
 
@@ -76,14 +121,58 @@ func NewDevice(client *deconz.ApiClient, config *deconz.Device) (*Device, error)
 	d.log = log.NewWithOptions(os.Stderr, log.Options{
 		ReportTimestamp: true,
 		TimeFormat:      time.DateTime,
-		Prefix:          config.Name,
+		Prefix:          name,
+	})
+
+	// Process lights before sensors, because some sensors (e.g. power meters)
+	// attach their characteristics to the services of the lights.
+	// Within these, the subdevices are sorted by their unique ID, so that the services
+	// keep their order and instance IDs even if deCONZ lists the subdevices differently
+	subdevices := slices.Clone(config.Subdevices)
+
+	// Replace the deCONZ type with the user-defined type if configured
+	for i, sub := range subdevices {
+		if t := options.overrideType(config.UniqueId, sub.UniqueId); len(t) > 0 {
+			d.log.Infof("using type %s instead of %s for %s", t, sub.Type, sub.UniqueId)
+			subdevices[i].Type = t
+		}
+	}
+	slices.SortStableFunc(subdevices, func(a, b deconz.Subdevice) int {
+		return cmp.Or(
+			boolToInt[isSensorType(a.Type)]-boolToInt[isSensorType(b.Type)],
+			strings.Compare(a.UniqueId, b.UniqueId),
+		)
 	})
 
 	// Log device discovery and process each subdevice
 	d.log.Infof("discovered device (%s)", config.UniqueId)
-	for _, sub := range config.Subdevices {
+	d.mergeLights = d.mergeableLights(subdevices)
+	d.hasButtons = slices.ContainsFunc(subdevices, func(sub deconz.Subdevice) bool {
+		return slices.Contains(buttonTypes, sub.Type)
+	})
+	var primary *deconz.Subdevice
+	for _, sub := range subdevices {
 		if err := addSubdevice(d, &sub); err != nil {
-			d.log.Warnf("failed to add the service %s: %+v", sub.Type, err)
+			// Unsupported types are reported in a summary after all devices are created
+			if errors.Is(err, errNotImplemented) {
+				d.log.Debugf("skipping unsupported service %s", sub.Type)
+				continue
+			}
+			// Other subdevices of the same device are still added
+			d.log.Warnf("failed to add the service %s of %s: %+v", sub.Type, sub.UniqueId, err)
+			continue
+		}
+
+		// The first subdevice with a service is the primary one, lights are processed first
+		if primary == nil && d.Services[sub.UniqueId] != nil {
+			primary = &sub
+		}
+	}
+
+	// Mark the sensors as faulted if a sensor with a service is disabled in deCONZ
+	for _, sub := range subdevices {
+		if isSensorType(sub.Type) && sub.Config.Has("on") && d.Services[sub.UniqueId] != nil {
+			d.SetEnabled(sub.UniqueId, sub.Config.ValueToBool("on"))
 		}
 	}
 
@@ -93,9 +182,73 @@ func NewDevice(client *deconz.ApiClient, config *deconz.Device) (*Device, error)
 		return nil, errors.New("no services found")
 	}
 
+	// Bridged accessories are shown with the icon of their primary service, the category
+	// is set as well, but HomeKit only announces the category of the bridge itself
+	// Switches and meters have no service of their own and keep the default
+	if s := d.Services[primary.UniqueId].S(); s != nil {
+		s.Primary = true
+	}
+	d.Accessory.Type = d.category(primary)
+
 	return d, nil
 }
 
+// subdeviceConstructors maps deCONZ device types to the constructors of the
+// corresponding HomeKit services.
+var subdeviceConstructors = map[deconz.DeviceType]func(*Device, *deconz.Subdevice) error{
+	deconz.DimmableLightDevice:         (*Device).NewLightFromCapabilities,
+	deconz.ColorTemperatureLightDevice: (*Device).NewLightFromCapabilities,
+	deconz.ColorLightDevice:            (*Device).NewLightFromCapabilities,
+	deconz.ExtendedColorLightDevice:    (*Device).NewLightFromCapabilities,
+	deconz.PresenceSensorDevice:        (*Device).NewPresenceSensor,
+	deconz.OpenCloseSensorDevice:       (*Device).NewOpenCloseSensor,
+	deconz.OnOffOutputDevice:           (*Device).NewOnOffPlugDevice,
+	deconz.OnOffPlugInUnitDevice:       (*Device).NewOnOffPlugDevice,
+	deconz.SmartPlugDevice:             (*Device).NewOnOffPlugDevice,
+	deconz.OnOffSwitchDevice:           (*Device).NewOnOffSwitch,
+	deconz.OnOffLightDevice:            (*Device).NewLightFromCapabilities,
+	deconz.OnOffLightSwitchDevice:      (*Device).NewOnOffSwitch,
+	deconz.SwitchDevice:                (*Device).NewSwitch,
+	deconz.DimmerSwitchDevice:          (*Device).NewSwitch,
+	deconz.LevelControlSwitchDevice:    (*Device).NewSwitch,
+	deconz.RelativeRotaryDevice:        (*Device).NewRotaryDevice,
+	deconz.WaterDevice:                 (*Device).NewWaterSensor,
+	deconz.FireSensorDevice:            (*Device).NewFireSensor,
+	deconz.CarbonMonoxideDevice:        (*Device).NewCarbonMonoxideSensor,
+	deconz.TemperatureDevice:           (*Device).NewTemperatureSensor,
+	deconz.HumiditySensorDevice:        (*Device).NewHumiditySensor,
+	deconz.MoistureSensorDevice:        (*Device).NewMoistureSensor,
+	deconz.ParticulateMatterDevice:     (*Device).NewParticulateMatterSensor,
+	deconz.PressureDevice:              (*Device).NewPressureSensor,
+	deconz.VibrationDevice:             (*Device).NewVibrationSensor,
+	deconz.AlarmDevice:                 (*Device).NewAlarmSensor,
+	deconz.AncillaryControlDevice:      (*Device).NewAncillaryControl,
+	deconz.DimmablePlugInUnitDevice:    (*Device).NewDimmablePlug,
+	deconz.PowerDevice:                 (*Device).NewPowerMeter,
+	deconz.ConsumptionDevice:           (*Device).NewPowerMeter,
+}
+
+// serviceConstructors maps the services which can be selected in a device configuration
+// to the constructors of the corresponding HomeKit services.
+var serviceConstructors = map[deviceConfiguration.Service]func(*Device, *deconz.Subdevice) error{
+	deviceConfiguration.ServiceFan:        (*Device).NewFan,
+	deviceConfiguration.ServiceValve:      (*Device).NewValve,
+	deviceConfiguration.ServiceIrrigation: (*Device).NewIrrigationValve,
+	deviceConfiguration.ServiceGarageDoor: (*Device).NewGarageDoor,
+	deviceConfiguration.ServiceOutlet:     (*Device).NewOnOffPlugDevice,
+	deviceConfiguration.ServiceSwitch:     (*Device).NewRelaySwitch,
+	deviceConfiguration.ServiceLightbulb:  (*Device).NewLightFromCapabilities,
+}
+
+// loadDeviceConfigurations loads the device configurations from the devices directory once.
+// These configurations define how the buttons of switches and the services of lights are mapped.
+var loadDeviceConfigurations = sync.OnceValues(func() (map[string]deviceConfiguration.DeviceConfiguration, error) {
+	return deviceConfiguration.LoadFromDirectory("./devices")
+})
+
+// errNotImplemented is returned for subdevices whose type is not supported.
+var errNotImplemented = errors.New("not implemented")
+
 // addSubdevice adds a service to a device based on the subdevice type.
 // It maps deCONZ device types to HomeKit service types and creates the appropriate service.
 //
@@ -106,37 +259,106 @@ func NewDevice(client *deconz.ApiClient, config *deconz.Device) (*Device, error)
 // Returns:
 //   - error: An error if the service could not be created or the device type is not supported
 func addSubdevice(dev *Device, config *deconz.Subdevice) error {
+	// Use the service selected in the overrides or the device configuration for the lights of the device
+	if constructor, ok := serviceConstructors[dev.selectedService(config)]; ok {
+		return constructor(dev, config)
+	}
+
 	// Create the appropriate service based on the device type
-	switch config.Type {
-	case deconz.DimmableLightDevice:
-		return dev.NewDimmableLight(config)
-	case deconz.ColorTemperatureLightDevice:
-		return dev.NewColorTemperatureLight(config)
-	case deconz.PresenceSensorDevice:
-		return dev.NewPresenceSensor(config)
-	case deconz.OpenCloseSensorDevice:
-		return dev.NewOpenCloseSensor(config)
-	case deconz.OnOffOutputDevice:
-		return dev.NewOnOffPlugDevice(config)
-	case deconz.OnOffPlugInUnitDevice:
-		return dev.NewOnOffPlugDevice(config)
-	case deconz.SmartPlugDevice:
-		return dev.NewOnOffPlugDevice(config)
-	case deconz.OnOffSwitchDevice:
-		return dev.NewOnOffPlugDevice(config)
-	case deconz.OnOffLightDevice:
-		return dev.NewOnOffLight(config)
-	case deconz.OnOffLightSwitchDevice:
-		return dev.NewOnOffLight(config)
-	case deconz.SwitchDevice:
-		return dev.NewSwitch(config)
-	case deconz.WaterDevice:
-		return dev.NewWaterSensor(config)
-	case deconz.DimmablePlugInUnitDevice:
-		return dev.NewDimmableLight(config)
-
-	default:
-		return fmt.Errorf("not implemented")
+	constructor, ok := subdeviceConstructors[config.Type]
+	if !ok {
+		return errNotImplemented
+	}
+	return constructor(dev, config)
+}
+
+// selectedService returns the service selected for a subdevice in the overrides or,
+// if none is selected there, in the device configuration of the model.
+// Services can only be selected for lights, sensors always use their default service.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - deviceConfiguration.Service: The selected service, or an empty string if none is selected
+func (device *Device) selectedService(config *deconz.Subdevice) deviceConfiguration.Service {
+	if isSensorType(config.Type) {
+		return ""
+	}
+
+	selected := device.options.overrideService(device.ID, config.UniqueId).Service
+	if configs, err := loadDeviceConfigurations(); err == nil && len(selected) == 0 {
+		selected = configs[device.model].Service
+	}
+	return selected
+}
+
+// isSupportedType reports whether a deCONZ device type can be exposed to HomeKit.
+//
+// Parameters:
+//   - t: The deCONZ device type
+//
+// Returns:
+//   - bool: True if the device type is supported
+func isSupportedType(t deconz.DeviceType) bool {
+	_, ok := subdeviceConstructors[t]
+	return ok
+}
+
+// isSensorType reports whether a deCONZ device type is a sensor.
+// All deCONZ sensor types are prefixed with "ZHA".
+//
+// Parameters:
+//   - t: The deCONZ device type
+//
+// Returns:
+//   - bool: True if the device type is a sensor
+func isSensorType(t deconz.DeviceType) bool {
+	return strings.HasPrefix(string(t), "ZHA")
+}
+
+// SetName updates the name of the device after it was renamed in deCONZ.
+// HomeKit controllers cache accessory names, so the new name may only be shown
+// after the controller refreshes the accessory (e.g. after restarting the Home app).
+// A user-defined name from the overrides takes precedence and is kept.
+//
+// Parameters:
+//   - name: The new name of the device
+func (device *Device) SetName(name string) {
+	if len(device.options.Overrides[device.ID].Name) > 0 || device.Accessory.Info.Name.Value() == name {
+		return
+	}
+
+	device.log.Infof("renamed to %s", name)
+	device.Accessory.Info.Name.SetValue(name)
+}
+
+// UpdateLastSeen records the time the device was last seen by the gateway.
+//
+// Parameters:
+//   - lastSeen: The "lastseen" timestamp reported by deCONZ
+func (device *Device) UpdateLastSeen(lastSeen string) {
+	if t, err := deconz.ParseTimestamp(lastSeen); err == nil {
+		device.status.UpdateLastSeen(t)
+	}
+}
+
+// SetEnabled records whether a sensor of the device is enabled in deCONZ.
+// The sensor services of a device with a disabled sensor are reported as inactive and
+// faulted, so that automations don't rely on their values.
+//
+// Parameters:
+//   - id: The unique ID of the sensor
+//   - enabled: The "on" value of the sensor's configuration
+func (device *Device) SetEnabled(id string, enabled bool) {
+	if !device.status.SetEnabled(id, enabled) {
+		return
+	}
+
+	if enabled {
+		device.log.Infof("sensor %s enabled again, marking sensors as active", id)
+	} else {
+		device.log.Warnf("sensor %s disabled in deCONZ, marking sensors as faulted", id)
 	}
 }
 
@@ -0,0 +1,204 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"encoding/json"
+	"github.com/brutella/hap/service"
+	"slices"
+	"testing"
+)
+
+// extendedMap decodes a state or config in the format of the deCONZ devices endpoint,
+// e.g. {"fire": {"value": true}}.
+func extendedMap(t *testing.T, raw string) deconz.ExtendedObjectMap {
+	t.Helper()
+
+	obj := deconz.ExtendedObjectMap{}
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		t.Fatalf("invalid extended map %s: %v", raw, err)
+	}
+	return obj
+}
+
+// withDeviceConfigurations replaces the device configurations of the devices directory
+// for the duration of the test.
+func withDeviceConfigurations(t *testing.T, configs map[string]deviceConfiguration.DeviceConfiguration) {
+	t.Helper()
+
+	load := loadDeviceConfigurations
+	loadDeviceConfigurations = func() (map[string]deviceConfiguration.DeviceConfiguration, error) {
+		return configs, nil
+	}
+	t.Cleanup(func() { loadDeviceConfigurations = load })
+}
+
+// newTestDevice creates a device from a deCONZ device configuration and fails the test on errors.
+// Devices with lights need a client, e.g. of a mock gateway, sensors don't use it.
+func newTestDevice(t *testing.T, client *deconz.ApiClient, config *deconz.Device, options Options) *Device {
+	t.Helper()
+
+	device, err := NewDevice(client, config, options)
+	if err != nil {
+		t.Fatalf("NewDevice() error = %v", err)
+	}
+	return device
+}
+
+// sensorDevice returns a device configuration with a single sensor subdevice.
+func sensorDevice(t *testing.T, sensorType deconz.DeviceType, state string) *deconz.Device {
+	t.Helper()
+
+	return &deconz.Device{
+		UniqueId: "00:11:22:33:44:55:66:77",
+		Name:     "Test Sensor",
+		Subdevices: []deconz.Subdevice{{
+			Type:     sensorType,
+			UniqueId: "00:11:22:33:44:55:66:77-01-0500",
+			State:    extendedMap(t, state),
+			Config:   extendedMap(t, `{"on": {"value": true}}`),
+		}},
+	}
+}
+
+func TestOverrideReplacesName(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+
+	tests := []struct {
+		name      string
+		overrides map[string]deviceConfiguration.Override
+		want      string
+	}{
+		{"deCONZ name", nil, "Test Sensor"},
+		{"override", map[string]deviceConfiguration.Override{config.UniqueId: {Name: "Living Room"}}, "Living Room"},
+		{"override of another device", map[string]deviceConfiguration.Override{"00:11:22:33:44:55:66:99": {Name: "Other"}}, "Test Sensor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestDevice(t, nil, config, Options{Overrides: tt.overrides})
+			if got := device.Accessory.Info.Name.Value(); got != tt.want {
+				t.Errorf("Name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessoryInfoPlaceholders(t *testing.T) {
+	// The sensor configuration doesn't report a manufacturer, model or firmware
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	reported := *config
+	reported.Manufacturer = "LUMI"
+	reported.Model = "lumi.weather"
+	reported.SwVersion = "20191205"
+
+	tests := []struct {
+		name      string
+		config    *deconz.Device
+		overrides map[string]deviceConfiguration.Override
+		want      [3]string
+	}{
+		{"missing", config, nil, [3]string{unknownInfo, unknownInfo, unknownInfo}},
+		{"reported", &reported, nil, [3]string{"LUMI", "lumi.weather", "20191205"}},
+		{
+			name:   "override of missing values",
+			config: config,
+			overrides: map[string]deviceConfiguration.Override{config.UniqueId: {
+				Manufacturer: "Aqara", Model: "WSDCGQ11LM",
+			}},
+			want: [3]string{"Aqara", "WSDCGQ11LM", unknownInfo},
+		},
+		{
+			name:      "override of reported values",
+			config:    &reported,
+			overrides: map[string]deviceConfiguration.Override{config.UniqueId: {Firmware: "1.0"}},
+			want:      [3]string{"LUMI", "lumi.weather", "1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newTestDevice(t, nil, tt.config, Options{Overrides: tt.overrides}).Accessory.Info
+			got := [3]string{info.Manufacturer.Value(), info.Model.Value(), info.FirmwareRevision.Value()}
+			if got != tt.want {
+				t.Errorf("manufacturer, model and firmware = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiSensorBecomesOneAccessory(t *testing.T) {
+	// A weather sensor reports its values as three subdevices of one device
+	config := &deconz.Device{
+		UniqueId: "00:15:8d:00:02:7a:3c:11",
+		Name:     "Weather",
+		Subdevices: []deconz.Subdevice{
+			{
+				Type:     deconz.TemperatureDevice,
+				UniqueId: "00:15:8d:00:02:7a:3c:11-01-0402",
+				State:    extendedMap(t, `{"temperature": {"value": 2150}}`),
+				Config:   extendedMap(t, `{"on": {"value": true}}`),
+			},
+			{
+				Type:     deconz.HumiditySensorDevice,
+				UniqueId: "00:15:8d:00:02:7a:3c:11-01-0405",
+				State:    extendedMap(t, `{"humidity": {"value": 4530}}`),
+				Config:   extendedMap(t, `{"on": {"value": true}}`),
+			},
+			{
+				Type:     deconz.PressureDevice,
+				UniqueId: "00:15:8d:00:02:7a:3c:11-01-0403",
+				State:    extendedMap(t, `{"pressure": {"value": 1008}}`),
+				Config:   extendedMap(t, `{"on": {"value": true}}`),
+			},
+		},
+	}
+
+	// The pressure is only exposed with the Eve characteristics
+	device := newTestDevice(t, nil, config, Options{EveCharacteristics: true})
+
+	if got := len(device.Services); got != 3 {
+		t.Fatalf("%d services, want 3", got)
+	}
+	temperature, ok := device.Services[config.Subdevices[0].UniqueId].(*TemperatureSensor)
+	if !ok {
+		t.Fatalf("the temperature is exposed as %T, want *TemperatureSensor", device.Services[config.Subdevices[0].UniqueId])
+	}
+	humidity, ok := device.Services[config.Subdevices[1].UniqueId].(*HumiditySensor)
+	if !ok {
+		t.Fatalf("the humidity is exposed as %T, want *HumiditySensor", device.Services[config.Subdevices[1].UniqueId])
+	}
+	pressure, ok := device.Services[config.Subdevices[2].UniqueId].(*PressureSensor)
+	if !ok {
+		t.Fatalf("the pressure is exposed as %T, want *PressureSensor", device.Services[config.Subdevices[2].UniqueId])
+	}
+
+	// The accessory has the information service and a service per subdevice
+	services := device.Accessory.Ss
+	if got := len(services); got != 4 {
+		t.Fatalf("the accessory has %d services, want 4", got)
+	}
+	for _, s := range []*service.S{temperature.S(), humidity.S(), pressure.S()} {
+		if !slices.Contains(services, s) {
+			t.Errorf("the service %s is missing in the accessory", s.Type)
+		}
+	}
+
+	// The first sensor is the primary service
+	if !temperature.S().Primary {
+		t.Error("the temperature sensor is not the primary service")
+	}
+	if humidity.S().Primary || pressure.S().Primary {
+		t.Error("more than one primary service")
+	}
+
+	if got := temperature.service.CurrentTemperature.Value(); got != 21.5 {
+		t.Errorf("CurrentTemperature = %v, want 21.5", got)
+	}
+	if got := humidity.service.CurrentRelativeHumidity.Value(); got != 45.3 {
+		t.Errorf("CurrentRelativeHumidity = %v, want 45.3", got)
+	}
+	if got := pressure.pressure.Value(); got != 1008 {
+		t.Errorf("air pressure = %v, want 1008", got)
+	}
+}
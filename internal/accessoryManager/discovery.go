@@ -0,0 +1,94 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"context"
+	"github.com/charmbracelet/log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Discover periodically fetches all devices from the deCONZ gateway and reports the devices
+// which were paired after the bridge was started. The accessories of a running bridge can't be
+// changed, so new devices are only exposed after a restart, but this tells the user right away
+// that a restart is needed. Each new device is reported once.
+// This blocks until the context is cancelled.
+//
+// Parameters:
+//   - ctx: Context for cancelling the discovery
+//   - interval: The interval between two discoveries
+//   - log: The logger for the new devices and failed discoveries
+func (am *AccessoryManager) Discover(ctx context.Context, interval time.Duration, log *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// The devices which were already known on startup or reported since
+	known := make(map[string]bool)
+	for id := range am.Devices {
+		known[id] = true
+	}
+	for _, device := range am.skipped {
+		known[device.UniqueId] = true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := am.discover(known, log); err != nil {
+				log.Warnf("failed to discover new devices: %v", err)
+			}
+		}
+	}
+}
+
+// discover fetches all devices once and reports the devices which are not known yet.
+//
+// Parameters:
+//   - known: The unique IDs of the known devices, the new devices are added
+//   - log: The logger for the new devices
+//
+// Returns:
+//   - error: Any error encountered while fetching the devices
+func (am *AccessoryManager) discover(known map[string]bool, log *log.Logger) error {
+	devices, err := am.client.GetAllDevices()
+	if err != nil {
+		return err
+	}
+
+	for _, config := range devices {
+		if known[config.UniqueId] {
+			continue
+		}
+		known[config.UniqueId] = true
+
+		// Devices which are filtered would not be exposed after a restart either
+		if !am.options.isDeviceIncluded(config) {
+			log.Debugf("new device %s (%s) is filtered by the include/exclude lists", config.Name, config.UniqueId)
+			continue
+		}
+
+		// The services are determined from the types, without creating the accessory
+		types := []string{}
+		for _, sub := range config.Subdevices {
+			if t := am.options.overrideType(config.UniqueId, sub.UniqueId); len(t) > 0 {
+				sub.Type = t
+			}
+			if isSupportedType(sub.Type) && !slices.Contains(types, string(sub.Type)) {
+				types = append(types, string(sub.Type))
+			}
+		}
+		if len(types) == 0 {
+			log.Infof("new device %s (%s, %s) found, but it has no supported services", config.Name, config.Model, config.UniqueId)
+			continue
+		}
+
+		log.Warnf("new device %s (%s, %s) with %s found, restart the bridge to expose it to HomeKit",
+			config.Name, config.Model, config.UniqueId, strings.Join(types, ", "))
+	}
+
+	return nil
+}
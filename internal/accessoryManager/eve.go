@@ -0,0 +1,53 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import "github.com/brutella/hap/characteristic"
+
+// Custom characteristic types used by the Eve app.
+// These characteristics are not part of the HomeKit specification and are ignored
+// by the Apple Home app, but they are displayed and graphed by Eve and similar apps.
+const (
+	// TypeEveVoltage is the Eve characteristic for the voltage in V
+	TypeEveVoltage = "E863F10A-079E-48FF-8F27-9C2605A29F52"
+
+	// TypeEveTotalConsumption is the Eve characteristic for the total consumption in kWh
+	TypeEveTotalConsumption = "E863F10C-079E-48FF-8F27-9C2605A29F52"
+
+	// TypeEvePower is the Eve characteristic for the current power in W
+	TypeEvePower = "E863F10D-079E-48FF-8F27-9C2605A29F52"
+
+	// TypeEveCurrent is the Eve characteristic for the electric current in A
+	TypeEveCurrent = "E863F126-079E-48FF-8F27-9C2605A29F52"
+
+	// TypeEveAirPressure is the Eve characteristic for the air pressure in hPa
+	TypeEveAirPressure = "E863F10F-079E-48FF-8F27-9C2605A29F52"
+
+	// TypeEveAirPressureSensor is the Eve service for air pressure sensors
+	TypeEveAirPressureSensor = "E863F00A-079E-48FF-8F27-9C2605A29F52"
+
+	// TypePowerMeterService is the custom service for standalone energy meters, which is
+	// used by other HomeKit bridges as well, so that Eve shows the power characteristics
+	TypePowerMeterService = "00000001-0000-1777-8000-775D67EC4377"
+)
+
+// newEveCharacteristic creates a new read-only float characteristic with a custom type.
+//
+// Parameters:
+//   - typ: The UUID of the custom characteristic
+//   - description: A human-readable description of the characteristic
+//
+// Returns:
+//   - *characteristic.Float: A pointer to the initialized characteristic
+func newEveCharacteristic(typ string, description string) *characteristic.Float {
+	c := characteristic.NewFloat(typ)
+	c.Format = characteristic.FormatFloat
+	c.Permissions = []string{characteristic.PermissionRead, characteristic.PermissionEvents}
+	c.Description = description
+	c.SetMinValue(0)
+	c.SetMaxValue(1_000_000)
+	c.SetStepValue(0.01)
+	c.SetValue(0)
+
+	return c
+}
@@ -0,0 +1,167 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/helper"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"math"
+	"net/http"
+)
+
+// Fan represents a Zigbee fan controller in HomeKit.
+// It implements the DeviceService interface. deCONZ doesn't have a distinct fan type,
+// so fan controllers are reported as lights, whose on/off state controls the fan and
+// whose brightness controls the rotation speed.
+type Fan struct {
+	// ID is the unique identifier of the fan (from deCONZ)
+	ID string
+
+	// RotationSpeed is the HomeKit characteristic for the rotation speed
+	RotationSpeed *characteristic.RotationSpeed
+
+	// feedback ignores the state echoed by deCONZ after a change from HomeKit
+	feedback *feedbackGuard
+
+	// commands is the queue of commands for the deCONZ gateway
+	commands commandQueue
+
+	// rotationSpeedDebouncer coalesces rapid rotation speed changes from HomeKit
+	rotationSpeedDebouncer *debouncer[float64]
+
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit fan service
+	service *service.FanV2
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (fan *Fan) S() *service.S {
+	return fan.service.S
+}
+
+// SetActive turns the fan on or off.
+// This method is called when the Active characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: The Active value (characteristic.ActiveActive or characteristic.ActiveInactive)
+//   - previous: The previous Active value, which is restored if the command fails
+func (fan *Fan) SetActive(v, previous int) {
+	on := v == characteristic.ActiveActive
+	fan.device.log.Infof("set fan %s", onOffStr[on])
+
+	// Send the command to the deCONZ gateway
+	fan.feedback.updateChange()
+	fan.commands.send(fan.device.log, "set fan "+onOffStr[on], func() error {
+		return fan.device.client.SetLightOn(fan.ID, on)
+	}, func() {
+		_ = fan.service.Active.SetValue(previous)
+	})
+}
+
+// SetRotationSpeed sets the rotation speed of the fan.
+// This method is called when the RotationSpeed characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: The rotation speed percentage (0-100)
+//   - previous: The previous rotation speed, which is restored if the command fails
+func (fan *Fan) SetRotationSpeed(v, previous float64) {
+	// Suppress the echoed events while the slider is dragged
+	fan.feedback.updateChange()
+
+	// Only send the latest value within the debounce window to the deCONZ gateway
+	fan.rotationSpeedDebouncer.call(v, previous, func(previous float64) {
+		fan.device.log.Infof("set rotation speed to %.0f%%", v)
+
+		// The rotation speed is sent as the brightness of the light
+		fan.feedback.updateChange()
+		fan.commands.send(fan.device.log, "set rotation speed", func() error {
+			return fan.device.client.SetLightBrightness(fan.ID, int(math.Round(v)), helper.DefaultMinBrightness)
+		}, func() {
+			// The gateway may have applied the command, so the next value is sent in any case
+			fan.rotationSpeedDebouncer.force()
+			fan.RotationSpeed.SetValue(previous)
+		})
+	})
+}
+
+// UpdateState updates the fan's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (fan *Fan) UpdateState(state deconz.MapObject) {
+	// Ignore updates for a short period after a user-initiated change
+	// to prevent feedback loops
+	if fan.feedback.suppressed() {
+		return
+	}
+
+	// Update the Active characteristic if the state contains an "on" value
+	if state.Has("on") {
+		_ = fan.service.Active.SetValue(boolToInt[state.ValueToBool("on")])
+	}
+
+	// Update the RotationSpeed characteristic if the state contains a "bri" value
+	if state.Has("bri") {
+		fan.RotationSpeed.SetValue(float64(helper.BrightnessToPercent(state.ValueToInt("bri"), helper.DefaultMinBrightness)))
+	}
+}
+
+// UpdateConfig updates the fan's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Fans don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for fans)
+func (fan *Fan) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// NewFan creates a new fan service.
+// This is used for fan controllers which are reported as lights by deCONZ and
+// whose model is configured with the "fan" service in the device configuration.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewFan(config *deconz.Subdevice) error {
+	fan := new(Fan)
+	fan.ID = config.UniqueId
+	fan.device = device
+	fan.feedback = newFeedbackGuard(device.options.feedbackWindow(device.ID, config.UniqueId))
+	fan.commands = newCommandQueue()
+	fan.rotationSpeedDebouncer = newDebouncer[float64](debounceDelay)
+
+	// Create a new HomeKit fan service with the rotation speed characteristic
+	fan.service = service.NewFanV2()
+	fan.service.Active.OnValueUpdate(func(v, previous int, r *http.Request) {
+		if r != nil {
+			fan.SetActive(v, previous)
+		}
+	})
+
+	fan.RotationSpeed = characteristic.NewRotationSpeed()
+	fan.RotationSpeed.OnValueUpdate(func(v, previous float64, r *http.Request) {
+		if r != nil {
+			fan.SetRotationSpeed(v, previous)
+		}
+	})
+	fan.service.AddC(fan.RotationSpeed.C)
+
+	// Initialize the fan state from the current deCONZ state
+	fan.UpdateState(config.State)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, fan)
+	return nil
+}
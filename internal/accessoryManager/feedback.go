@@ -0,0 +1,57 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultFeedbackWindow is the default time state updates from deCONZ are ignored
+// after a change from HomeKit.
+const DefaultFeedbackWindow = time.Second
+
+// feedbackGuard prevents feedback loops between HomeKit and deCONZ.
+// After a change from HomeKit, deCONZ echoes the (possibly outdated) state of the device.
+// These updates are ignored for a short window, so they don't revert the value shown in HomeKit.
+// It is safe for concurrent use.
+type feedbackGuard struct {
+	// window is the time updates are ignored after a change (0 disables the guard)
+	window time.Duration
+
+	// mu guards the time of the last change
+	mu sync.Mutex
+
+	// lastChange is the time of the last change from HomeKit
+	lastChange time.Time
+}
+
+// newFeedbackGuard creates a new feedback guard.
+//
+// Parameters:
+//   - window: The time updates are ignored after a change
+//
+// Returns:
+//   - *feedbackGuard: A pointer to the initialized feedback guard
+func newFeedbackGuard(window time.Duration) *feedbackGuard {
+	return &feedbackGuard{window: window}
+}
+
+// updateChange records the current time as the last change time.
+// This is called whenever a change from HomeKit is sent to deCONZ.
+func (guard *feedbackGuard) updateChange() {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	guard.lastChange = time.Now()
+}
+
+// suppressed reports whether state updates from deCONZ should be ignored,
+// because the last change from HomeKit is within the feedback window.
+//
+// Returns:
+//   - bool: True if updates should be ignored
+func (guard *feedbackGuard) suppressed() bool {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	return time.Since(guard.lastChange) < guard.window
+}
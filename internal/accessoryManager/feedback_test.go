@@ -0,0 +1,84 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"testing"
+	"time"
+)
+
+func TestFeedbackGuard(t *testing.T) {
+	guard := newFeedbackGuard(100 * time.Millisecond)
+	if guard.suppressed() {
+		t.Fatal("suppressed() before any change = true, want false")
+	}
+
+	guard.updateChange()
+	if !guard.suppressed() {
+		t.Error("suppressed() within the window = false, want true")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if guard.suppressed() {
+		t.Error("suppressed() after the window = true, want false")
+	}
+
+	// A window of 0 disables the suppression
+	disabled := newFeedbackGuard(0)
+	disabled.updateChange()
+	if disabled.suppressed() {
+		t.Error("suppressed() with a window of 0 = true, want false")
+	}
+}
+
+func TestLightIgnoresEchoWithinFeedbackWindow(t *testing.T) {
+	light := newTestLight(t)
+	light.feedback = newFeedbackGuard(100 * time.Millisecond)
+
+	// HomeKit turned the light on, the echo of the previous state is ignored
+	light.On.SetValue(true)
+	light.feedback.updateChange()
+	light.UpdateState(deconz.ObjectMap{"on": false})
+	if !light.On.Value() {
+		t.Error("the update within the feedback window was applied")
+	}
+
+	// Changes after the window are applied
+	time.Sleep(150 * time.Millisecond)
+	light.UpdateState(deconz.ObjectMap{"on": false})
+	if light.On.Value() {
+		t.Error("the update after the feedback window was ignored")
+	}
+}
+
+func TestFeedbackWindowOverrides(t *testing.T) {
+	window := func(d time.Duration) *deviceConfiguration.Duration {
+		duration := deviceConfiguration.Duration(d)
+		return &duration
+	}
+
+	options := Options{
+		FeedbackWindow: time.Second,
+		Overrides: map[string]deviceConfiguration.Override{
+			"00:11:22:33:44:55:66:77":    {FeedbackWindow: window(2 * time.Second)},
+			"00:11:22:33:44:55:66:77-0b": {FeedbackWindow: window(0)},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		deviceId    string
+		subdeviceId string
+		want        time.Duration
+	}{
+		{"global setting", "00:11:22:33:44:55:66:88", "00:11:22:33:44:55:66:88-01", time.Second},
+		{"device override", "00:11:22:33:44:55:66:77", "00:11:22:33:44:55:66:77-01", 2 * time.Second},
+		{"subdevice override", "00:11:22:33:44:55:66:77", "00:11:22:33:44:55:66:77-0b", 0},
+	}
+
+	for _, tt := range tests {
+		if got := options.feedbackWindow(tt.deviceId, tt.subdeviceId); got != tt.want {
+			t.Errorf("%s: feedbackWindow() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"errors"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// garageDoorPulse is the time the relay is switched on to trigger the door motor
+	garageDoorPulse = time.Second
+
+	// garageDoorTimeout is the time the door may take to open or close
+	// If the contact sensor doesn't report the new position in time, the door is reported as stopped
+	garageDoorTimeout = 30 * time.Second
+)
+
+// GarageDoor represents a garage door opener in HomeKit.
+// It implements the DeviceService interface and combines an on/off output (the relay
+// triggering the door motor) with a contact sensor reporting the door position.
+// Like the button of a wall-mounted opener, the relay is switched on briefly to
+// start or stop the door motor.
+type GarageDoor struct {
+	// ID is the unique identifier of the relay (from deCONZ)
+	ID string
+
+	// sensorId is the unique identifier of the contact sensor (from deCONZ)
+	sensorId string
+
+	// commands is the queue of commands for the deCONZ gateway
+	commands commandQueue
+
+	// timer reports the door as stopped if it doesn't reach the target position in time
+	timer *time.Timer
+
+	// mu guards the door state against concurrent updates
+	mu sync.Mutex
+
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit garage door opener service
+	service *service.GarageDoorOpener
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (door *GarageDoor) S() *service.S {
+	return door.service.S
+}
+
+// SetTargetDoorState opens or closes the door.
+// This method is called when the TargetDoorState characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - target: The target state (characteristic.TargetDoorStateOpen or characteristic.TargetDoorStateClosed)
+func (door *GarageDoor) SetTargetDoorState(target int) {
+	door.mu.Lock()
+	defer door.mu.Unlock()
+
+	// Nothing to do if the door is already in the target position
+	current := door.service.CurrentDoorState.Value()
+	if current == target {
+		return
+	}
+
+	if target == characteristic.TargetDoorStateOpen {
+		door.device.log.Info("opening")
+		_ = door.service.CurrentDoorState.SetValue(characteristic.CurrentDoorStateOpening)
+	} else {
+		door.device.log.Info("closing")
+		_ = door.service.CurrentDoorState.SetValue(characteristic.CurrentDoorStateClosing)
+	}
+
+	// Trigger the door motor by switching the relay on and off again
+	door.commands.send(door.device.log, "trigger garage door", func() error {
+		if err := door.device.client.SetLightOn(door.ID, true); err != nil {
+			return err
+		}
+		time.Sleep(garageDoorPulse)
+		return door.device.client.SetLightOn(door.ID, false)
+	}, func() {
+		door.mu.Lock()
+		defer door.mu.Unlock()
+		door.stopTimer()
+		_ = door.service.CurrentDoorState.SetValue(current)
+		_ = door.service.TargetDoorState.SetValue(targetForCurrent(current))
+	})
+
+	// Report the door as stopped if the sensor doesn't confirm the position in time
+	door.stopTimer()
+	door.timer = time.AfterFunc(garageDoorTimeout, func() {
+		door.mu.Lock()
+		defer door.mu.Unlock()
+		door.timer = nil
+		door.device.log.Warn("door didn't reach the target position in time")
+		_ = door.service.CurrentDoorState.SetValue(characteristic.CurrentDoorStateStopped)
+	})
+}
+
+// stopTimer stops the timeout of a door movement.
+// The caller must hold the mutex.
+func (door *GarageDoor) stopTimer() {
+	if door.timer != nil {
+		door.timer.Stop()
+		door.timer = nil
+	}
+}
+
+// targetForCurrent returns the target state matching a current door state.
+//
+// Parameters:
+//   - current: The current door state
+//
+// Returns:
+//   - int: The matching target door state
+func targetForCurrent(current int) int {
+	if current == characteristic.CurrentDoorStateClosed || current == characteristic.CurrentDoorStateClosing {
+		return characteristic.TargetDoorStateClosed
+	}
+	return characteristic.TargetDoorStateOpen
+}
+
+// UpdateState updates the door position based on updates of the contact sensor.
+// This method implements the DeviceService interface.
+// The door is registered as an observer of the contact sensor, updates of the relay are ignored.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (door *GarageDoor) UpdateState(state deconz.MapObject) {
+	if !state.Has("open") {
+		return
+	}
+
+	door.mu.Lock()
+	defer door.mu.Unlock()
+
+	open := state.ValueToBool("open")
+	current := door.service.CurrentDoorState.Value()
+
+	// The sensor reports the door as open as soon as it leaves the closed position,
+	// so a closing door is only finished once the sensor reports it as closed
+	if current == characteristic.CurrentDoorStateClosing && open {
+		return
+	}
+	door.stopTimer()
+
+	if open {
+		if current != characteristic.CurrentDoorStateOpen {
+			door.device.log.Info("open")
+		}
+		_ = door.service.CurrentDoorState.SetValue(characteristic.CurrentDoorStateOpen)
+		_ = door.service.TargetDoorState.SetValue(characteristic.TargetDoorStateOpen)
+	} else {
+		if current != characteristic.CurrentDoorStateClosed {
+			door.device.log.Info("closed")
+		}
+		_ = door.service.CurrentDoorState.SetValue(characteristic.CurrentDoorStateClosed)
+		_ = door.service.TargetDoorState.SetValue(characteristic.TargetDoorStateClosed)
+	}
+}
+
+// UpdateConfig updates the door's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Garage doors don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for garage doors)
+func (door *GarageDoor) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// NewGarageDoor creates a new garage door opener service.
+// This is used for on/off outputs configured with the "garageDoor" service in the
+// overrides, which also name the contact sensor reporting the door position.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if no contact sensor is configured or its state can't be retrieved
+func (device *Device) NewGarageDoor(config *deconz.Subdevice) error {
+	door := new(GarageDoor)
+	door.ID = config.UniqueId
+	door.sensorId = device.options.overrideService(device.ID, config.UniqueId).ContactSensor
+	door.device = device
+
+	if len(door.sensorId) == 0 {
+		return errors.New("no contact sensor configured for the garage door")
+	}
+
+	// Get the current door position from the contact sensor
+	sensor, err := fetchWithRetry(device.client.Context(), device.log, door.sensorId, device.client.GetSensor)
+	if err != nil {
+		return err
+	}
+
+	// Start sending the commands only once the door is known to be valid,
+	// so that a misconfigured door doesn't leave a goroutine behind
+	door.commands = newCommandQueue()
+
+	// Create a new HomeKit garage door opener service
+	door.service = service.NewGarageDoorOpener()
+	door.service.TargetDoorState.OnValueUpdate(func(v, _ int, r *http.Request) {
+		if r != nil {
+			door.SetTargetDoorState(v)
+		}
+	})
+
+	// Initialize the door position from the current state of the contact sensor
+	door.UpdateState(sensor.State)
+
+	// Register the service with the device and observe the contact sensor
+	device.addDeviceService(config.UniqueId, door)
+	device.observers[door.sensorId] = door
+	return nil
+}
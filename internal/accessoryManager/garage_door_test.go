@@ -0,0 +1,75 @@
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/deconz/deconztest"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/charmbracelet/log"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// garageDoorRelay is the relay of a garage door, which is configured with the contact sensor in the overrides.
+var garageDoorRelay = deconz.Subdevice{
+	Type:     deconz.OnOffOutputDevice,
+	UniqueId: "00:11:22:33:44:55:66:99-01",
+}
+
+func TestGarageDoorWithoutContactSensor(t *testing.T) {
+	device := &Device{
+		ID:        "00:11:22:33:44:55:66:99",
+		Accessory: accessory.New(accessory.Info{Name: "Garage"}, accessory.TypeGarageDoorOpener),
+		Services:  make(map[string]DeviceService),
+		observers: make(map[string]DeviceService),
+		log:       log.New(io.Discard),
+	}
+
+	// A failed constructor must not leave the goroutine of the command queue behind
+	before := runtime.NumGoroutine()
+	for range 20 {
+		if err := device.NewGarageDoor(&garageDoorRelay); err == nil {
+			t.Fatal("NewGarageDoor() without a contact sensor succeeded")
+		}
+	}
+	if leaked := runtime.NumGoroutine() - before; leaked >= 20 {
+		t.Errorf("NewGarageDoor() leaked %d goroutines", leaked)
+	}
+	if len(device.Services) > 0 {
+		t.Errorf("Services = %v, want none", device.Services)
+	}
+}
+
+func TestGarageDoorFollowsContactSensor(t *testing.T) {
+	gateway := deconztest.NewGateway(t)
+	gateway.AddSensor(t, "7", `{"type": "ZHAOpenClose", "uniqueid": "00:11:22:33:44:55:66:aa-01-0006", "state": {"open": false}}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+
+	device := newTestDevice(t, client, &deconz.Device{
+		UniqueId:   "00:11:22:33:44:55:66:99",
+		Name:       "Garage",
+		Subdevices: []deconz.Subdevice{garageDoorRelay},
+	}, Options{Overrides: map[string]deviceConfiguration.Override{
+		garageDoorRelay.UniqueId: {Service: deviceConfiguration.ServiceGarageDoor, ContactSensor: "00:11:22:33:44:55:66:aa-01-0006"},
+	}})
+
+	door, ok := device.Services[garageDoorRelay.UniqueId].(*GarageDoor)
+	if !ok {
+		t.Fatalf("the relay is exposed as %T, want *GarageDoor", device.Services[garageDoorRelay.UniqueId])
+	}
+	if got := door.service.CurrentDoorState.Value(); got != characteristic.CurrentDoorStateClosed {
+		t.Errorf("CurrentDoorState = %d, want closed", got)
+	}
+
+	// The contact sensor is observed by the door
+	device.observers["00:11:22:33:44:55:66:aa-01-0006"].UpdateState(deconz.ObjectMap{"open": true})
+	if got := door.service.CurrentDoorState.Value(); got != characteristic.CurrentDoorStateOpen {
+		t.Errorf("CurrentDoorState = %d, want open", got)
+	}
+}
@@ -0,0 +1,242 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/helper"
+	"errors"
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"github.com/charmbracelet/log"
+	"os"
+	"time"
+)
+
+// Group represents a deCONZ light group in HomeKit.
+// It implements the DeviceService interface and exposes the group as a single
+// lightbulb accessory, so that all lights of the group can be controlled at once.
+type Group struct {
+	// ID is the identifier of the group (from deCONZ)
+	ID string
+
+	// Accessory is the HomeKit accessory representing this group
+	Accessory *accessory.A
+
+	// On is the HomeKit characteristic for the on/off state
+	On *characteristic.On
+
+	// Brightness is the HomeKit characteristic for brightness level
+	Brightness *characteristic.Brightness
+
+	// ColorTemperature is the HomeKit characteristic for color temperature
+	ColorTemperature *characteristic.ColorTemperature
+
+	// feedback ignores the state echoed by deCONZ after a change from HomeKit
+	feedback *feedbackGuard
+
+	// client is the deCONZ API client for communicating with the gateway
+	client *deconz.ApiClient
+
+	// log is the logger for this group
+	log *log.Logger
+
+	// service is the HomeKit lightbulb service for this group
+	service *service.S
+}
+
+// NewGroup creates a new Group from a deCONZ group configuration.
+// The characteristics are enabled based on the fields present in the group's last action.
+//
+// Parameters:
+//   - client: A pointer to the deCONZ API client for communication with the gateway
+//   - config: A pointer to the deCONZ group configuration
+//   - options: The settings for exposing the group to HomeKit
+//
+// Returns:
+//   - *Group: A pointer to the initialized Group
+//   - error: An error if the group has no lights
+func NewGroup(client *deconz.ApiClient, config *deconz.Group, options Options) (*Group, error) {
+	// Groups without lights cannot be controlled
+	if len(config.Lights) == 0 {
+		return nil, errors.New("group has no lights")
+	}
+
+	g := new(Group)
+	g.ID = config.ID
+	g.client = client
+	g.feedback = newFeedbackGuard(options.FeedbackWindow)
+
+	// Create a new HomeKit accessory with information from the deCONZ group
+	g.Accessory = accessory.New(accessory.Info{
+		Name:         config.Name,
+		Manufacturer: "deCONZ",
+		Model:        config.Type,
+		SerialNumber: "group-" + config.ID,
+	}, accessory.TypeLightbulb)
+	g.Accessory.Id = groupIdToHomeKitId(config.ID)
+
+	// Initialize a logger for this group
+	g.log = log.NewWithOptions(os.Stderr, log.Options{
+		ReportTimestamp: true,
+		TimeFormat:      time.DateTime,
+		Prefix:          config.Name,
+	})
+	g.log.Infof("discovered group (%s)", config.ID)
+
+	// Create the lightbulb service with the characteristics supported by the group
+	g.service = service.New(service.TypeLightbulb)
+	g.On = characteristic.NewOn()
+	g.On.OnValueRemoteUpdate(g.SetOn)
+	g.service.AddC(g.On.C)
+
+	if config.Action.Brightness != nil {
+		g.Brightness = characteristic.NewBrightness()
+		g.Brightness.OnValueRemoteUpdate(g.SetBrightness)
+		g.service.AddC(g.Brightness.C)
+	}
+
+	if config.Action.ColorTemperature != nil {
+		g.ColorTemperature = characteristic.NewColorTemperature()
+		g.ColorTemperature.OnValueRemoteUpdate(g.SetColorTemperature)
+		g.service.AddC(g.ColorTemperature.C)
+	}
+
+	g.Accessory.AddS(g.service)
+
+	// Initialize the group state from the current deCONZ state
+	if config.State.AnyOn != nil {
+		g.On.SetValue(*config.State.AnyOn)
+	}
+	if config.Action.Brightness != nil {
+		_ = g.Brightness.SetValue(helper.BrightnessToPercent(int(*config.Action.Brightness), helper.DefaultMinBrightness))
+	}
+	if config.Action.ColorTemperature != nil {
+		_ = g.ColorTemperature.SetValue(*config.Action.ColorTemperature)
+	}
+
+	return g, nil
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (group *Group) S() *service.S {
+	return group.service
+}
+
+// SetName updates the name of the group after it was renamed in deCONZ.
+// HomeKit controllers cache accessory names, so the new name may only be shown
+// after the controller refreshes the accessory.
+//
+// Parameters:
+//   - name: The new name of the group
+func (group *Group) SetName(name string) {
+	if group.Accessory.Info.Name.Value() == name {
+		return
+	}
+
+	group.log.Infof("renamed to %s", name)
+	group.Accessory.Info.Name.SetValue(name)
+}
+
+// SetOn turns all lights of the group on or off.
+// This method is called when the On characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - on: A boolean indicating whether to turn the group on (true) or off (false)
+func (group *Group) SetOn(on bool) {
+	group.log.Infof("set %s", onOffStr[on])
+
+	// Send the command to the deCONZ gateway
+	if err := group.client.SetGroupOn(group.ID, on); err != nil {
+		group.log.Errorf("failed to set group %s: %+v", onOffStr[on], err)
+	}
+	group.feedback.updateChange()
+}
+
+// SetBrightness sets the brightness of all lights of the group.
+// This method is called when the Brightness characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: An integer representing the brightness percentage (0-100)
+func (group *Group) SetBrightness(v int) {
+	group.log.Infof("set brightness to %d%%", v)
+
+	// Send the command to the deCONZ gateway
+	if err := group.client.SetGroupBrightness(group.ID, v); err != nil {
+		group.log.Errorf("failed to set brightness: %+v", err)
+	}
+	group.feedback.updateChange()
+}
+
+// SetColorTemperature sets the color temperature of all lights of the group.
+// This method is called when the ColorTemperature characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: An integer representing the color temperature in mireds
+func (group *Group) SetColorTemperature(v int) {
+	// Convert mireds to Kelvin for logging (mireds = 1,000,000/Kelvin)
+	k := 1_000_000.0 / float64(v)
+	group.log.Infof("set color temperature to %.1f K (%d)", k, v)
+
+	// Send the command to the deCONZ gateway
+	if err := group.client.SetGroupColorTemperature(group.ID, v); err != nil {
+		group.log.Errorf("failed to set color temperature: %+v", err)
+	}
+	group.feedback.updateChange()
+}
+
+// UpdateState updates the group's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Group events carry the aggregated "any_on"/"all_on" state, while the
+// "on", "bri" and "ct" values are taken from the group action if present.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (group *Group) UpdateState(state deconz.MapObject) {
+	// Ignore updates for a short period after a user-initiated change
+	// to prevent feedback loops
+	if group.feedback.suppressed() {
+		return
+	}
+
+	// The values are collected first and applied together, like for lights
+	batch := characteristicBatch{}
+
+	// Update the Brightness characteristic if the state contains a "bri" value
+	if state.Has("bri") && group.Brightness != nil {
+		brightness := helper.BrightnessToPercent(state.ValueToInt("bri"), helper.DefaultMinBrightness)
+		batch.add(func() { _ = group.Brightness.SetValue(brightness) })
+	}
+
+	// Update the ColorTemperature characteristic if the state contains a "ct" value
+	if state.Has("ct") && group.ColorTemperature != nil {
+		ct := state.ValueToInt("ct")
+		batch.add(func() { _ = group.ColorTemperature.SetValue(ct) })
+	}
+
+	// The group is shown as on as long as any of its lights is on
+	if state.Has("any_on") {
+		on := state.ValueToBool("any_on")
+		batch.add(func() { group.On.SetValue(on) })
+	} else if state.Has("on") {
+		on := state.ValueToBool("on")
+		batch.add(func() { group.On.SetValue(on) })
+	}
+
+	batch.apply()
+}
+
+// UpdateConfig updates the group's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Groups don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for groups)
+func (group *Group) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
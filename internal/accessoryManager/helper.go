@@ -3,16 +3,23 @@
 package accessoryManager
 
 import (
+	"context"
+	"github.com/charmbracelet/log"
+	"hash/fnv"
 	"math/big"
 	"strings"
+	"time"
 )
 
 // uniqueIdToHomeKitId converts a deCONZ unique ID (which is typically a MAC address or similar
 // identifier in hexadecimal format with colons or hyphens) to a uint64 that can be used as
 // a HomeKit accessory ID.
 //
-// The function removes colons and hyphens from the ID, interprets the resulting string as
-// a hexadecimal number, and converts it to a uint64.
+// The function removes colons and hyphens from the ID and interprets the resulting string as
+// a hexadecimal number. IDs with up to 16 hex digits (e.g. plain MAC addresses) fit into a uint64
+// and are converted directly, so the IDs of existing accessories don't change. Longer IDs
+// (e.g. "00:11:22:33:44:55:66:77-01-1000") would be truncated and could collide, so they are
+// derived from a hash of the full unique ID instead.
 //
 // Parameters:
 //   - id: The deCONZ unique ID to convert
@@ -26,10 +33,67 @@ func uniqueIdToHomeKitId(id string) uint64 {
 
 	// Convert the hexadecimal string to a big integer
 	n := new(big.Int)
-	n.SetString(numberStr, 16)
+	if _, ok := n.SetString(numberStr, 16); ok && n.IsUint64() {
+		return n.Uint64()
+	}
 
-	// Return the uint64 representation
-	return n.Uint64()
+	// Hash IDs which don't fit into a uint64 (or aren't hexadecimal)
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// fetchAttempts is the number of attempts to fetch the details of a subdevice from the gateway.
+const fetchAttempts = 3
+
+// fetchRetryDelay is the delay before the first retry, it doubles with every further retry.
+const fetchRetryDelay = 500 * time.Millisecond
+
+// fetchWithRetry fetches the details of a subdevice from the deCONZ gateway and retries
+// failed requests a few times, so a short hiccup of the gateway doesn't drop the subdevice.
+//
+// Parameters:
+//   - ctx: The context, the retries are cancelled when it is done (e.g. on shutdown)
+//   - log: The logger of the device
+//   - id: The unique ID of the subdevice (for logging)
+//   - fetch: The function fetching the details from the gateway
+//
+// Returns:
+//   - *T: A pointer to the fetched details
+//   - error: The error of the last attempt if all attempts failed, or the error of the context
+func fetchWithRetry[T any](ctx context.Context, log *log.Logger, id string, fetch func(id string) (*T, error)) (*T, error) {
+	delay := fetchRetryDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fetch(id)
+		if err == nil || attempt == fetchAttempts {
+			return result, err
+		}
+
+		log.Warnf("failed to fetch %s (attempt %d/%d), retrying in %s: %v", id, attempt, fetchAttempts, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// groupIdToHomeKitId converts a deCONZ group identifier to a uint64 that can be used as
+// a HomeKit accessory ID.
+//
+// Group identifiers are small numbers which would collide with the bridge and device IDs,
+// so the ID is derived from a hash of the prefixed group identifier instead.
+//
+// Parameters:
+//   - id: The deCONZ group identifier to convert
+//
+// Returns:
+//   - uint64: The converted HomeKit accessory ID
+func groupIdToHomeKitId(id string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("group-" + id))
+	return h.Sum64()
 }
 
 // onOffStr is a map that converts boolean values to "on" or "off" strings.
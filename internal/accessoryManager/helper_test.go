@@ -0,0 +1,108 @@
+package accessoryManager
+
+import (
+	"context"
+	"errors"
+	"github.com/charmbracelet/log"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUniqueIdToHomeKitIdKeepsShortIds(t *testing.T) {
+	// IDs which fit into a uint64 keep the IDs of existing accessories
+	tests := []struct {
+		id   string
+		want uint64
+	}{
+		{"00:11:22:33:44:55:66:77", 0x0011223344556677},
+		{"ff:ff:ff:ff:ff:ff:ff:ff", 0xffffffffffffffff},
+		{"00:21:2e:ff:ff:00:00:01", 0x00212effff000001},
+		{"12-34", 0x1234},
+	}
+
+	for _, tt := range tests {
+		if got := uniqueIdToHomeKitId(tt.id); got != tt.want {
+			t.Errorf("uniqueIdToHomeKitId(%q) = %#x, want %#x", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestUniqueIdToHomeKitIdLongIdsDontCollide(t *testing.T) {
+	// The IDs share the low 64 bits, which a truncation to uint64 would keep
+	ids := []string{
+		"00:11:22:33:44:55:66:77-01-1000",
+		"00:11:22:33:44:55:66:78-01-1000",
+		"01:11:22:33:44:55:66:77-01-1000",
+		"00:11:22:33:44:55:66:77-02-1000",
+		"00:11:22:33:44:55:66:77-01-0006",
+		"00:11:22:33:44:55:66:77",
+	}
+
+	seen := map[uint64]string{}
+	for _, id := range ids {
+		hkId := uniqueIdToHomeKitId(id)
+		if other, ok := seen[hkId]; ok {
+			t.Errorf("%q and %q both map to %#x", id, other, hkId)
+		}
+		seen[hkId] = id
+	}
+}
+
+func TestUniqueIdToHomeKitIdIsStable(t *testing.T) {
+	// The IDs must not change between runs, otherwise HomeKit would see new accessories
+	// The expected values are the FNV-1a hashes of the unique IDs
+	tests := []struct {
+		id   string
+		want uint64
+	}{
+		{"00:11:22:33:44:55:66:77-01-1000", 0xdf04de99b4b3f93b},
+		{"not hexadecimal", 0x8762a13b8d7e4149},
+	}
+
+	for _, tt := range tests {
+		if got := uniqueIdToHomeKitId(tt.id); got != tt.want {
+			t.Errorf("uniqueIdToHomeKitId(%q) = %#x, want %#x", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	calls := 0
+	fetch := func(id string) (*string, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("gateway hiccup")
+		}
+		return &id, nil
+	}
+
+	result, err := fetchWithRetry(context.Background(), log.New(io.Discard), "00:11-01", fetch)
+	if err != nil || *result != "00:11-01" {
+		t.Fatalf("fetchWithRetry() = %v, %v, want the result of the second attempt", result, err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestFetchWithRetryIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	fetch := func(string) (*string, error) {
+		calls++
+		cancel()
+		return nil, errors.New("gateway unreachable")
+	}
+
+	start := time.Now()
+	if _, err := fetchWithRetry(ctx, log.New(io.Discard), "00:11-01", fetch); !errors.Is(err, context.Canceled) {
+		t.Errorf("fetchWithRetry() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed >= fetchRetryDelay {
+		t.Errorf("fetchWithRetry() returned after %v, want before the first retry", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
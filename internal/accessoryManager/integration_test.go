@@ -0,0 +1,178 @@
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/deconz/deconztest"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// plugDevice is an on/off plug as reported by /devices/{uniqueid}.
+const plugDevice = `{
+	"uniqueid": "00:11:22:33:44:55:66:88",
+	"manufacturername": "IKEA of Sweden",
+	"modelid": "TRADFRI control outlet",
+	"name": "Plug",
+	"swversion": "2.3.089",
+	"lastseen": "2024-05-01T10:00Z",
+	"subdevices": [{
+		"type": "On/Off plug-in unit",
+		"uniqueid": "00:11:22:33:44:55:66:88-01",
+		"config": {},
+		"state": {
+			"on": {"lastupdated": "2024-05-01T10:00:00.000", "value": false},
+			"reachable": {"lastupdated": "2024-05-01T10:00:00.000", "value": true}
+		}
+	}]
+}`
+
+// plugLight is the light of the plug as reported by /lights/{id}.
+const plugLight = `{
+	"etag": "0123",
+	"hascolor": false,
+	"manufacturername": "IKEA of Sweden",
+	"modelid": "TRADFRI control outlet",
+	"name": "Plug",
+	"state": {"alert": "none", "on": false, "reachable": true},
+	"swversion": "2.3.089",
+	"type": "On/Off plug-in unit",
+	"uniqueid": "00:11:22:33:44:55:66:88-01"
+}`
+
+// testLogger discards all messages of the event client.
+type testLogger struct{}
+
+func (testLogger) Debugf(string, ...interface{}) {}
+func (testLogger) Infof(string, ...interface{})  {}
+func (testLogger) Warnf(string, ...interface{})  {}
+func (testLogger) Errorf(string, ...interface{}) {}
+
+// newTestGateway starts a fake gateway with the plug and an accessory manager
+// connected to it like in main, with the events of the WebSocket processed by the manager.
+func newTestGateway(t *testing.T) (*deconztest.Gateway, *AccessoryManager) {
+	t.Helper()
+
+	gateway := deconztest.NewGateway(t)
+	gateway.AddDevice(t, plugDevice)
+	gateway.AddLight(t, "1", plugLight)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	api := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+	api.SetLogger(testLogger{})
+	devices, err := api.GetAllDevices()
+	if err != nil {
+		t.Fatalf("GetAllDevices() error = %v", err)
+	}
+	am := NewAccessoryManager(api, devices, Options{})
+
+	events, err := deconz.NewEventClient(ctx, gateway.EventURL(), nil, am.ProcessUpdate, 0, testLogger{})
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = events.Stop() })
+
+	return gateway, am
+}
+
+// waitFor polls a condition until it is met or the test times out.
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGatewayOnEventSwitchesPlug(t *testing.T) {
+	gateway, am := newTestGateway(t)
+
+	plug, ok := am.Services["00:11:22:33:44:55:66:88-01"].(*Light)
+	if !ok {
+		t.Fatalf("the plug is exposed as %T, want *Light", am.Services["00:11:22:33:44:55:66:88-01"])
+	}
+	if plug.On.Value() {
+		t.Fatal("the plug is on before the event")
+	}
+
+	gateway.Send(t, `{"t":"event","e":"changed","r":"lights","id":"1","uniqueid":"00:11:22:33:44:55:66:88-01","state":{"on":true}}`)
+	waitFor(t, "the plug is on", plug.On.Value)
+
+	gateway.Send(t, `{"t":"event","e":"changed","r":"lights","id":"1","uniqueid":"00:11:22:33:44:55:66:88-01","state":{"on":false}}`)
+	waitFor(t, "the plug is off", func() bool { return !plug.On.Value() })
+}
+
+// bulbDevice is a color temperature bulb as reported by /devices/{uniqueid}.
+// It is formatted with the last byte of the unique ID.
+const bulbDevice = `{
+	"uniqueid": "00:11:22:33:44:55:77:%02x",
+	"manufacturername": "IKEA of Sweden",
+	"modelid": "TRADFRI bulb E27 WS opal 980lm",
+	"name": "Bulb %[1]d",
+	"lastseen": "2024-05-01T10:00Z",
+	"subdevices": [{
+		"type": "Color temperature light",
+		"uniqueid": "00:11:22:33:44:55:77:%02[1]x-01",
+		"config": {},
+		"state": {
+			"on": {"value": true},
+			"bri": {"value": 127},
+			"colormode": {"value": "ct"},
+			"ct": {"value": 300},
+			"reachable": {"value": true}
+		}
+	}]
+}`
+
+// bulbLight is the light of bulbDevice as reported by /lights/{id}.
+const bulbLight = `{
+	"ctmax": 454,
+	"ctmin": 250,
+	"hascolor": true,
+	"name": "Bulb %d",
+	"state": {"on": true, "bri": 127, "colormode": "ct", "ct": 300, "reachable": true},
+	"type": "Color temperature light",
+	"uniqueid": "00:11:22:33:44:55:77:%02[1]x-01"
+}`
+
+func TestStartupFetchesEachLightOnce(t *testing.T) {
+	const bulbs = 5
+	gateway := deconztest.NewGateway(t)
+	for i := 1; i <= bulbs; i++ {
+		gateway.AddDevice(t, fmt.Sprintf(bulbDevice, i))
+		gateway.AddLight(t, fmt.Sprint(i), fmt.Sprintf(bulbLight, i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	api := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+	api.SetLogger(testLogger{})
+	devices, err := api.GetAllDevices()
+	if err != nil {
+		t.Fatalf("GetAllDevices() error = %v", err)
+	}
+	am := NewAccessoryManager(api, devices, Options{})
+
+	// The capabilities and the color temperature range of a bulb are read from the same
+	// details, without the cache every bulb would be requested twice
+	if got := gateway.Reads("/lights/"); got != bulbs {
+		t.Errorf("requested the lights %d times on startup, want %d (once per bulb)", got, bulbs)
+	}
+	for i := 1; i <= bulbs; i++ {
+		id := fmt.Sprintf("00:11:22:33:44:55:77:%02x-01", i)
+		light, ok := am.Services[id].(*Light)
+		if !ok || light.ColorTemperature == nil {
+			t.Fatalf("%s is exposed as %T, want a light with a color temperature", id, am.Services[id])
+		}
+		if ctMin, ctMax := light.ColorTemperature.MinValue(), light.ColorTemperature.MaxValue(); ctMin != 250 || ctMax != 454 {
+			t.Errorf("%s: color temperature range = %d-%d, want 250-454", id, ctMin, ctMax)
+		}
+	}
+}
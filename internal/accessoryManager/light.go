@@ -4,16 +4,22 @@ package accessoryManager
 
 import (
 	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/helper"
 	"github.com/brutella/hap/characteristic"
 	"github.com/brutella/hap/service"
-	"time"
+	"net/http"
+	"slices"
+	"sync"
 )
 
 // Light represents a light device in HomeKit.
 // It implements the DeviceService interface and provides functionality for
-// controlling lights with various capabilities (on/off, brightness, color temperature).
+// controlling lights with various capabilities (on/off, brightness, color temperature, color).
+// Some bulbs are exposed by deCONZ as several lights, which are merged into a single Light
+// with one channel per deCONZ light (see mergeableLights).
 type Light struct {
 	// ID is the unique identifier of the light (from deCONZ)
+	// For a merged light, this is the ID of the first channel
 	ID string
 
 	// On is the HomeKit characteristic for the on/off state
@@ -25,9 +31,48 @@ type Light struct {
 	// ColorTemperature is the HomeKit characteristic for color temperature
 	ColorTemperature *characteristic.ColorTemperature
 
-	// lastChange tracks when the light was last changed by a user command
-	// This is used to prevent feedback loops when updating state
-	lastChange *time.Time
+	// Hue is the HomeKit characteristic for the hue of the color
+	Hue *characteristic.Hue
+
+	// Saturation is the HomeKit characteristic for the saturation of the color
+	Saturation *characteristic.Saturation
+
+	// colorLoop is an optional switch for the color loop effect
+	colorLoop *service.Switch
+
+	// minBrightness is the raw deCONZ brightness HomeKit's 1% is mapped to
+	minBrightness int
+
+	// channels are the deCONZ lights of the service, starting with the light of ID
+	channels []*lightChannel
+
+	// mu guards active and the on/off states and color modes of the channels, which are
+	// changed by HomeKit, by the events of deCONZ and by the reverts of failed commands
+	mu sync.Mutex
+
+	// active is the channel which is turned on and dimmed
+	active *lightChannel
+
+	// colorChannel is the channel the color and the color loop are controlled with
+	colorChannel *lightChannel
+
+	// colorTemperatureChannel is the channel the color temperature is controlled with
+	colorTemperatureChannel *lightChannel
+
+	// feedback ignores the state echoed by deCONZ after a change from HomeKit
+	feedback *feedbackGuard
+
+	// commands is the queue of commands for the deCONZ gateway
+	commands commandQueue
+
+	// refresher refreshes the characteristics when they are read by a controller
+	refresher *refresher
+
+	// brightnessDebouncer coalesces rapid brightness changes from HomeKit
+	brightnessDebouncer *debouncer[int]
+
+	// colorTemperatureDebouncer coalesces rapid color temperature changes from HomeKit
+	colorTemperatureDebouncer *debouncer[int]
 
 	// device is a reference to the parent Device
 	device *Device
@@ -37,7 +82,7 @@ type Light struct {
 }
 
 // NewLight creates a new Light service with the specified service type.
-// The service type determines whether the light appears as a lightbulb or outlet in HomeKit.
+// The service type determines whether the light appears as a lightbulb, outlet or switch in HomeKit.
 //
 // Parameters:
 //   - device: A pointer to the parent Device
@@ -50,6 +95,25 @@ func NewLight(device *Device, config *deconz.Subdevice, serviceType string) *Lig
 	lightbulb := new(Light)
 	lightbulb.ID = config.UniqueId
 	lightbulb.device = device
+	lightbulb.feedback = newFeedbackGuard(device.options.feedbackWindow(device.ID, config.UniqueId))
+	lightbulb.minBrightness = device.options.minBrightness(device.ID, config.UniqueId)
+
+	// Lights start with a single channel, which controls all capabilities
+	channel := &lightChannel{id: config.UniqueId, light: lightbulb}
+	lightbulb.channels = []*lightChannel{channel}
+	lightbulb.active = channel
+	lightbulb.colorChannel = channel
+	lightbulb.colorTemperatureChannel = channel
+
+	// Start sending the commands for this light to the deCONZ gateway
+	lightbulb.commands = newCommandQueue()
+
+	// Reads of all characteristics of the light share a refresh
+	lightbulb.refresher = newRefresher(lightbulb.refresh)
+
+	// Coalesce the changes of sliders, so the gateway isn't flooded with commands
+	lightbulb.brightnessDebouncer = newDebouncer[int](debounceDelay)
+	lightbulb.colorTemperatureDebouncer = newDebouncer[int](debounceDelay)
 
 	// Create a new HomeKit service of the specified type
 	lightbulb.service = service.New(serviceType)
@@ -67,12 +131,42 @@ func (light *Light) S() *service.S {
 	return light.service
 }
 
-// updateChange records the current time as the last change time.
-// This is used to ignore state updates from deCONZ for a short period
-// after a user-initiated change to prevent feedback loops.
-func (light *Light) updateChange() {
-	now := time.Now()
-	light.lastChange = &now
+// send queues a command for the deCONZ gateway.
+// HomeKit already shows the new value (optimistic update) and the echoed event
+// from deCONZ is suppressed by the feedback guard. If the command fails,
+// revert restores the previous value, so HomeKit reflects the real state of the light.
+//
+// Parameters:
+//   - description: A description of the command for the error log (e.g. "set brightness")
+//   - command: The function sending the command to the gateway
+//   - revert: The function restoring the previous value of the characteristic
+func (light *Light) send(description string, command func() error, revert func()) {
+	light.feedback.updateChange()
+	light.commands.send(light.device.log, description, command, revert)
+}
+
+// refresh updates the characteristics from the current state of the channels in deCONZ.
+// If the state of a channel can't be fetched, its last known values are kept.
+// The states are fetched first and then applied like an event, so that the refresh
+// doesn't race the events processed by the accessory manager.
+func (light *Light) refresh() {
+	channels := []*lightChannel{}
+	states := []deconz.ObjectMap{}
+	for _, channel := range light.channels {
+		details, err := light.device.client.GetCurrentLight(channel.id)
+		if err != nil {
+			light.device.log.Debugf("failed to refresh the state of %s: %v", channel.id, err)
+			continue
+		}
+		channels = append(channels, channel)
+		states = append(states, details.State.ObjectMap())
+	}
+
+	light.device.updateMu.Lock()
+	defer light.device.updateMu.Unlock()
+	for i, channel := range channels {
+		channel.UpdateState(states[i])
+	}
 }
 
 // enableOn adds the On characteristic to the light service.
@@ -81,6 +175,7 @@ func (light *Light) enableOn() {
 	light.On = characteristic.NewOn()
 	// Register the SetOn method to be called when the value is changed through HomeKit
 	light.On.OnValueRemoteUpdate(light.SetOn)
+	refreshOnRead(light.On.C, light.refresher)
 
 	// Add the characteristic to the service
 	light.service.AddC(light.On.C)
@@ -91,7 +186,12 @@ func (light *Light) enableOn() {
 func (light *Light) enableBrightness() {
 	light.Brightness = characteristic.NewBrightness()
 	// Register the SetBrightness method to be called when the value is changed through HomeKit
-	light.Brightness.OnValueRemoteUpdate(light.SetBrightness)
+	light.Brightness.OnValueUpdate(func(v, previous int, r *http.Request) {
+		if r != nil {
+			light.SetBrightness(v, previous)
+		}
+	})
+	refreshOnRead(light.Brightness.C, light.refresher)
 
 	// Add the characteristic to the service
 	light.service.AddC(light.Brightness.C)
@@ -102,22 +202,78 @@ func (light *Light) enableBrightness() {
 func (light *Light) enableColorTemperature() {
 	light.ColorTemperature = characteristic.NewColorTemperature()
 	// Register the SetColorTemperature method to be called when the value is changed through HomeKit
-	light.ColorTemperature.OnValueRemoteUpdate(light.SetColorTemperature)
-
-	// Set the minimum and maximum color temperature values in mireds
-	if details, err := light.device.client.GetLight(light.ID); err == nil {
-		if ctMin := details.CtMin; ctMin != nil {
-			light.ColorTemperature.SetMinValue(*ctMin)
-		}
-		if ctMax := details.CtMax; ctMax != nil {
-			light.ColorTemperature.SetMaxValue(*ctMax)
+	light.ColorTemperature.OnValueUpdate(func(v, previous int, r *http.Request) {
+		if r != nil {
+			light.SetColorTemperature(v, previous)
 		}
-	}
+	})
+	refreshOnRead(light.ColorTemperature.C, light.refresher)
+	light.updateColorTemperatureRange()
 
 	// Add the characteristic to the service
 	light.service.AddC(light.ColorTemperature.C)
 }
 
+// updateColorTemperatureRange sets the minimum and maximum values of the ColorTemperature
+// characteristic to the range supported by the color temperature channel.
+// If the range can't be fetched, the current range is kept.
+func (light *Light) updateColorTemperatureRange() {
+	id := light.colorTemperatureChannel.id
+	details, err := fetchWithRetry(light.device.client.Context(), light.device.log, id, light.device.client.GetLight)
+	if err != nil {
+		light.device.log.Warnf("failed to get the color temperature range of %s, using the default range: %v", id, err)
+		return
+	}
+
+	// The range is reported in mireds
+	if ctMin := details.CtMin; ctMin != nil {
+		light.ColorTemperature.SetMinValue(*ctMin)
+	}
+	if ctMax := details.CtMax; ctMax != nil {
+		light.ColorTemperature.SetMaxValue(*ctMax)
+	}
+}
+
+// enableColor adds the Hue and Saturation characteristics to the light service.
+// This allows the light's color to be controlled through HomeKit.
+func (light *Light) enableColor() {
+	light.Hue = characteristic.NewHue()
+	// Register the SetHue method to be called when the value is changed through HomeKit
+	light.Hue.OnValueUpdate(func(v, previous float64, r *http.Request) {
+		if r != nil {
+			light.SetHue(v, previous)
+		}
+	})
+
+	light.Saturation = characteristic.NewSaturation()
+	// Register the SetSaturation method to be called when the value is changed through HomeKit
+	light.Saturation.OnValueUpdate(func(v, previous float64, r *http.Request) {
+		if r != nil {
+			light.SetSaturation(v, previous)
+		}
+	})
+
+	// Add the characteristics to the service
+	light.service.AddC(light.Hue.C)
+	light.service.AddC(light.Saturation.C)
+}
+
+// enableColorLoop adds a companion switch to the accessory, which starts and stops
+// the color loop effect of the light. HomeKit has no native control for light effects.
+func (light *Light) enableColorLoop() {
+	light.colorLoop = service.NewSwitch()
+	// Register the SetColorLoop method to be called when the value is changed through HomeKit
+	light.colorLoop.On.OnValueRemoteUpdate(light.SetColorLoop)
+
+	// Name the switch, so it can be told apart from the light
+	name := characteristic.NewName()
+	name.SetValue("Color Loop")
+	light.colorLoop.AddC(name.C)
+
+	// Add the service to the accessory
+	light.device.Accessory.AddS(light.colorLoop.S)
+}
+
 // SetOn turns the light on or off.
 // This method is called when the On characteristic is changed through HomeKit.
 //
@@ -126,11 +282,35 @@ func (light *Light) enableColorTemperature() {
 func (light *Light) SetOn(on bool) {
 	light.device.log.Infof("set %s", onOffStr[on])
 
-	// Send the command to the deCONZ gateway
-	if err := light.device.client.SetLightOn(light.ID, on); err != nil {
-		light.device.log.Errorf("failed to set light %s: %+v", onOffStr[on], err)
+	// Only the active channel is turned on, but all channels are turned off
+	light.mu.Lock()
+	channels := light.channels
+	if on {
+		channels = []*lightChannel{light.active}
+	}
+	previous := make([]bool, len(channels))
+	for i, channel := range channels {
+		previous[i] = channel.on
+		channel.on = on
 	}
-	light.updateChange()
+	light.mu.Unlock()
+
+	// Send the command to the deCONZ gateway
+	light.send("set light "+onOffStr[on], func() error {
+		for _, channel := range channels {
+			if err := light.device.client.SetLightOn(channel.id, on); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func() {
+		light.mu.Lock()
+		for i, channel := range channels {
+			channel.on = previous[i]
+		}
+		light.mu.Unlock()
+		light.On.SetValue(!on)
+	})
 }
 
 // SetBrightness sets the brightness of the light.
@@ -138,14 +318,27 @@ func (light *Light) SetOn(on bool) {
 //
 // Parameters:
 //   - v: An integer representing the brightness percentage (0-100)
-func (light *Light) SetBrightness(v int) {
-	light.device.log.Infof("set brightness to %d%%", v)
-
-	// Send the command to the deCONZ gateway
-	if err := light.device.client.SetLightBrightness(light.ID, v); err != nil {
-		light.device.log.Errorf("failed to set brightness: %+v", err)
-	}
-	light.updateChange()
+//   - previous: The previous brightness percentage, which is restored if the command fails
+func (light *Light) SetBrightness(v, previous int) {
+	// Suppress the echoed events while the slider is dragged
+	light.feedback.updateChange()
+
+	// Only send the latest value within the debounce window to the deCONZ gateway
+	light.brightnessDebouncer.call(v, previous, func(previous int) {
+		light.device.log.Infof("set brightness to %d%%", v)
+
+		// The brightness is set on the channel which is active when the value is sent
+		light.mu.Lock()
+		id := light.active.id
+		light.mu.Unlock()
+		light.send("set brightness", func() error {
+			return light.device.client.SetLightBrightness(id, v, light.minBrightness)
+		}, func() {
+			// The gateway may have applied the command, so the next value is sent in any case
+			light.brightnessDebouncer.force()
+			_ = light.Brightness.SetValue(previous)
+		})
+	})
 }
 
 // SetColorTemperature sets the color temperature of the light.
@@ -153,48 +346,261 @@ func (light *Light) SetBrightness(v int) {
 //
 // Parameters:
 //   - v: An integer representing the color temperature in mireds
-func (light *Light) SetColorTemperature(v int) {
-	// Convert mireds to Kelvin for logging (mireds = 1,000,000/Kelvin)
-	k := 1_000_000.0 / float64(v)
-	light.device.log.Infof("set color temperature to %.1f K (%d)", k, v)
+//   - previous: The previous color temperature, which is restored if the command fails
+func (light *Light) SetColorTemperature(v, previous int) {
+	// Suppress the echoed events while the slider is dragged
+	light.feedback.updateChange()
+
+	// Only send the latest value within the debounce window to the deCONZ gateway
+	light.colorTemperatureDebouncer.call(v, previous, func(previous int) {
+		// Convert mireds to Kelvin for logging (mireds = 1,000,000/Kelvin)
+		k := 1_000_000.0 / float64(v)
+		light.device.log.Infof("set color temperature to %.1f K (%d)", k, v)
+
+		channel := light.colorTemperatureChannel
+		light.activate(channel)
+		light.send("set color temperature", func() error {
+			return light.device.client.SetLightColorTemperature(channel.id, v)
+		}, func() {
+			// The gateway may have applied the command, so the next value is sent in any case
+			light.colorTemperatureDebouncer.force()
+			_ = light.ColorTemperature.SetValue(previous)
+		})
+	})
+}
+
+// SetHue sets the hue of the light's color.
+// This method is called when the Hue characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: A float representing the hue in degrees (0-360)
+//   - previous: The previous hue, which is restored if the command fails
+func (light *Light) SetHue(v, previous float64) {
+	light.device.log.Infof("set hue to %.0f°", v)
 
 	// Send the command to the deCONZ gateway
-	if err := light.device.client.SetLightColorTemperature(light.ID, v); err != nil {
-		light.device.log.Errorf("failed to set color temperature: %+v", err)
+	channel := light.colorChannel
+	command := light.colorCommand(channel, v, light.Saturation.Value())
+	light.activate(channel)
+	light.send("set hue", command, func() {
+		light.Hue.SetValue(previous)
+	})
+}
+
+// SetSaturation sets the saturation of the light's color.
+// This method is called when the Saturation characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: A float representing the saturation percentage (0-100)
+//   - previous: The previous saturation, which is restored if the command fails
+func (light *Light) SetSaturation(v, previous float64) {
+	light.device.log.Infof("set saturation to %.0f%%", v)
+
+	// Send the command to the deCONZ gateway
+	channel := light.colorChannel
+	command := light.colorCommand(channel, light.Hue.Value(), v)
+	light.activate(channel)
+	light.send("set saturation", command, func() {
+		light.Saturation.SetValue(previous)
+	})
+}
+
+// colorCommand creates the command setting the color of a channel.
+// Channels in the "xy" color mode (e.g. lights which only support xy colors) get the color
+// as xy coordinates, so that they stay in their color mode. All other channels get hue and
+// saturation and switch to the "hs" color mode.
+//
+// Parameters:
+//   - channel: The channel whose color is set
+//   - hue: The hue in degrees (0-360)
+//   - saturation: The saturation in percent (0-100)
+//
+// Returns:
+//   - func() error: The command sending the color to the gateway
+func (light *Light) colorCommand(channel *lightChannel, hue, saturation float64) func() error {
+	light.mu.Lock()
+	defer light.mu.Unlock()
+
+	if channel.colorMode == "xy" {
+		// The brightness is controlled separately, so the color is converted at full brightness
+		x, y := helper.HueSatToXY(hue, saturation, 1)
+		return func() error {
+			return light.device.client.SetLightXY(channel.id, x, y)
+		}
+	}
+
+	channel.colorMode = "hs"
+	return func() error {
+		return light.device.client.SetLightHueSaturation(channel.id, hue, saturation)
+	}
+}
+
+// SetColorLoop starts or stops the color loop effect of the light.
+// This method is called when the On characteristic of the color loop switch is changed through HomeKit.
+//
+// Parameters:
+//   - on: A boolean indicating whether to start (true) or stop (false) the color loop
+func (light *Light) SetColorLoop(on bool) {
+	light.device.log.Infof("set color loop %s", onOffStr[on])
+
+	effect := deconz.EffectNone
+	if on {
+		effect = deconz.EffectColorLoop
 	}
-	light.updateChange()
+
+	// Send the command to the deCONZ gateway
+	light.send("set color loop "+onOffStr[on], func() error {
+		return light.device.client.SetLightEffect(light.colorChannel.id, effect)
+	}, func() {
+		light.colorLoop.On.SetValue(!on)
+	})
 }
 
 // UpdateState updates the light's state based on updates from the deCONZ gateway.
 // This method implements the DeviceService interface.
+// The updates of the other channels of a merged light are routed to the light by the channels.
 //
 // Parameters:
 //   - state: The updated state object from deCONZ
-//   - _: The updated config object from deCONZ (not used for lights)
 func (light *Light) UpdateState(state deconz.MapObject) {
+	light.updateChannel(light.channels[0], state)
+}
+
+// updateChannel updates the light's state based on an update of one of its channels.
+// All values of the state are evaluated before any characteristic is changed,
+// so that the notifications of a single event are sent to the controllers together.
+// The brightness is taken from the active channel and the colors from the channels
+// controlling them, because deCONZ keeps reporting the values of the other channels.
+//
+// Parameters:
+//   - channel: The channel the update was reported for
+//   - state: The updated state object from deCONZ
+func (light *Light) updateChannel(channel *lightChannel, state deconz.MapObject) {
 	// Ignore updates for a short period after a user-initiated change
 	// to prevent feedback loops
-	if light.lastChange != nil {
-		ignoreUntil := light.lastChange.Add(time.Second)
-		if time.Now().Before(ignoreUntil) {
-			return
-		}
+	if light.feedback.suppressed() {
+		return
 	}
 
-	// Update the On characteristic if the state contains an "on" value
-	if state.Has("on") && light.On != nil {
-		light.On.SetValue(state.ValueToBool("on"))
+	// A single event may change several values (e.g. on, bri and ct), which are
+	// collected first and then applied together
+	batch := characteristicBatch{}
+	light.mu.Lock()
+
+	// Remember whether the channel is on
+	// Some lights only report a changed brightness, a brightness above 0 means that they are on
+	reportsOn := state.Has("on") || (state.Has("bri") && state.ValueToInt("bri") > 0)
+	if reportsOn {
+		channel.on = !state.Has("on") || state.ValueToBool("on")
+	}
+
+	// A channel turned on outside of HomeKit becomes the active one if the active channel is off
+	if channel.on && !light.active.on {
+		light.active = channel
 	}
 
 	// Update the Brightness characteristic if the state contains a "bri" value
-	if state.Has("bri") && light.Brightness != nil {
-		_ = light.Brightness.SetValue(state.ValueToPercent("bri"))
+	if state.Has("bri") && light.Brightness != nil && channel == light.active {
+		brightness := helper.BrightnessToPercent(state.ValueToInt("bri"), light.minBrightness)
+		batch.add(func() { _ = light.Brightness.SetValue(brightness) })
+	}
+
+	// Update the color loop switch if the state contains an "effect" value
+	if state.Has("effect") && light.colorLoop != nil && channel == light.colorChannel {
+		colorLoop := state.ValueToString("effect") == deconz.EffectColorLoop
+		batch.add(func() { light.colorLoop.On.SetValue(colorLoop) })
+	}
+
+	// Remember the active color mode, the values of the other modes are stale
+	if state.Has("colormode") {
+		channel.colorMode = state.ValueToString("colormode")
+	}
+
+	// Only update the characteristics matching the active color mode
+	switch channel.colorMode {
+	case "ct":
+		light.updateColorTemperature(channel, state, &batch)
+	case "hs":
+		light.updateHueSaturation(channel, state, &batch)
+	case "xy":
+		light.updateXY(channel, state, &batch)
+	default:
+		// Lights which don't report a color mode only support a single one
+		light.updateColorTemperature(channel, state, &batch)
+		light.updateHueSaturation(channel, state, &batch)
+	}
+
+	// The On characteristic is updated last, so that a light which is turned on
+	// already shows its new brightness and color
+	// A merged light is on as long as any of its channels is on
+	if reportsOn && light.On != nil {
+		on := slices.ContainsFunc(light.channels, func(c *lightChannel) bool { return c.on })
+		batch.add(func() { light.On.SetValue(on) })
+	}
+
+	// The characteristics are changed without holding the lock, as they notify the controllers
+	light.mu.Unlock()
+	batch.apply()
+}
+
+// updateColorTemperature queues the update of the ColorTemperature characteristic
+// if the state of the color temperature channel contains a "ct" value.
+//
+// Parameters:
+//   - channel: The channel the update was reported for
+//   - state: The updated state object from deCONZ
+//   - batch: The batch the update is added to
+func (light *Light) updateColorTemperature(channel *lightChannel, state deconz.MapObject, batch *characteristicBatch) {
+	if state.Has("ct") && light.ColorTemperature != nil && channel == light.colorTemperatureChannel {
+		ct := state.ValueToInt("ct")
+		batch.add(func() { _ = light.ColorTemperature.SetValue(ct) })
+	}
+}
+
+// updateHueSaturation queues the update of the Hue and Saturation characteristics if the
+// state of the color channel contains "hue" (0-65535) or "sat" (0-255) values.
+//
+// Parameters:
+//   - channel: The channel the update was reported for
+//   - state: The updated state object from deCONZ
+//   - batch: The batch the updates are added to
+func (light *Light) updateHueSaturation(channel *lightChannel, state deconz.MapObject, batch *characteristicBatch) {
+	if channel != light.colorChannel {
+		return
 	}
+	if state.Has("hue") && light.Hue != nil {
+		hue := float64(state.ValueToInt("hue")) * 360.0 / 65535.0
+		batch.add(func() { light.Hue.SetValue(hue) })
+	}
+	if state.Has("sat") && light.Saturation != nil {
+		sat := float64(state.ValueToPercent("sat"))
+		batch.add(func() { light.Saturation.SetValue(sat) })
+	}
+}
 
-	// Update the ColorTemperature characteristic if the state contains a "ct" value
-	if state.Has("ct") && light.ColorTemperature != nil {
-		_ = light.ColorTemperature.SetValue(state.ValueToInt("ct"))
+// updateXY queues the update of the Hue and Saturation characteristics if the state of the
+// color channel contains an "xy" value, by converting the CIE xy color to hue and saturation.
+//
+// Parameters:
+//   - channel: The channel the update was reported for
+//   - state: The updated state object from deCONZ
+//   - batch: The batch the updates are added to
+func (light *Light) updateXY(channel *lightChannel, state deconz.MapObject, batch *characteristicBatch) {
+	if !state.Has("xy") || light.Hue == nil || light.Saturation == nil || channel != light.colorChannel {
+		return
 	}
+
+	xy := state.ValueToFloatSlice("xy")
+	if len(xy) != 2 {
+		return
+	}
+
+	// The brightness is controlled separately, so the color is converted at full brightness
+	hue, sat := helper.XYToHueSat(xy[0], xy[1], 1)
+	batch.add(func() {
+		light.Hue.SetValue(hue)
+		light.Saturation.SetValue(sat)
+	})
 }
 
 // UpdateConfig updates the light's configuration based on updates from the deCONZ gateway.
@@ -208,69 +614,208 @@ func (light *Light) UpdateConfig(_ deconz.MapObject) {
 	// nothing to do
 }
 
-// NewOnOffLight creates a new on/off light service.
-// This is used for lights that only support being turned on or off.
+// ZCL color capabilities reported by deCONZ in the "colorcapabilities" field of a light.
+const (
+	colorCapabilityHueSaturation    = 1 << 0
+	colorCapabilityEnhancedHue      = 1 << 1
+	colorCapabilityColorLoop        = 1 << 2
+	colorCapabilityXY               = 1 << 3
+	colorCapabilityColorTemperature = 1 << 4
+)
+
+// lightCapabilities describes the characteristics supported by a light.
+type lightCapabilities struct {
+	// brightness indicates whether the light is dimmable
+	brightness bool
+
+	// colorTemperature indicates whether the color temperature can be set
+	colorTemperature bool
+
+	// color indicates whether the hue and saturation can be set
+	color bool
+
+	// colorLoop indicates whether the light supports the color loop effect
+	colorLoop bool
+}
+
+// dimmableLightTypes are the deCONZ types of lights which are always dimmable.
+var dimmableLightTypes = []deconz.DeviceType{
+	deconz.DimmableLightDevice,
+	deconz.ColorTemperatureLightDevice,
+	deconz.ColorLightDevice,
+	deconz.ExtendedColorLightDevice,
+}
+
+// lightTypeCapabilities returns the color capabilities implied by the deCONZ type of a light.
+//
+// Parameters:
+//   - lightType: The deCONZ type of the light
+//
+// Returns:
+//   - lightCapabilities: The capabilities of the type (without brightness)
+func lightTypeCapabilities(lightType deconz.DeviceType) lightCapabilities {
+	switch lightType {
+	case deconz.ColorTemperatureLightDevice:
+		return lightCapabilities{colorTemperature: true}
+	case deconz.ColorLightDevice:
+		return lightCapabilities{color: true, colorLoop: true}
+	case deconz.ExtendedColorLightDevice:
+		return lightCapabilities{colorTemperature: true, color: true, colorLoop: true}
+	default:
+		return lightCapabilities{}
+	}
+}
+
+// lightCapabilitiesOf determines the characteristics supported by a light.
+// The color capabilities and the color temperature range reported by the light are preferred.
+// If the light doesn't report them, the capabilities are derived from the deCONZ type.
+// Some color bulbs have a misleading type, but report a hue/saturation or xy color mode and
+// no color temperature at all. They get the color characteristics instead of a color
+// temperature, which would have no effect.
+//
+// Parameters:
+//   - lightType: The deCONZ type of the light
+//   - state: The current state of the light
+//   - details: A pointer to the light details from deCONZ (may be nil)
+//
+// Returns:
+//   - lightCapabilities: The capabilities of the light
+func lightCapabilitiesOf(lightType deconz.DeviceType, state deconz.MapObject, details *deconz.Light) lightCapabilities {
+	capabilities := lightCapabilities{
+		// Dimmable lights report their brightness, lights of other types (e.g. on/off outputs
+		// exposed as a lightbulb) only get a brightness if they report one
+		brightness: state.Has("bri") || slices.Contains(dimmableLightTypes, lightType),
+	}
+
+	// Use the color capabilities if the light reports them
+	if details != nil && details.ColorCapabilities != nil && *details.ColorCapabilities > 0 {
+		bits := *details.ColorCapabilities
+		capabilities.colorTemperature = bits&colorCapabilityColorTemperature != 0
+		capabilities.color = bits&(colorCapabilityHueSaturation|colorCapabilityEnhancedHue|colorCapabilityXY) != 0
+		capabilities.colorLoop = bits&colorCapabilityColorLoop != 0
+		return capabilities
+	}
+
+	// Otherwise derive the capabilities from the type of the light
+	typeCapabilities := lightTypeCapabilities(lightType)
+	capabilities.colorTemperature = typeCapabilities.colorTemperature
+	capabilities.color = typeCapabilities.color
+	capabilities.colorLoop = typeCapabilities.colorLoop
+
+	// Lights reporting a color temperature range support color temperatures
+	hasCtRange := details != nil && details.CtMin != nil && details.CtMax != nil
+	if hasCtRange {
+		capabilities.colorTemperature = true
+	}
+
+	// Lights in a color mode which never report a color temperature only support colors
+	if capabilities.colorTemperature && !hasCtRange && !state.Has("ct") && state.Has("colormode") {
+		if mode := state.ValueToString("colormode"); mode == "hs" || mode == "xy" {
+			capabilities.colorTemperature = false
+			capabilities.color = true
+		}
+	}
+
+	return capabilities
+}
+
+// NewLightFromCapabilities creates a new light service with the characteristics supported by the light.
+// This is used for all kinds of lights (on/off, dimmable, color temperature and color lights).
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
 //
 // Returns:
 //   - error: An error if the service could not be created
-func (device *Device) NewOnOffLight(config *deconz.Subdevice) error {
+func (device *Device) NewLightFromCapabilities(config *deconz.Subdevice) error {
+	// The details are usually cached, as all lights are fetched on startup
+	details, err := fetchWithRetry(device.client.Context(), device.log, config.UniqueId, device.client.GetLight)
+	if err != nil {
+		device.log.Warnf("failed to get the capabilities of %s, using the capabilities of the type %s: %v", config.UniqueId, config.Type, err)
+	}
+	capabilities := lightCapabilitiesOf(config.Type, config.State, details)
+
+	// Report lights whose capabilities contradict their type, as their type may be misleading
+	typeCapabilities := lightTypeCapabilities(config.Type)
+	if capabilities.colorTemperature != typeCapabilities.colorTemperature || capabilities.color != typeCapabilities.color {
+		device.log.Warnf("the capabilities of %s (color temperature: %t, color: %t) contradict its type %s",
+			config.UniqueId, capabilities.colorTemperature, capabilities.color, config.Type)
+	}
+
+	// Merge the light into the other light of the bulb if they are channels of the same bulb
+	if existing := device.mergeTarget(capabilities); existing != nil {
+		existing.addChannel(config, capabilities)
+		return nil
+	}
+
 	light := NewLight(device, config, service.TypeLightbulb)
 	light.enableOn()
+	if capabilities.brightness {
+		light.enableBrightness()
+	}
+	if capabilities.colorTemperature {
+		light.enableColorTemperature()
+	}
+	if capabilities.color {
+		light.enableColor()
+	}
+	if capabilities.colorLoop && device.options.ColorLoopSwitch {
+		light.enableColorLoop()
+	}
 	light.UpdateState(config.State)
 
 	return nil
 }
 
-// NewDimmableLight creates a new dimmable light service.
-// This is used for lights that support being turned on/off and brightness control.
+// NewOnOffPlugDevice creates a new on/off plug device service.
+// This is used for plug-in units and outlets that can be turned on or off.
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
 //
 // Returns:
 //   - error: An error if the service could not be created
-func (device *Device) NewDimmableLight(config *deconz.Subdevice) error {
-	light := NewLight(device, config, service.TypeLightbulb)
-	light.enableOn()
-	light.enableBrightness()
-	light.UpdateState(config.State)
+func (device *Device) NewOnOffPlugDevice(config *deconz.Subdevice) error {
+	plug := NewLight(device, config, service.TypeOutlet)
+	plug.enableOn()
+	plug.UpdateState(config.State)
 
 	return nil
 }
 
-// NewColorTemperatureLight creates a new color temperature light service.
-// This is used for lights that support being turned on/off, brightness control,
-// and color temperature control.
+// NewRelaySwitch creates a new on/off switch service.
+// This is used for on/off outputs like wall relays, which are selected to be exposed
+// as a generic switch instead of an outlet.
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
 //
 // Returns:
 //   - error: An error if the service could not be created
-func (device *Device) NewColorTemperatureLight(config *deconz.Subdevice) error {
-	light := NewLight(device, config, service.TypeLightbulb)
-	light.enableOn()
-	light.enableBrightness()
-	light.enableColorTemperature()
-	light.UpdateState(config.State)
+func (device *Device) NewRelaySwitch(config *deconz.Subdevice) error {
+	relay := NewLight(device, config, service.TypeSwitch)
+	relay.enableOn()
+	relay.UpdateState(config.State)
 
 	return nil
 }
 
-// NewOnOffPlugDevice creates a new on/off plug device service.
-// This is used for plug-in units and outlets that can be turned on or off.
+// NewDimmablePlug creates a new dimmable plug device service.
+// This is used for plug-in units that can be turned on or off and dimmed.
+// The plug is exposed as an outlet with an additional Brightness characteristic,
+// so it isn't misrepresented as a lightbulb. The tradeoff is that the Apple Home app
+// only shows the on/off switch for outlets, while the brightness is still available
+// in other HomeKit apps (e.g. Eve), scenes and automations.
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
 //
 // Returns:
 //   - error: An error if the service could not be created
-func (device *Device) NewOnOffPlugDevice(config *deconz.Subdevice) error {
+func (device *Device) NewDimmablePlug(config *deconz.Subdevice) error {
 	plug := NewLight(device, config, service.TypeOutlet)
 	plug.enableOn()
+	plug.enableBrightness()
 	plug.UpdateState(config.State)
 
 	return nil
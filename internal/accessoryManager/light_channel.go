@@ -0,0 +1,188 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// lightChannel is one of the deCONZ lights controlled by a Light service.
+// Most lights have a single channel. Some bulbs and LED controllers are exposed by deCONZ
+// as several lights of the same device (e.g. a color light and a white light), which are
+// merged into a single Light, so that they don't appear as two conflicting lights in HomeKit.
+// The channels of merged lights implement the DeviceService interface, so that the events
+// of every deCONZ light are routed to the Light they belong to.
+// The on/off state and the color mode are guarded by the mutex of the Light.
+type lightChannel struct {
+	// id is the unique identifier of the deCONZ light
+	id string
+
+	// on is the last known on/off state of the deCONZ light
+	on bool
+
+	// colorMode is the color mode last reported by deCONZ ("ct", "hs" or "xy")
+	// Only the characteristics matching this mode are updated, because deCONZ
+	// keeps reporting stale values for the other modes
+	colorMode string
+
+	// light is the Light the channel belongs to
+	light *Light
+}
+
+// UpdateState updates the state of the light based on updates of the channel.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (channel *lightChannel) UpdateState(state deconz.MapObject) {
+	channel.light.updateChannel(channel, state)
+}
+
+// UpdateConfig updates the configuration of the channel.
+// This method implements the DeviceService interface.
+// Lights don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for lights)
+func (channel *lightChannel) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// S returns the HomeKit service of the light the channel belongs to.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (channel *lightChannel) S() *service.S {
+	return channel.light.service
+}
+
+// mergeableLightTypes are the deCONZ types of lights which may be channels of the same bulb.
+var mergeableLightTypes = map[deconz.DeviceType]bool{
+	deconz.DimmableLightDevice:         true,
+	deconz.ColorTemperatureLightDevice: true,
+	deconz.ColorLightDevice:            true,
+	deconz.ExtendedColorLightDevice:    true,
+}
+
+// mergeableLights reports whether the lights of a device may be channels of a single bulb.
+// This is the case for devices with exactly two dimmable lights without a selected service.
+// Whether they are merged is decided when the second light is added, as only a color light
+// and a light without colors (e.g. a white channel) complement each other. Devices with
+// more lights (e.g. multi-channel dimmers) keep their lights separate.
+// Selecting a service for a light in the overrides keeps the lights separate as well.
+//
+// Parameters:
+//   - subdevices: The subdevices of the device
+//
+// Returns:
+//   - bool: True if the lights may be merged
+func (device *Device) mergeableLights(subdevices []deconz.Subdevice) bool {
+	lights := 0
+	for _, sub := range subdevices {
+		if isSensorType(sub.Type) {
+			continue
+		}
+		if !mergeableLightTypes[sub.Type] || len(device.selectedService(&sub)) > 0 {
+			return false
+		}
+		lights++
+	}
+	return lights == 2
+}
+
+// mergeTarget returns the light a light with the given capabilities is merged into.
+// A light is only merged into a lightbulb of the same device with a single channel,
+// if exactly one of them supports colors.
+//
+// Parameters:
+//   - capabilities: The capabilities of the light which is added
+//
+// Returns:
+//   - *Light: A pointer to the light to merge into, or nil if the light is added separately
+func (device *Device) mergeTarget(capabilities lightCapabilities) *Light {
+	if !device.mergeLights {
+		return nil
+	}
+
+	for _, s := range device.Services {
+		if existing, ok := s.(*Light); ok && existing.service.Type == service.TypeLightbulb &&
+			len(existing.channels) == 1 && (existing.Hue != nil) != capabilities.color {
+			return existing
+		}
+	}
+	return nil
+}
+
+// addChannel merges another deCONZ light of the same bulb into the light.
+// The characteristics the light doesn't support yet are added and their commands are
+// sent to the new channel. The color temperature is preferred from a channel without
+// colors, which is usually the dedicated white channel of the bulb.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration of the channel
+//   - capabilities: The capabilities of the channel
+func (light *Light) addChannel(config *deconz.Subdevice, capabilities lightCapabilities) {
+	channel := &lightChannel{id: config.UniqueId, light: light}
+	light.channels = append(light.channels, channel)
+	light.device.Services[config.UniqueId] = channel
+	light.device.log.Infof("merging %s into the light %s", config.UniqueId, light.ID)
+
+	if capabilities.brightness && light.Brightness == nil {
+		light.enableBrightness()
+	}
+	if capabilities.colorTemperature {
+		if light.ColorTemperature == nil {
+			light.colorTemperatureChannel = channel
+			light.enableColorTemperature()
+		} else if !capabilities.color {
+			light.colorTemperatureChannel = channel
+			light.updateColorTemperatureRange()
+		}
+	}
+	if capabilities.color {
+		light.colorChannel = channel
+		light.enableColor()
+		if capabilities.colorLoop && light.device.options.ColorLoopSwitch {
+			light.enableColorLoop()
+		}
+	}
+
+	channel.UpdateState(config.State)
+}
+
+// activate makes a channel the active one, e.g. before its color is changed.
+// If the light is on, the channel is turned on and the previously active channel is
+// turned off, so that the bulb switches between its channels like a single light.
+//
+// Parameters:
+//   - channel: The channel to activate
+func (light *Light) activate(channel *lightChannel) {
+	light.mu.Lock()
+	previous := light.active
+	switched := channel != previous && light.On != nil && light.On.Value()
+	light.active = channel
+	if switched {
+		channel.on = true
+		previous.on = false
+	}
+	light.mu.Unlock()
+
+	if !switched {
+		return
+	}
+	light.device.log.Infof("switch from %s to %s", previous.id, channel.id)
+	light.send("switch channel", func() error {
+		if err := light.device.client.SetLightOn(channel.id, true); err != nil {
+			return err
+		}
+		return light.device.client.SetLightOn(previous.id, false)
+	}, func() {
+		light.mu.Lock()
+		light.active = previous
+		channel.on = false
+		previous.on = true
+		light.mu.Unlock()
+	})
+}
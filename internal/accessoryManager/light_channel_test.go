@@ -0,0 +1,174 @@
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/deconz/deconztest"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Unique IDs of the channels of the bulb of newMergedLight.
+const (
+	colorChannelId = "00:11:22:33:44:55:66:aa-0b"
+	whiteChannelId = "00:11:22:33:44:55:66:aa-0c"
+)
+
+// newMergedLight creates a bulb which deCONZ exposes as a color light and a white light.
+// The white channel is on, the color channel is off.
+func newMergedLight(t *testing.T) (*deconztest.Gateway, *Device, *Light) {
+	t.Helper()
+
+	gateway := deconztest.NewGateway(t)
+	gateway.AddLight(t, "1", `{
+		"hascolor": true,
+		"type": "Color light",
+		"uniqueid": "`+colorChannelId+`",
+		"state": {"on": false, "bri": 254, "colormode": "hs", "hue": 0, "sat": 254, "reachable": true}
+	}`)
+	gateway.AddLight(t, "2", `{
+		"hascolor": true,
+		"ctmin": 153,
+		"ctmax": 454,
+		"type": "Color temperature light",
+		"uniqueid": "`+whiteChannelId+`",
+		"state": {"on": true, "bri": 127, "colormode": "ct", "ct": 300, "reachable": true}
+	}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+	client.SetLogger(testLogger{})
+
+	// deCONZ lists the white channel first
+	config := &deconz.Device{
+		UniqueId: "00:11:22:33:44:55:66:aa",
+		Name:     "RGBW Bulb",
+		Subdevices: []deconz.Subdevice{
+			{
+				Type:     deconz.ColorTemperatureLightDevice,
+				UniqueId: whiteChannelId,
+				State:    extendedMap(t, `{"on": {"value": true}, "bri": {"value": 127}, "colormode": {"value": "ct"}, "ct": {"value": 300}}`),
+			},
+			{
+				Type:     deconz.ColorLightDevice,
+				UniqueId: colorChannelId,
+				State:    extendedMap(t, `{"on": {"value": false}, "bri": {"value": 254}, "colormode": {"value": "hs"}, "hue": {"value": 0}, "sat": {"value": 254}}`),
+			},
+		},
+	}
+	device := newTestDevice(t, client, config, Options{})
+
+	light, ok := device.Services[colorChannelId].(*Light)
+	if !ok {
+		t.Fatalf("the color channel is exposed as %T, want *Light", device.Services[colorChannelId])
+	}
+	return gateway, device, light
+}
+
+// channelStates returns whether the color and the white channel of a merged light are on.
+func channelStates(light *Light) (color bool, white bool) {
+	light.mu.Lock()
+	defer light.mu.Unlock()
+	return light.channels[0].on, light.channels[1].on
+}
+
+func TestMergedLight(t *testing.T) {
+	gateway, device, light := newMergedLight(t)
+
+	// The white channel is routed to the light, which has a single service
+	channel, ok := device.Services[whiteChannelId].(*lightChannel)
+	if !ok || channel.light != light {
+		t.Fatalf("the white channel is exposed as %T, want a channel of the light", device.Services[whiteChannelId])
+	}
+	if got := len(device.Accessory.Ss); got != 2 {
+		t.Errorf("the accessory has %d services, want the information and the light", got)
+	}
+
+	// The light has the union of the capabilities
+	if light.Brightness == nil || light.ColorTemperature == nil || light.Hue == nil || light.Saturation == nil {
+		t.Fatal("the merged light is missing characteristics")
+	}
+	if light.colorChannel.id != colorChannelId || light.colorTemperatureChannel.id != whiteChannelId {
+		t.Errorf("color on %s and color temperature on %s, want %s and %s",
+			light.colorChannel.id, light.colorTemperatureChannel.id, colorChannelId, whiteChannelId)
+	}
+
+	// The white channel is on, so it is the active one
+	if !light.On.Value() || light.Brightness.Value() != 50 || light.ColorTemperature.Value() != 300 {
+		t.Errorf("On = %t, Brightness = %d, ColorTemperature = %d, want the state of the white channel",
+			light.On.Value(), light.Brightness.Value(), light.ColorTemperature.Value())
+	}
+	if light.active != channel {
+		t.Errorf("the active channel is %s, want %s", light.active.id, whiteChannelId)
+	}
+
+	// Setting a color switches from the white to the color channel
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+	light.Hue.C.SetValueRequest(120.0, write)
+	want := []struct {
+		path string
+		key  string
+		want any
+	}{
+		{"/lights/" + colorChannelId + "/state", "on", true},
+		{"/lights/" + whiteChannelId + "/state", "on", false},
+		{"/lights/" + colorChannelId + "/state", "hue", 21845.0},
+	}
+	requests := gateway.WaitForRequests(t, len(want))
+	for i, w := range want {
+		if requests[i].Path != w.path || requests[i].Body[w.key] != w.want {
+			t.Errorf("request %d = %s %v, want %s with %s %v", i, requests[i].Path, requests[i].Body, w.path, w.key, w.want)
+		}
+	}
+	if color, white := channelStates(light); !color || white {
+		t.Errorf("color channel on: %t, white channel on: %t, want only the color channel", color, white)
+	}
+
+	// The white channel reporting that it is off doesn't turn the light off
+	channel.UpdateState(deconz.ObjectMap{"on": false})
+	if !light.On.Value() {
+		t.Error("the light is off after the inactive channel was turned off")
+	}
+}
+
+func TestMergedLightRevertsFailedOff(t *testing.T) {
+	gateway, _, light := newMergedLight(t)
+	gateway.SetFailing(true)
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+
+	// Turning the light off fails, so the channels keep their state
+	light.On.C.SetValueRequest(false, write)
+	waitFor(t, "the light is on again", light.On.Value)
+	if color, white := channelStates(light); color || !white {
+		t.Errorf("color channel on: %t, white channel on: %t, want only the white channel", color, white)
+	}
+
+	// An event of the inactive channel is evaluated with the restored state
+	light.channels[0].UpdateState(deconz.ObjectMap{"on": false})
+	if !light.On.Value() {
+		t.Error("the light is off after an event of the channel which is off")
+	}
+}
+
+func TestMergedLightConcurrentUpdates(t *testing.T) {
+	_, _, light := newMergedLight(t)
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+
+	// Commands from HomeKit, events of both channels and reverts run concurrently
+	var wg sync.WaitGroup
+	for _, channel := range light.channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				channel.UpdateState(deconz.ObjectMap{"on": i%2 == 0, "colormode": "hs"})
+			}
+		}()
+	}
+	for i := range 50 {
+		light.On.C.SetValueRequest(i%2 == 0, write)
+		light.Hue.C.SetValueRequest(float64(i), write)
+	}
+	wg.Wait()
+}
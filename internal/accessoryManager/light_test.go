@@ -0,0 +1,491 @@
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/deconz/deconztest"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"fmt"
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"github.com/charmbracelet/log"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLight creates a color light with all characteristics, which doesn't need a gateway
+// as long as no command is sent.
+func newTestLight(t *testing.T) *Light {
+	t.Helper()
+
+	device := &Device{
+		ID:        "00:11:22:33:44:55:66:77",
+		Accessory: accessory.New(accessory.Info{Name: "Test Light"}, accessory.TypeLightbulb),
+		Services:  make(map[string]DeviceService),
+		observers: make(map[string]DeviceService),
+		updateMu:  new(sync.Mutex),
+		log:       log.New(io.Discard),
+	}
+
+	light := NewLight(device, &deconz.Subdevice{UniqueId: "00:11:22:33:44:55:66:77-0b"}, service.TypeLightbulb)
+	light.On = characteristic.NewOn()
+	light.Brightness = characteristic.NewBrightness()
+	light.ColorTemperature = characteristic.NewColorTemperature()
+	light.Hue = characteristic.NewHue()
+	light.Saturation = characteristic.NewSaturation()
+	return light
+}
+
+// connectTestLight sends the commands of a light to a fake gateway.
+func connectTestLight(t *testing.T, light *Light) *deconztest.Gateway {
+	t.Helper()
+
+	gateway := deconztest.NewGateway(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	light.device.client = deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+	return gateway
+}
+
+func TestLightColorModeTransitions(t *testing.T) {
+	light := newTestLight(t)
+
+	// deCONZ keeps reporting the stale values of the other color modes
+	steps := []struct {
+		name    string
+		state   deconz.ObjectMap
+		wantCt  int
+		wantHue float64
+		wantSat float64
+	}{
+		{
+			name:    "ct",
+			state:   deconz.ObjectMap{"colormode": "ct", "ct": 300.0, "hue": 10000.0, "sat": 100.0, "xy": []any{0.7, 0.3}},
+			wantCt:  300,
+			wantHue: 0, wantSat: 0,
+		},
+		{
+			name:    "ct to hs",
+			state:   deconz.ObjectMap{"colormode": "hs", "ct": 153.0, "hue": 21845.0, "sat": 255.0, "xy": []any{0.7, 0.3}},
+			wantCt:  300,
+			wantHue: 120, wantSat: 100,
+		},
+		{
+			// The sRGB blue primary
+			name:    "hs to xy",
+			state:   deconz.ObjectMap{"colormode": "xy", "ct": 153.0, "hue": 21845.0, "sat": 255.0, "xy": []any{0.15, 0.06}},
+			wantCt:  300,
+			wantHue: 240, wantSat: 100,
+		},
+		{
+			name:    "xy to ct",
+			state:   deconz.ObjectMap{"colormode": "ct", "ct": 400.0, "hue": 0.0, "sat": 0.0, "xy": []any{0.3, 0.3}},
+			wantCt:  400,
+			wantHue: 240, wantSat: 100,
+		},
+	}
+
+	for _, step := range steps {
+		light.UpdateState(step.state)
+
+		if got := light.ColorTemperature.Value(); got != step.wantCt {
+			t.Errorf("%s: ColorTemperature = %d, want %d", step.name, got, step.wantCt)
+		}
+		if got := light.Hue.Value(); math.Abs(got-step.wantHue) > 1 {
+			t.Errorf("%s: Hue = %v, want %v", step.name, got, step.wantHue)
+		}
+		if got := light.Saturation.Value(); math.Abs(got-step.wantSat) > 1 {
+			t.Errorf("%s: Saturation = %v, want %v", step.name, got, step.wantSat)
+		}
+	}
+}
+
+func TestLightColorCommandKeepsColorMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		colorMode string
+		wantKeys  []string
+	}{
+		{"hs light", "hs", []string{"hue", "sat"}},
+		{"ct light switches to hs", "ct", []string{"hue", "sat"}},
+		{"xy light", "xy", []string{"xy"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			light := newTestLight(t)
+			gateway := connectTestLight(t, light)
+			light.UpdateState(deconz.ObjectMap{"colormode": tt.colorMode})
+
+			// Pure red
+			light.Saturation.SetValue(100)
+			light.Hue.SetValue(0)
+			light.SetHue(0, 120)
+
+			body := gateway.WaitForRequests(t, 1)[0].Body
+			if len(body) != len(tt.wantKeys) {
+				t.Fatalf("sent %v, want the keys %v", body, tt.wantKeys)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := body[key]; !ok {
+					t.Fatalf("sent %v, want the keys %v", body, tt.wantKeys)
+				}
+			}
+
+			if tt.colorMode == "xy" {
+				// The sRGB red primary
+				xy, _ := body["xy"].([]any)
+				if len(xy) != 2 || math.Abs(xy[0].(float64)-0.64) > 0.001 || math.Abs(xy[1].(float64)-0.33) > 0.001 {
+					t.Errorf("xy = %v, want [0.64 0.33]", body["xy"])
+				}
+			}
+		})
+	}
+}
+
+// testLightState is the color light of newTestLight as reported by /lights/{id}.
+const testLightState = `{
+	"hascolor": true,
+	"name": "Test Light",
+	"state": {"on": %t, "bri": 127, "colormode": "ct", "ct": 300, "reachable": true},
+	"type": "Extended color light",
+	"uniqueid": "00:11:22:33:44:55:66:77-0b"
+}`
+
+func TestLightRefreshesOnRead(t *testing.T) {
+	light := newTestLight(t)
+	light.enableOn()
+	light.enableBrightness()
+	gateway := connectTestLight(t, light)
+	gateway.AddLight(t, "1", fmt.Sprintf(testLightState, true))
+	read := httptest.NewRequest("GET", "/characteristics", nil)
+
+	if value, _ := light.On.C.ValueRequestFunc(read); value != true {
+		t.Errorf("On = %v, want true", value)
+	}
+	if got := light.Brightness.Value(); got != 50 {
+		t.Errorf("Brightness = %d, want 50", got)
+	}
+
+	// A slow gateway must not block the controller, the last known value is returned
+	// and the refresh is applied when the gateway has answered
+	gateway.SetDelay(2 * refreshTimeout)
+	gateway.AddLight(t, "1", fmt.Sprintf(testLightState, false))
+	light.device.client.InvalidateLight(light.ID)
+	start := time.Now()
+	if value, _ := light.On.C.ValueRequestFunc(read); value != true {
+		t.Errorf("On from a slow gateway = %v, want the last known value true", value)
+	}
+	if elapsed := time.Since(start); elapsed > refreshTimeout+250*time.Millisecond {
+		t.Errorf("the read took %v, want at most %v", elapsed, refreshTimeout)
+	}
+	waitFor(t, "the light is off", func() bool { return !light.On.Value() })
+}
+
+func TestLightCapabilitiesFromColorCapabilities(t *testing.T) {
+	// Some lights report a type which doesn't match their capabilities, the bitmask wins
+	dimmable := deconz.ObjectMap{"on": true, "bri": 254.0}
+	tests := []struct {
+		name string
+		bits int
+		want lightCapabilities
+	}{
+		{
+			name: "color temperature only",
+			bits: colorCapabilityColorTemperature,
+			want: lightCapabilities{brightness: true, colorTemperature: true},
+		},
+		{
+			name: "hue and saturation only",
+			bits: colorCapabilityHueSaturation,
+			want: lightCapabilities{brightness: true, color: true},
+		},
+		{
+			name: "xy only",
+			bits: colorCapabilityXY,
+			want: lightCapabilities{brightness: true, color: true},
+		},
+		{
+			name: "enhanced hue with color loop",
+			bits: colorCapabilityEnhancedHue | colorCapabilityColorLoop,
+			want: lightCapabilities{brightness: true, color: true, colorLoop: true},
+		},
+		{
+			// 0x1f, as reported by most extended color lights
+			name: "all",
+			bits: colorCapabilityHueSaturation | colorCapabilityEnhancedHue | colorCapabilityColorLoop | colorCapabilityXY | colorCapabilityColorTemperature,
+			want: lightCapabilities{brightness: true, colorTemperature: true, color: true, colorLoop: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := &deconz.Light{ColorCapabilities: &tt.bits}
+			if got := lightCapabilitiesOf(deconz.ExtendedColorLightDevice, dimmable, details); got != tt.want {
+				t.Errorf("lightCapabilitiesOf(%#x) = %+v, want %+v", tt.bits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLightCapabilitiesWithoutColorCapabilities(t *testing.T) {
+	ctMin, ctMax := 153, 454
+	zero := 0
+	tests := []struct {
+		name      string
+		lightType deconz.DeviceType
+		state     deconz.ObjectMap
+		details   *deconz.Light
+		want      lightCapabilities
+	}{
+		{
+			name:      "color temperature type",
+			lightType: deconz.ColorTemperatureLightDevice,
+			state:     deconz.ObjectMap{"bri": 254.0, "ct": 300.0},
+			want:      lightCapabilities{brightness: true, colorTemperature: true},
+		},
+		{
+			// A bitmask of 0 isn't a valid report, the type is used instead
+			name:      "empty bitmask",
+			lightType: deconz.ColorLightDevice,
+			state:     deconz.ObjectMap{"bri": 254.0},
+			details:   &deconz.Light{ColorCapabilities: &zero},
+			want:      lightCapabilities{brightness: true, color: true, colorLoop: true},
+		},
+		{
+			name:      "dimmable with a color temperature range",
+			lightType: deconz.DimmableLightDevice,
+			state:     deconz.ObjectMap{"bri": 254.0},
+			details:   &deconz.Light{CtMin: &ctMin, CtMax: &ctMax},
+			want:      lightCapabilities{brightness: true, colorTemperature: true},
+		},
+		{
+			name:      "extended color light in xy mode without color temperature",
+			lightType: deconz.ExtendedColorLightDevice,
+			state:     deconz.ObjectMap{"bri": 254.0, "colormode": "xy", "xy": []any{0.3, 0.3}},
+			want:      lightCapabilities{brightness: true, color: true, colorLoop: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lightCapabilitiesOf(tt.lightType, tt.state, tt.details); got != tt.want {
+				t.Errorf("lightCapabilitiesOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLightAppliesMultiFieldEventTogether(t *testing.T) {
+	light := newTestLight(t)
+
+	// Record the order of the updates and the values seen when the light turns on
+	updates := []string{}
+	var brightnessWhenOn, ctWhenOn int
+	light.Brightness.OnValueUpdate(func(int, int, *http.Request) { updates = append(updates, "bri") })
+	light.ColorTemperature.OnValueUpdate(func(int, int, *http.Request) { updates = append(updates, "ct") })
+	light.On.OnValueUpdate(func(bool, bool, *http.Request) {
+		updates = append(updates, "on")
+		brightnessWhenOn, ctWhenOn = light.Brightness.Value(), light.ColorTemperature.Value()
+	})
+
+	light.UpdateState(message(t, `{"t":"event","e":"changed","r":"lights","id":"1","uniqueid":"00:11:22:33:44:55:66:77-0b",
+		"state":{"on":true,"bri":254,"colormode":"ct","ct":400}}`).State)
+
+	if !light.On.Value() || light.Brightness.Value() != 100 || light.ColorTemperature.Value() != 400 {
+		t.Errorf("on, brightness and ct = %t %d %d, want true 100 400", light.On.Value(), light.Brightness.Value(), light.ColorTemperature.Value())
+	}
+
+	// The light is turned on last, with its new brightness and color temperature
+	if len(updates) != 3 || updates[2] != "on" {
+		t.Errorf("updates = %v, want bri and ct before on", updates)
+	}
+	if brightnessWhenOn != 100 || ctWhenOn != 400 {
+		t.Errorf("brightness and ct when turned on = %d %d, want 100 400", brightnessWhenOn, ctWhenOn)
+	}
+}
+
+func TestLightBrightnessCapability(t *testing.T) {
+	tests := []struct {
+		name      string
+		lightType deconz.DeviceType
+		state     deconz.ObjectMap
+		want      bool
+	}{
+		{"dimmable light", deconz.DimmableLightDevice, deconz.ObjectMap{"on": true}, true},
+		{"color light", deconz.ExtendedColorLightDevice, deconz.ObjectMap{"on": true}, true},
+		{"on/off light", deconz.OnOffLightDevice, deconz.ObjectMap{"on": true}, false},
+		{"on/off light reporting a brightness", deconz.OnOffLightDevice, deconz.ObjectMap{"on": true, "bri": 254.0}, true},
+		{"on/off output", deconz.OnOffOutputDevice, deconz.ObjectMap{"on": true}, false},
+		{"plug", deconz.OnOffPlugInUnitDevice, deconz.ObjectMap{"on": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lightCapabilitiesOf(tt.lightType, tt.state, nil).brightness; got != tt.want {
+				t.Errorf("brightness = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputAsLightbulbHasNoBrightness(t *testing.T) {
+	gateway := deconztest.NewGateway(t)
+	gateway.AddLight(t, "1", `{
+		"type": "On/Off output",
+		"uniqueid": "00:11:22:33:44:55:66:99-01",
+		"state": {"alert": "none", "on": false, "reachable": true}
+	}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+
+	config := &deconz.Device{
+		UniqueId: "00:11:22:33:44:55:66:99",
+		Name:     "Relay",
+		Subdevices: []deconz.Subdevice{{
+			Type:     deconz.OnOffOutputDevice,
+			UniqueId: "00:11:22:33:44:55:66:99-01",
+			State:    extendedMap(t, `{"on": {"value": false}, "reachable": {"value": true}}`),
+		}},
+	}
+	overrides := map[string]deviceConfiguration.Override{config.UniqueId: {Service: deviceConfiguration.ServiceLightbulb}}
+	device := newTestDevice(t, client, config, Options{Overrides: overrides})
+
+	light, ok := device.Services["00:11:22:33:44:55:66:99-01"].(*Light)
+	if !ok {
+		t.Fatalf("the output is exposed as %T, want *Light", device.Services["00:11:22:33:44:55:66:99-01"])
+	}
+	if light.S().Type != service.TypeLightbulb {
+		t.Errorf("service type = %s, want a lightbulb", light.S().Type)
+	}
+	if light.Brightness != nil {
+		t.Error("the output has a brightness")
+	}
+}
+
+func TestLightInfersOnFromBrightness(t *testing.T) {
+	steps := []struct {
+		name    string
+		state   deconz.ObjectMap
+		wantOn  bool
+		wantBri int
+	}{
+		{"brightness only", deconz.ObjectMap{"bri": 127.0}, true, 50},
+		{"explicit off", deconz.ObjectMap{"on": false, "bri": 127.0}, false, 50},
+		{"brightness of 0", deconz.ObjectMap{"bri": 0.0}, false, 0},
+		{"brightness only again", deconz.ObjectMap{"bri": 254.0}, true, 100},
+		{"off only", deconz.ObjectMap{"on": false}, false, 100},
+	}
+
+	light := newTestLight(t)
+	for _, step := range steps {
+		light.UpdateState(step.state)
+		if got := light.On.Value(); got != step.wantOn {
+			t.Errorf("%s: On = %t, want %t", step.name, got, step.wantOn)
+		}
+		if got := light.Brightness.Value(); got != step.wantBri {
+			t.Errorf("%s: Brightness = %d, want %d", step.name, got, step.wantBri)
+		}
+	}
+}
+
+func TestLightDoesNotForwardDuplicateCommands(t *testing.T) {
+	light := newTestLight(t)
+	light.enableOn()
+	light.enableBrightness()
+	gateway := connectTestLight(t, light)
+	light.UpdateState(deconz.ObjectMap{"on": true, "bri": 127.0})
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+
+	// The light is already on
+	light.On.C.SetValueRequest(true, write)
+
+	// The slider is dragged back to its start value within the debounce delay
+	start := light.Brightness.Value()
+	light.Brightness.C.SetValueRequest(start+30, write)
+	light.Brightness.C.SetValueRequest(start, write)
+	time.Sleep(2 * debounceDelay)
+
+	// Only the change is sent
+	light.On.C.SetValueRequest(false, write)
+	gateway.WaitForRequests(t, 1)
+	time.Sleep(2 * debounceDelay)
+	requests := gateway.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d commands, want 1: %v", len(requests), requests)
+	}
+	if on, ok := requests[0].Body["on"]; !ok || on != false || len(requests[0].Body) != 1 {
+		t.Errorf("sent %v, want {on: false}", requests[0].Body)
+	}
+}
+
+func TestLightForwardsUnchangedValueAfterFailedCommand(t *testing.T) {
+	light := newTestLight(t)
+	light.enableOn()
+	light.enableBrightness()
+	gateway := connectTestLight(t, light)
+	light.UpdateState(deconz.ObjectMap{"on": true, "bri": 127.0})
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+	start := light.Brightness.Value()
+
+	// The command fails, so HomeKit shows the previous value again
+	gateway.SetFailing(true)
+	light.Brightness.C.SetValueRequest(start+30, write)
+	gateway.WaitForRequests(t, 1)
+	waitFor(t, "the brightness is reverted", func() bool { return light.Brightness.Value() == start })
+
+	// The light may have applied the failed command, so dragging the slider
+	// back to the shown value is forwarded
+	gateway.SetFailing(false)
+	light.Brightness.C.SetValueRequest(start+10, write)
+	light.Brightness.C.SetValueRequest(start, write)
+	requests := gateway.WaitForRequests(t, 2)
+	if bri := requests[1].Body["bri"]; bri != 126.0 {
+		t.Errorf("sent %v, want the brightness of %d%%", requests[1].Body, start)
+	}
+}
+
+func TestLightBrightnessScaling(t *testing.T) {
+	tests := []struct {
+		percent int
+		bri     float64
+	}{
+		{1, 1},
+		{50, 126},
+		{100, 254},
+	}
+
+	light := newTestLight(t)
+	light.enableBrightness()
+	gateway := connectTestLight(t, light)
+	write := httptest.NewRequest("PUT", "/characteristics", nil)
+
+	for i, tt := range tests {
+		// The percentage from HomeKit is sent in the usable deCONZ range 1-254
+		light.Brightness.C.SetValueRequest(tt.percent, write)
+		body := gateway.WaitForRequests(t, i+1)[i].Body
+		if got := body["bri"]; got != tt.bri {
+			t.Errorf("%d%%: sent bri %v, want %v", tt.percent, got, tt.bri)
+		}
+
+		// The brightness reported back by deCONZ shows the same percentage
+		light.Brightness.SetValue(0)
+		light.UpdateState(deconz.ObjectMap{"bri": body["bri"]})
+		if got := light.Brightness.Value(); got != tt.percent {
+			t.Errorf("%d%%: Brightness after the round trip = %d", tt.percent, got)
+		}
+	}
+
+	// The reserved value 255 is shown as full brightness
+	light.UpdateState(deconz.ObjectMap{"bri": 255.0})
+	if got := light.Brightness.Value(); got != 100 {
+		t.Errorf("Brightness for bri 255 = %d, want 100", got)
+	}
+}
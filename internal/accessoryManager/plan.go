@@ -0,0 +1,65 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WritePlan writes a human-readable overview of the accessories which are exposed to HomeKit,
+// including the subdevices and devices which are skipped and why.
+// This is used to preview the mapping without starting the HomeKit server.
+//
+// Parameters:
+//   - w: The writer to write the overview to
+func (am *AccessoryManager) WritePlan(w io.Writer) {
+	devices := slices.SortedFunc(maps.Values(am.Devices), func(a, b *Device) int {
+		return cmp.Compare(a.Accessory.Info.Name.Value(), b.Accessory.Info.Name.Value())
+	})
+
+	_, _ = fmt.Fprintf(w, "Accessories (%d, at most %d are exposed):\n", am.AccessoryCount(), MaxAccessories)
+	for _, device := range devices {
+		_, _ = fmt.Fprintf(w, "  %s (%s, %s)\n", device.Accessory.Info.Name.Value(), device.ID, device.model)
+		for _, id := range slices.Sorted(maps.Keys(device.Services)) {
+			_, _ = fmt.Fprintf(w, "    - %s: %s\n", id, serviceName(device.Services[id]))
+		}
+	}
+
+	groups := slices.SortedFunc(maps.Values(am.Groups), func(a, b *Group) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	for _, group := range groups {
+		_, _ = fmt.Fprintf(w, "  %s (group %s)\n", group.Accessory.Info.Name.Value(), group.ID)
+	}
+
+	if len(am.unsupported) > 0 {
+		_, _ = fmt.Fprintf(w, "\nUnsupported subdevices (%d):\n", len(am.unsupported))
+		for _, sub := range am.unsupported {
+			_, _ = fmt.Fprintf(w, "  - %s (%s, %s): type %q is not supported\n", sub.UniqueId, sub.Name, sub.Model, sub.Type)
+		}
+	}
+
+	if len(am.skipped) > 0 {
+		_, _ = fmt.Fprintf(w, "\nSkipped devices (%d):\n", len(am.skipped))
+		for _, device := range am.skipped {
+			_, _ = fmt.Fprintf(w, "  - %s (%s, %s): %s\n", device.UniqueId, device.Name, device.Model, device.Reason)
+		}
+	}
+}
+
+// serviceName returns a readable name of a service implementation (e.g. "Light").
+//
+// Parameters:
+//   - s: The service
+//
+// Returns:
+//   - string: The name of the service type
+func serviceName(s DeviceService) string {
+	name := fmt.Sprintf("%T", s)
+	return name[strings.LastIndex(name, ".")+1:]
+}
@@ -0,0 +1,99 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"github.com/charmbracelet/log"
+	"time"
+)
+
+// eventStateKeys are the state values which report events instead of states (e.g. button presses).
+// They are removed from polled states, because the event was already reported (or missed) and
+// must not be triggered again.
+var eventStateKeys = []string{"buttonevent", "rotaryevent", "expectedrotation", "expectedeventduration", "gesture", "vibration", "action"}
+
+// Poll periodically fetches the state of all lights and sensors from the deCONZ gateway and
+// feeds it through the same path as the WebSocket events. This is a backstop for firmwares
+// which drop WebSocket events, the WebSocket is still used for immediate updates.
+// This blocks until the context is cancelled.
+//
+// Parameters:
+//   - ctx: Context for cancelling the polling
+//   - interval: The interval between two polls
+//   - log: The logger for failed polls
+func (am *AccessoryManager) Poll(ctx context.Context, interval time.Duration, log *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := am.poll(); err != nil {
+				log.Warnf("failed to poll the device states: %v", err)
+			}
+		}
+	}
+}
+
+// poll fetches the state of all lights and sensors once and updates the services.
+//
+// Returns:
+//   - error: Any error encountered during the API requests
+func (am *AccessoryManager) poll() error {
+	lights, err := am.client.GetAllLights()
+	if err != nil {
+		return err
+	}
+	for id, light := range lights {
+		state := light.State.ObjectMap()
+		am.processUpdate(polledMessage(deconz.LightsRessource, id, light.UniqueID, light.LastSeen, state, nil))
+	}
+
+	sensors, err := am.client.GetAllSensors()
+	if err != nil {
+		return err
+	}
+	for id, sensor := range sensors {
+		for _, key := range eventStateKeys {
+			delete(sensor.State, key)
+		}
+		am.processUpdate(polledMessage(deconz.SensorsRessource, id, sensor.UniqueId, sensor.LastSeen, sensor.State, sensor.Config))
+	}
+
+	return nil
+}
+
+// polledMessage builds a changed event from a polled resource, so it can be processed
+// like an event from the WebSocket.
+//
+// Parameters:
+//   - ressourceType: The type of the resource (lights or sensors)
+//   - id: The identifier of the resource
+//   - uniqueId: The unique ID of the resource
+//   - lastSeen: The timestamp when the resource was last seen by the gateway
+//   - state: The state of the resource
+//   - config: The configuration of the resource (nil for lights)
+//
+// Returns:
+//   - *deconz.Messsage: A pointer to the changed event
+func polledMessage(ressourceType deconz.RessourceType, id, uniqueId, lastSeen string, state, config deconz.ObjectMap) *deconz.Messsage {
+	msg := &deconz.Messsage{
+		Type:          "event",
+		EventType:     deconz.ChangedEvent,
+		RessourceType: ressourceType,
+		RessourceID:   &id,
+		UniqueID:      &uniqueId,
+		State:         &state,
+	}
+	if config != nil {
+		msg.Config = &config
+	}
+	if len(lastSeen) > 0 {
+		msg.Attr = &deconz.ObjectMap{"lastseen": lastSeen}
+	}
+	return msg
+}
@@ -0,0 +1,59 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// exclusiveService fails the test if its state is updated concurrently.
+type exclusiveService struct {
+	t       *testing.T
+	active  atomic.Int32
+	updates atomic.Int32
+}
+
+func (s *exclusiveService) UpdateState(deconz.MapObject) {
+	if s.active.Add(1) > 1 {
+		s.t.Error("the state was updated concurrently")
+	}
+	time.Sleep(time.Millisecond)
+	s.updates.Add(1)
+	s.active.Add(-1)
+}
+
+func (s *exclusiveService) UpdateConfig(deconz.MapObject) {}
+
+func (s *exclusiveService) S() *service.S { return nil }
+
+func TestPollAndEventsAreSerialized(t *testing.T) {
+	_, am := newTestGateway(t)
+	plug := &exclusiveService{t: t}
+	am.Services["00:11:22:33:44:55:66:88-01"] = plug
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			if err := am.poll(); err != nil {
+				t.Errorf("poll() error = %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			am.ProcessUpdate(message(t, `{"t":"event","e":"changed","r":"lights","id":"1","uniqueid":"00:11:22:33:44:55:66:88-01","state":{"on":true}}`))
+		}
+	}()
+	wg.Wait()
+
+	if got := plug.updates.Load(); got != 40 {
+		t.Errorf("updates = %d, want 40", got)
+	}
+}
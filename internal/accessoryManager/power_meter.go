@@ -0,0 +1,163 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"errors"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"maps"
+	"slices"
+)
+
+// PowerMeter represents the power metering of a smart plug or an energy meter in HomeKit.
+// It implements the DeviceService interface and attaches Eve characteristics
+// for power, voltage, current, and total consumption to the outlet or switch service of the device.
+// Devices without an outlet or switch (e.g. DIN rail energy meters) get a separate meter service,
+// which is shared by their power and consumption subdevices.
+type PowerMeter struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the separate meter service (nil if the characteristics are added to an outlet or switch)
+	service *service.S
+
+	// power is the Eve characteristic for the current power in W
+	power *characteristic.Float
+
+	// voltage is the Eve characteristic for the voltage in V
+	voltage *characteristic.Float
+
+	// current is the Eve characteristic for the electric current in A
+	current *characteristic.Float
+
+	// consumption is the Eve characteristic for the total consumption in kWh
+	consumption *characteristic.Float
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: The separate meter service, or nil if the characteristics are added
+//     to the outlet or switch service of the device
+func (meter *PowerMeter) S() *service.S {
+	return meter.service
+}
+
+// UpdateState updates the power meter's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (meter *PowerMeter) UpdateState(state deconz.MapObject) {
+	// deCONZ reports the power in W
+	if state.Has("power") && meter.power != nil {
+		meter.power.SetValue(float64(state.ValueToInt("power")))
+	}
+
+	// deCONZ reports the voltage in V
+	if state.Has("voltage") && meter.voltage != nil {
+		meter.voltage.SetValue(float64(state.ValueToInt("voltage")))
+	}
+
+	// deCONZ reports the current in mA, Eve expects A
+	if state.Has("current") && meter.current != nil {
+		meter.current.SetValue(float64(state.ValueToInt("current")) / 1000.0)
+	}
+
+	// deCONZ reports the consumption in Wh, Eve expects kWh
+	if state.Has("consumption") && meter.consumption != nil {
+		meter.consumption.SetValue(float64(state.ValueToInt("consumption")) / 1000.0)
+	}
+}
+
+// UpdateConfig updates the power meter's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for power meters)
+func (meter *PowerMeter) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// meteredService returns the service the power characteristics are added to.
+// This is the outlet or switch of the device, or the meter service added for another
+// subdevice of the same meter. Lights are processed before sensors, so they already exist.
+// The services are checked in the order of their IDs, so that the result is the same on every start.
+//
+// Returns:
+//   - *service.S: A pointer to the service, or nil if the device has none of them
+func (device *Device) meteredService() *service.S {
+	for _, id := range slices.Sorted(maps.Keys(device.Services)) {
+		switch s := device.Services[id].(type) {
+		case *Light:
+			if s.service.Type == service.TypeOutlet || s.service.Type == service.TypeSwitch {
+				return s.service
+			}
+		case *PowerMeter:
+			if s.service != nil {
+				return s.service
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewPowerMeter creates a new power meter service.
+// This is used for the power and consumption subdevices of smart plugs and energy meters.
+// The Eve characteristics are only added if they are enabled in the options,
+// because the Apple Home app doesn't support them.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewPowerMeter(config *deconz.Subdevice) error {
+	if !device.options.EveCharacteristics {
+		return errors.New("eve characteristics are disabled")
+	}
+
+	meter := new(PowerMeter)
+	meter.device = device
+
+	// The characteristics are attached to the outlet of the smart plug,
+	// energy meters without an outlet get a separate service
+	outlet := device.meteredService()
+	if outlet == nil {
+		meter.service = service.New(TypePowerMeterService)
+		name := characteristic.NewName()
+		name.SetValue("Energy Meter")
+		meter.service.AddC(name.C)
+		outlet = meter.service
+	}
+
+	// Add a characteristic for each value reported by the subdevice
+	// Values which are reported by several subdevices (e.g. the power) are only added once
+	add := func(key string, typ string, description string) *characteristic.Float {
+		if !config.State.Has(key) || outlet.C(typ) != nil {
+			return nil
+		}
+		c := newEveCharacteristic(typ, description)
+		outlet.AddC(c.C)
+		return c
+	}
+	meter.power = add("power", TypeEvePower, "Power")
+	meter.voltage = add("voltage", TypeEveVoltage, "Voltage")
+	meter.current = add("current", TypeEveCurrent, "Current")
+	meter.consumption = add("consumption", TypeEveTotalConsumption, "Total Consumption")
+
+	// Initialize the power meter state from the current deCONZ state
+	meter.UpdateState(config.State)
+
+	// Register the service with the device, the separate meter service is added to the accessory once
+	if meter.service != nil {
+		device.addDeviceService(config.UniqueId, meter)
+	} else {
+		device.Services[config.UniqueId] = meter
+	}
+	return nil
+}
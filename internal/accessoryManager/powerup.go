@@ -0,0 +1,44 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"maps"
+	"slices"
+)
+
+// ApplyPowerUp applies the powerup settings configured in the overrides to the lights.
+// HomeKit has no characteristic for the state of a light after a power cut, so the setting
+// is managed by the overrides instead. Only settings which differ from deCONZ are written,
+// so the lights are not reconfigured on every start.
+func (am *AccessoryManager) ApplyPowerUp() {
+	for _, device := range am.Devices {
+		for _, id := range slices.Sorted(maps.Keys(device.Services)) {
+			// The channels of merged lights are configured like separate lights
+			switch device.Services[id].(type) {
+			case *Light, *lightChannel:
+			default:
+				continue
+			}
+			powerUp, ok := am.options.powerUp(device.ID, id)
+			if !ok {
+				continue
+			}
+
+			current, err := am.client.GetLightPowerup(id)
+			if err != nil {
+				device.log.Warnf("failed to get the powerup setting: %v", err)
+				continue
+			}
+			if current == powerUp {
+				continue
+			}
+
+			if err := am.client.SetLightPowerup(id, powerUp); err != nil {
+				device.log.Warnf("failed to set the powerup setting: %v", err)
+				continue
+			}
+			device.log.Infof("changed the powerup setting from %d to %d", current, powerUp)
+		}
+	}
+}
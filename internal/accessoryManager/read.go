@@ -0,0 +1,123 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"github.com/brutella/hap/characteristic"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refreshTimeout is the time a read waits for the refresh of the values,
+// before the last known value is returned.
+const refreshTimeout = 500 * time.Millisecond
+
+// refresher refreshes the values of a service from the current state in deCONZ.
+// The refresh runs in the background, so a slow or unreachable gateway doesn't block the
+// reads of the controllers, and reads of several characteristics share the running refresh.
+// It is safe for concurrent use.
+type refresher struct {
+	// refresh is the function updating the service from the current state in deCONZ
+	refresh func()
+
+	// mu guards done
+	mu sync.Mutex
+
+	// done is closed when the running refresh has finished, it is nil if no refresh is running
+	done chan struct{}
+}
+
+// newRefresher creates a new refresher.
+//
+// Parameters:
+//   - refresh: The function updating the service from the current state in deCONZ
+//
+// Returns:
+//   - *refresher: A pointer to the initialized refresher
+func newRefresher(refresh func()) *refresher {
+	return &refresher{refresh: refresh}
+}
+
+// start starts a refresh unless one is already running.
+//
+// Returns:
+//   - <-chan struct{}: A channel which is closed when the refresh has finished
+func (r *refresher) start() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done == nil {
+		done := make(chan struct{})
+		r.done = done
+		go func() {
+			defer close(done)
+			r.refresh()
+
+			r.mu.Lock()
+			r.done = nil
+			r.mu.Unlock()
+		}()
+	}
+	return r.done
+}
+
+// wait refreshes the values and waits until the refresh has finished or the timeout has passed.
+// A refresh which takes longer continues in the background and updates the values when it is done.
+//
+// Parameters:
+//   - timeout: The maximum time to wait for the refresh
+func (r *refresher) wait(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.start():
+	case <-timer.C:
+	}
+}
+
+// refreshOnRead registers a read handler for a characteristic, which refreshes the values
+// of the service from deCONZ before the value is returned. The characteristics always keep
+// the last known value, which is initialized from deCONZ and updated by events and polling,
+// but a freshly connected controller would otherwise see a stale value if an event was missed.
+// If the refresh fails or doesn't finish within refreshTimeout, the last known value is returned.
+//
+// Parameters:
+//   - c: The characteristic whose reads refresh the values
+//   - r: The refresher of the service
+func refreshOnRead(c *characteristic.C, r *refresher) {
+	c.ValueRequestFunc = func(req *http.Request) (interface{}, int) {
+		// Only refresh for requests of controllers, not when the accessories are serialized
+		if req != nil {
+			r.wait(refreshTimeout)
+		}
+		return c.Value(), 0
+	}
+}
+
+// sensorRefresher creates a refresher which updates a sensor service from the current state in deCONZ.
+// The states of all sensors are fetched with a single request, which is reused for a short time,
+// so a controller reading all sensors at once doesn't cause a request per sensor.
+// The state is applied while holding the update lock of the device, so it doesn't interleave
+// with an event or poll updating the same sensor.
+//
+// Parameters:
+//   - id: The unique ID of the sensor
+//   - s: The service to update
+//
+// Returns:
+//   - *refresher: The refresher of the service, for refreshOnRead
+func (device *Device) sensorRefresher(id string, s DeviceService) *refresher {
+	return newRefresher(func() {
+		details, err := device.client.GetCurrentSensor(id)
+		if err != nil {
+			device.log.Debugf("failed to refresh the state of %s: %v", id, err)
+			return
+		}
+
+		device.updateMu.Lock()
+		defer device.updateMu.Unlock()
+		s.UpdateState(details.State)
+	})
+}
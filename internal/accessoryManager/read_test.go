@@ -0,0 +1,68 @@
+package accessoryManager
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/deconz/deconztest"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testSensorState is the sensor of sensorDevice as reported by /sensors/{id}.
+const testSensorState = `{
+	"config": {"on": true, "reachable": true},
+	"name": "Test Sensor",
+	"state": {"lastupdated": "2024-05-01T10:02:11.123", "temperature": 2300},
+	"type": "ZHATemperature",
+	"uniqueid": "00:11:22:33:44:55:66:77-01-0500"
+}`
+
+// newTestSensor creates a temperature sensor of 21.5 °C whose reads are refreshed from a fake
+// gateway, which reports 23 °C.
+func newTestSensor(t *testing.T) (*deconztest.Gateway, *TemperatureSensor) {
+	t.Helper()
+
+	gateway := deconztest.NewGateway(t)
+	gateway.AddSensor(t, "5", testSensorState)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := deconz.NewApiClient(ctx, gateway.URL, deconztest.APIKey)
+	client.SetLogger(testLogger{})
+
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	device := newTestDevice(t, client, config, Options{})
+	return gateway, device.Services[config.Subdevices[0].UniqueId].(*TemperatureSensor)
+}
+
+func TestSensorRefreshesOnRead(t *testing.T) {
+	_, sensor := newTestSensor(t)
+	read := httptest.NewRequest("GET", "/characteristics", nil)
+
+	// Serializing the accessories doesn't refresh the value
+	if value, _ := sensor.service.CurrentTemperature.C.ValueRequestFunc(nil); value != 21.5 {
+		t.Errorf("CurrentTemperature when serialized = %v, want 21.5", value)
+	}
+	if value, _ := sensor.service.CurrentTemperature.C.ValueRequestFunc(read); value != 23.0 {
+		t.Errorf("CurrentTemperature = %v, want 23", value)
+	}
+}
+
+func TestSensorReadFromSlowGateway(t *testing.T) {
+	gateway, sensor := newTestSensor(t)
+	gateway.SetDelay(2 * refreshTimeout)
+	read := httptest.NewRequest("GET", "/characteristics", nil)
+
+	// A slow gateway must not block the controller, the last known value is returned
+	// and the refresh is applied when the gateway has answered
+	start := time.Now()
+	if value, _ := sensor.service.CurrentTemperature.C.ValueRequestFunc(read); value != 21.5 {
+		t.Errorf("CurrentTemperature from a slow gateway = %v, want the last known value 21.5", value)
+	}
+	if elapsed := time.Since(start); elapsed > refreshTimeout+250*time.Millisecond {
+		t.Errorf("the read took %v, want at most %v", elapsed, refreshTimeout)
+	}
+	waitFor(t, "the temperature is refreshed", func() bool {
+		return sensor.service.CurrentTemperature.Value() == 23.0
+	})
+}
@@ -0,0 +1,103 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// AlarmSensor represents a generic alarm sensor in HomeKit.
+// It implements the DeviceService interface. HomeKit has no generic alarm service,
+// so an active alarm (e.g. of an SOS button or a siren) is reported as detected motion.
+type AlarmSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit motion sensor service
+	service *service.MotionSensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *AlarmSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *AlarmSensor) UpdateState(state deconz.MapObject) {
+	// Update the motion state if the state contains an "alarm" value
+	// The alarm is reported until deCONZ resets it
+	if state.Has("alarm") {
+		v := state.ValueToBool("alarm")
+		if v && !sensor.service.MotionDetected.Value() {
+			sensor.device.log.Info("alarm detected")
+		}
+		sensor.service.MotionDetected.SetValue(v)
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *AlarmSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewAlarmSensor creates a new alarm sensor service.
+// This is used for ZHAAlarm sensors, which report a generic alarm from the IAS zone of a device.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewAlarmSensor(config *deconz.Subdevice) error {
+	sensor := new(AlarmSensor)
+	sensor.device = device
+
+	// Create a new HomeKit motion sensor service
+	sensor.service = service.NewMotionSensor()
+	refreshOnRead(sensor.service.MotionDetected.C, device.sensorRefresher(config.UniqueId, sensor))
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,149 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// keypadActions maps the actions reported by deCONZ keypads to the one-based index of
+// the button they trigger. Emergency, fire and panic all trigger the emergency button.
+var keypadActions = map[string]int{
+	"disarmed":    1,
+	"armed_stay":  2,
+	"armed_night": 3,
+	"armed_away":  4,
+	"emergency":   5,
+	"fire":        5,
+	"panic":       5,
+}
+
+// keypadButtonCount is the number of buttons exposed for a keypad.
+const keypadButtonCount = 5
+
+// AncillaryControl represents an alarm keypad or panel in HomeKit.
+// It implements the DeviceService interface. HomeKit has no keypad service and the
+// security system service needs a state that is controlled by the bridge, so the actions
+// of the keypad (disarm, arm stay, arm night, arm away and emergency) are exposed as the
+// buttons of a stateless programmable switch, which can trigger automations.
+type AncillaryControl struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// buttons contains a stateless programmable switch service for each action,
+	// ordered by their index
+	buttons []*service.StatelessProgrammableSwitch
+
+	// panel is the last panel state set by the gateway (e.g. "armed_away" or "exit_delay")
+	panel string
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the keypad reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+// Like SwitchDevice, this returns nil because each action has its own service.
+//
+// Returns:
+//   - *service.S: Always nil for AncillaryControl
+func (keypad *AncillaryControl) S() *service.S {
+	return nil
+}
+
+// UpdateState updates the keypad's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// An "action" triggers the button of the action, the "panel" state is only logged,
+// because it is set by the gateway and not by the keypad. deCONZ sends the complete state,
+// so updates which change the panel state repeat the last action and are not treated as a keypress.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (keypad *AncillaryControl) UpdateState(state deconz.MapObject) {
+	// Update the low battery characteristic if available
+	keypad.battery.UpdateState(state)
+
+	if state.Has("panel") {
+		if panel := state.ValueToString("panel"); panel != keypad.panel {
+			keypad.device.log.Infof("panel state changed to %s", panel)
+			keypad.panel = panel
+			return
+		}
+	}
+
+	if state.Has("action") {
+		action := state.ValueToString("action")
+		index, ok := keypadActions[action]
+		if !ok {
+			// e.g. an invalid code was entered
+			keypad.device.log.Infof("ignoring keypad action %s", action)
+			return
+		}
+
+		keypad.device.log.Infof("keypad action %s", action)
+		_ = keypad.buttons[index-1].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventSinglePress)
+	}
+}
+
+// UpdateConfig updates the keypad's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (keypad *AncillaryControl) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	keypad.battery.UpdateConfig(config)
+}
+
+// NewAncillaryControl creates a new keypad service.
+// This is used for ZHAAncillaryControl sensors, e.g. alarm keypads and panels.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewAncillaryControl(config *deconz.Subdevice) error {
+	keypad := new(AncillaryControl)
+	keypad.device = device
+
+	// Declare that the buttons are labeled with numerals, so that HomeKit shows them as a group
+	label := service.NewServiceLabel()
+	_ = label.ServiceLabelNamespace.SetValue(characteristic.ServiceLabelNamespaceArabicNumerals)
+	device.Accessory.AddS(label.S)
+
+	// Add a button for each action, which only supports single presses
+	for index := 1; index <= keypadButtonCount; index++ {
+		indexCharacteristic := characteristic.NewServiceLabelIndex()
+		_ = indexCharacteristic.SetValue(index)
+
+		button := service.NewStatelessProgrammableSwitch()
+		button.ProgrammableSwitchEvent.C.ValidVals = []int{characteristic.ProgrammableSwitchEventSinglePress}
+		button.AddC(indexCharacteristic.C)
+
+		keypad.buttons = append(keypad.buttons, button)
+		device.Accessory.AddS(button.S)
+	}
+
+	// Add a battery service if the keypad reports battery status or level
+	batteryService := service.New(service.TypeBatteryService)
+	keypad.battery = newBattery(batteryService, config, device.options.LowBatteryThreshold)
+	if len(batteryService.Cs) > 0 {
+		device.Accessory.AddS(batteryService)
+	}
+
+	// Initialize the battery and panel state, the last action is not replayed,
+	// because it may have happened long ago
+	keypad.battery.UpdateState(config.State)
+	if config.State.Has("panel") {
+		keypad.panel = config.State.ValueToString("panel")
+	}
+	keypad.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.Services[config.UniqueId] = keypad
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// CarbonMonoxideSensor represents a carbon monoxide sensor in HomeKit.
+// It implements the DeviceService interface and provides functionality for
+// monitoring carbon monoxide from compatible sensors.
+type CarbonMonoxideSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit carbon monoxide sensor service
+	service *service.CarbonMonoxideSensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *CarbonMonoxideSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+//   - config: The updated config object from deCONZ (not used for carbon monoxide sensors)
+func (sensor *CarbonMonoxideSensor) UpdateState(state deconz.MapObject) {
+	// Update the carbon monoxide state if the state contains a "carbonmonoxide" value
+	// Other updates (e.g. of the battery or tamper state) don't reset a detection
+	if state.Has("carbonmonoxide") {
+		// In HomeKit, 1 = carbon monoxide detected, 0 = no carbon monoxide detected
+		v := state.ValueToBool("carbonmonoxide")
+		changed := sensor.service.CarbonMonoxideDetected.Value() != boolToInt[v]
+		_ = sensor.service.CarbonMonoxideDetected.SetValue(boolToInt[v])
+
+		// Log when carbon monoxide is detected (only log new positive detections to reduce noise)
+		if v && changed {
+			sensor.device.log.Info("carbon monoxide detected")
+		}
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *CarbonMonoxideSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewCarbonMonoxideSensor creates a new carbon monoxide sensor service.
+// This is used for sensors that detect carbon monoxide.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewCarbonMonoxideSensor(config *deconz.Subdevice) error {
+	sensor := new(CarbonMonoxideSensor)
+	sensor.device = device
+
+	// Create a new HomeKit carbon monoxide sensor service
+	sensor.service = service.NewCarbonMonoxideSensor()
+	refreshOnRead(sensor.service.CarbonMonoxideDetected.C, device.sensorRefresher(config.UniqueId, sensor))
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,108 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// FireSensor represents a smoke detector in HomeKit.
+// It implements the DeviceService interface and provides functionality for
+// monitoring smoke from compatible sensors.
+type FireSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit smoke sensor service
+	service *service.SmokeSensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *FireSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+//   - config: The updated config object from deCONZ (not used for fire sensors)
+func (sensor *FireSensor) UpdateState(state deconz.MapObject) {
+	// Update the smoke state if the state contains a "fire" value
+	// Other updates (e.g. of the battery or tamper state) don't reset a detection
+	if state.Has("fire") {
+		// In HomeKit, 1 = smoke detected, 0 = no smoke detected
+		v := state.ValueToBool("fire")
+		changed := sensor.service.SmokeDetected.Value() != boolToInt[v]
+		_ = sensor.service.SmokeDetected.SetValue(boolToInt[v])
+
+		// Log when smoke is detected (only log new positive detections to reduce noise)
+		if v && changed {
+			sensor.device.log.Info("smoke detected")
+		}
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *FireSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewFireSensor creates a new fire sensor service.
+// This is used for sensors that detect smoke.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewFireSensor(config *deconz.Subdevice) error {
+	sensor := new(FireSensor)
+	sensor.device = device
+
+	// Create a new HomeKit smoke sensor service
+	sensor.service = service.NewSmokeSensor()
+	refreshOnRead(sensor.service.SmokeDetected.C, device.sensorRefresher(config.UniqueId, sensor))
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,50 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"testing"
+)
+
+func TestAlarmSensorsKeepDetectionOnOtherUpdates(t *testing.T) {
+	tests := []struct {
+		name       string
+		sensorType deconz.DeviceType
+		key        string
+		detected   func(s DeviceService) int
+	}{
+		{"fire", deconz.FireSensorDevice, "fire", func(s DeviceService) int {
+			return s.(*FireSensor).service.SmokeDetected.Value()
+		}},
+		{"carbon monoxide", deconz.CarbonMonoxideDevice, "carbonmonoxide", func(s DeviceService) int {
+			return s.(*CarbonMonoxideSensor).service.CarbonMonoxideDetected.Value()
+		}},
+		{"water", deconz.WaterDevice, "water", func(s DeviceService) int {
+			return s.(*WaterSensor).service.LeakDetected.Value()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := sensorDevice(t, tt.sensorType, `{"`+tt.key+`": {"value": true}, "lowbattery": {"value": false}}`)
+			device := newTestDevice(t, nil, config, Options{})
+			s := device.Services[config.Subdevices[0].UniqueId]
+
+			if got := tt.detected(s); got != 1 {
+				t.Fatalf("initial detection = %d, want 1", got)
+			}
+
+			// Updates without the alarm value keep the detection
+			for _, update := range []deconz.ObjectMap{{"lowbattery": true}, {"tampered": true}, {}} {
+				s.UpdateState(update)
+				if got := tt.detected(s); got != 1 {
+					t.Errorf("detection after %v = %d, want 1", update, got)
+				}
+			}
+
+			s.UpdateState(deconz.ObjectMap{tt.key: false})
+			if got := tt.detected(s); got != 0 {
+				t.Errorf("detection after clearing = %d, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// HumiditySensor represents a humidity sensor in HomeKit.
+// It implements the DeviceService interface and provides functionality for
+// monitoring the relative humidity from compatible sensors.
+type HumiditySensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit humidity sensor service
+	service *service.HumiditySensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *HumiditySensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// deCONZ reports the humidity in hundredths of a percent (e.g. 4550 = 45.5 %).
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *HumiditySensor) UpdateState(state deconz.MapObject) {
+	// Update the humidity if the state contains a "humidity" value
+	if state.Has("humidity") {
+		humidity := float64(state.ValueToInt("humidity")) / 100.0
+		sensor.service.CurrentRelativeHumidity.SetValue(max(0, min(humidity, 100)))
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *HumiditySensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewHumiditySensor creates a new humidity sensor service.
+// This is used for sensors that measure the relative humidity. Weather sensors report
+// temperature, humidity and pressure as separate subdevices, whose services are all
+// added to the accessory of the device.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewHumiditySensor(config *deconz.Subdevice) error {
+	sensor := new(HumiditySensor)
+	sensor.device = device
+
+	// Create a new HomeKit humidity sensor service
+	sensor.service = service.NewHumiditySensor()
+	refreshOnRead(sensor.service.CurrentRelativeHumidity.C, device.sensorRefresher(config.UniqueId, sensor))
+	sensor.service.CurrentRelativeHumidity.SetStepValue(0.01)
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
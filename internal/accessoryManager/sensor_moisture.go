@@ -0,0 +1,108 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// MoistureSensor represents a soil moisture sensor in HomeKit.
+// It implements the DeviceService interface. HomeKit has no soil moisture service,
+// so the moisture is reported as the relative humidity of a humidity sensor,
+// which is the closest native fit and is shown by the Apple Home app.
+type MoistureSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit humidity sensor service
+	service *service.HumiditySensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *MoistureSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// deCONZ reports the moisture in hundredths of a percent like the humidity (e.g. 3520 = 35.2 %).
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *MoistureSensor) UpdateState(state deconz.MapObject) {
+	// Update the humidity if the state contains a "moisture" value
+	if state.Has("moisture") {
+		moisture := float64(state.ValueToInt("moisture")) / 100.0
+		sensor.service.CurrentRelativeHumidity.SetValue(max(0, min(moisture, 100)))
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *MoistureSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewMoistureSensor creates a new soil moisture sensor service.
+// This is used for plant sensors which measure the moisture of the soil.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewMoistureSensor(config *deconz.Subdevice) error {
+	sensor := new(MoistureSensor)
+	sensor.device = device
+
+	// Create a new HomeKit humidity sensor service
+	sensor.service = service.NewHumiditySensor()
+	refreshOnRead(sensor.service.CurrentRelativeHumidity.C, device.sensorRefresher(config.UniqueId, sensor))
+	sensor.service.CurrentRelativeHumidity.SetStepValue(0.01)
+
+	// Name the service, so it can be told apart from the humidity of the air
+	name := characteristic.NewName()
+	name.SetValue("Soil Moisture")
+	sensor.service.AddC(name.C)
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+)
+
+// OnOffSwitch represents a physical on/off input switch in HomeKit.
+// It implements the DeviceService interface and exposes the switch position
+// as a read-only contact sensor, because an input switch can't be commanded.
+type OnOffSwitch struct {
+	// service is the HomeKit contact sensor service
+	service *service.ContactSensor
+
+	// device is a reference to the parent Device
+	device *Device
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *OnOffSwitch) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the switch position based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *OnOffSwitch) UpdateState(state deconz.MapObject) {
+	if !state.Has("on") {
+		return
+	}
+
+	// A switched on input closes the circuit, so it is reported as contact detected
+	// In HomeKit, 0 = detected (closed), 1 = not detected (open)
+	on := state.ValueToBool("on")
+	if sensor.service.ContactSensorState.Value() != boolToInt[!on] {
+		sensor.device.log.Infof("switched %s", onOffStr[on])
+	}
+	_ = sensor.service.ContactSensorState.SetValue(boolToInt[!on])
+}
+
+// UpdateConfig updates the switch's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// On/off switches don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for on/off switches)
+func (sensor *OnOffSwitch) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// NewOnOffSwitch creates a new on/off switch service.
+// This is used for physical input switches (e.g. wall switches) which report their
+// position but can't be switched remotely. Controllable outputs like plugs and relays
+// are created with NewOnOffPlugDevice instead.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewOnOffSwitch(config *deconz.Subdevice) error {
+	sensor := new(OnOffSwitch)
+	sensor.device = device
+
+	// Create a new HomeKit contact sensor service
+	sensor.service = service.NewContactSensor()
+
+	// Initialize the switch position from the current deCONZ state
+	sensor.UpdateState(config.State)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
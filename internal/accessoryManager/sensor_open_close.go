@@ -4,7 +4,6 @@ package accessoryManager
 
 import (
 	"deconz-homekit/internal/deconz"
-	"github.com/brutella/hap/characteristic"
 	"github.com/brutella/hap/service"
 )
 
@@ -15,13 +14,19 @@ type OpenCloseSensor struct {
 	// service is the HomeKit contact sensor service
 	service *service.ContactSensor
 
+	// id is the unique identifier of the sensor in deCONZ
+	id string
+
 	// device is a reference to the parent Device
 	device *Device
 
-	// lowBatteryCharacteristic is the HomeKit characteristic for low battery status
-	// This is optional and only present if the sensor reports battery status
-	lowBatteryCharacteristic   *characteristic.StatusLowBattery
-	batteryLevelCharacteristic *characteristic.BatteryLevel
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
 }
 
 // S returns the underlying HomeKit service.
@@ -42,20 +47,25 @@ func (sensor *OpenCloseSensor) S() *service.S {
 func (sensor *OpenCloseSensor) UpdateState(state deconz.MapObject) {
 	// Update the contact sensor state based on the "open" value from deCONZ
 	// In HomeKit, 1 = detected (open), 0 = not detected (closed)
-	if state.ValueToBool("open") {
-		sensor.device.log.Info("open")
-		_ = sensor.service.ContactSensorState.SetValue(1) // Contact detected (open)
-	} else {
-		sensor.device.log.Info("closed")
-		_ = sensor.service.ContactSensorState.SetValue(0) // Contact not detected (closed)
+	// Only changes are logged, because polled states repeat the current value
+	// Updates without the "open" value (e.g. only a tamper or battery change) keep the current state
+	if state.Has("open") {
+		open := state.ValueToBool("open")
+		if sensor.service.ContactSensorState.Value() != boolToInt[open] {
+			if open {
+				sensor.device.log.Info("open")
+			} else {
+				sensor.device.log.Info("closed")
+			}
+		}
+		_ = sensor.service.ContactSensorState.SetValue(boolToInt[open]) // 1 = open, 0 = closed
 	}
 
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
 	// Update the low battery characteristic if available
-	if state.Has("lowbattery") && sensor.lowBatteryCharacteristic != nil {
-		batteryIsLow := state.ValueToBool("lowbattery")
-		// Convert boolean to int (0 = normal, 1 = low)
-		_ = sensor.lowBatteryCharacteristic.SetValue(boolToInt[batteryIsLow])
-	}
+	sensor.battery.UpdateState(state)
 }
 
 // UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
@@ -64,11 +74,8 @@ func (sensor *OpenCloseSensor) UpdateState(state deconz.MapObject) {
 // Parameters:
 //   - config: The updated configuration object from deCONZ
 func (sensor *OpenCloseSensor) UpdateConfig(config deconz.MapObject) {
-	// Update the battery level characteristic if available
-	if config.Has("battery") && sensor.batteryLevelCharacteristic != nil {
-		batteryLevel := config.ValueToInt("battery")
-		_ = sensor.batteryLevelCharacteristic.SetValue(batteryLevel)
-	}
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
 }
 
 // NewOpenCloseSensor creates a new open/close sensor service.
@@ -81,22 +88,21 @@ func (sensor *OpenCloseSensor) UpdateConfig(config deconz.MapObject) {
 //   - error: An error if the service could not be created
 func (device *Device) NewOpenCloseSensor(config *deconz.Subdevice) error {
 	sensor := new(OpenCloseSensor)
+	sensor.id = config.UniqueId
 	sensor.device = device
 
 	// Create a new HomeKit contact sensor service
 	sensor.service = service.NewContactSensor()
+	refreshOnRead(sensor.service.ContactSensorState.C, device.sensorRefresher(config.UniqueId, sensor))
 
-	// Add the low battery characteristic if the sensor reports battery status
-	if config.State.Has("lowbattery") {
-		sensor.lowBatteryCharacteristic = characteristic.NewStatusLowBattery()
-		sensor.service.AddC(sensor.lowBatteryCharacteristic.C)
-	}
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
 
-	// Add the battery level characteristic if the sensor reports battery config
-	if config.Config.Has("battery") {
-		sensor.batteryLevelCharacteristic = characteristic.NewBatteryLevel()
-		sensor.service.AddC(sensor.batteryLevelCharacteristic.C)
-	}
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
 
 	// Initialize the sensor state from the current deCONZ state
 	sensor.UpdateState(config.State)
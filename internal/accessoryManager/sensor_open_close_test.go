@@ -0,0 +1,44 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"testing"
+)
+
+func TestOpenCloseSensorWithoutOpenState(t *testing.T) {
+	tests := []struct {
+		name           string
+		state          string
+		wantLowBattery bool
+	}{
+		{"no values", `{}`, false},
+		{"only low battery", `{"lowbattery": {"value": true}}`, true},
+		{"only tampered", `{"tampered": {"value": false}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := sensorDevice(t, deconz.OpenCloseSensorDevice, tt.state)
+			device := newTestDevice(t, nil, config, Options{})
+			sensor, ok := device.Services[config.Subdevices[0].UniqueId].(*OpenCloseSensor)
+			if !ok {
+				t.Fatalf("the sensor is exposed as %T, want *OpenCloseSensor", device.Services[config.Subdevices[0].UniqueId])
+			}
+
+			// Sensors start closed until they report their state
+			if got := sensor.service.ContactSensorState.Value(); got != 0 {
+				t.Errorf("ContactSensorState = %d, want 0", got)
+			}
+			if tt.wantLowBattery {
+				if sensor.battery.lowBatteryCharacteristic == nil || sensor.battery.lowBatteryCharacteristic.Value() != 1 {
+					t.Error("the low battery status is not reported")
+				}
+			}
+
+			sensor.UpdateState(deconz.ObjectMap{"open": true})
+			if got := sensor.service.ContactSensorState.Value(); got != 1 {
+				t.Errorf("ContactSensorState after opening = %d, want 1", got)
+			}
+		})
+	}
+}
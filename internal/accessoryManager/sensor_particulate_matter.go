@@ -0,0 +1,111 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/helper"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// ParticulateMatterSensor represents a particulate matter sensor in HomeKit.
+// It implements the DeviceService interface and exposes the PM2.5 density on an
+// air quality service. The air quality level is derived from the density.
+type ParticulateMatterSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit air quality sensor service
+	service *service.AirQualitySensor
+
+	// density is the PM2.5 density characteristic in µg/m³
+	density *characteristic.PM2_5Density
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *ParticulateMatterSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// deCONZ reports the PM2.5 density in µg/m³ as "measured_value", some sensors use "pm2_5" instead.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *ParticulateMatterSensor) UpdateState(state deconz.MapObject) {
+	for _, key := range []string{"measured_value", "pm2_5"} {
+		if state.Has(key) {
+			density := max(0, min(state.ValueToFloat(key), sensor.density.MaxValue()))
+			sensor.density.SetValue(density)
+			_ = sensor.service.AirQuality.SetValue(helper.PM25ToAirQuality(density))
+			break
+		}
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *ParticulateMatterSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewParticulateMatterSensor creates a new particulate matter sensor service.
+// This is used for standalone PM2.5 sensors and for air purifiers which report
+// the particulate matter as a separate subdevice.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewParticulateMatterSensor(config *deconz.Subdevice) error {
+	sensor := new(ParticulateMatterSensor)
+	sensor.device = device
+
+	// Create a new HomeKit air quality sensor service with the PM2.5 density
+	sensor.service = service.NewAirQualitySensor()
+	refreshOnRead(sensor.service.AirQuality.C, device.sensorRefresher(config.UniqueId, sensor))
+	sensor.density = characteristic.NewPM2_5Density()
+	sensor.service.AddC(sensor.density.C)
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -4,24 +4,51 @@ package accessoryManager
 
 import (
 	"deconz-homekit/internal/deconz"
-	"github.com/brutella/hap/characteristic"
+	"deconz-homekit/internal/helper"
 	"github.com/brutella/hap/service"
+	"sync"
+	"time"
 )
 
-// PresenceSensor represents a motion/occupancy sensor in HomeKit.
+// Approximate ambient light levels (in lux) for sensors which only report
+// the "dark" and "daylight" flags instead of a measured light level.
+const (
+	darkLux     = 0.0001
+	dimLux      = 100
+	daylightLux = 10000
+)
+
+// PresenceSensor represents a motion sensor in HomeKit.
 // It implements the DeviceService interface and provides functionality for
 // monitoring presence detection from motion sensors.
 type PresenceSensor struct {
 	// device is a reference to the parent Device
 	device *Device
 
-	// service is the HomeKit occupancy sensor service
-	service *service.OccupancySensor
+	// service is the HomeKit motion sensor service
+	service *service.MotionSensor
+
+	// lightSensor is the HomeKit light sensor service
+	// This is optional and only present if the sensor reports light information
+	lightSensor *service.LightSensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
 
-	// lowBatteryCharacteristic is the HomeKit characteristic for low battery status
-	// This is optional and only present if the sensor reports battery status
-	lowBatteryCharacteristic   *characteristic.StatusLowBattery
-	batteryLevelCharacteristic *characteristic.BatteryLevel
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+
+	// cooldown is the time a cleared motion is held back, so that a
+	// re-detection within this time doesn't trigger HomeKit automations again
+	cooldown time.Duration
+
+	// cooldownTimer reports the cleared motion once the cooldown has expired
+	cooldownTimer *time.Timer
+
+	// mu guards the motion state against concurrent updates from the cooldown timer
+	mu sync.Mutex
 }
 
 // S returns the underlying HomeKit service.
@@ -38,23 +65,95 @@ func (sensor *PresenceSensor) S() *service.S {
 //
 // Parameters:
 //   - state: The updated state object from deCONZ
-//   - config: The updated config object from deCONZ (not used for presence sensors)
 func (sensor *PresenceSensor) UpdateState(state deconz.MapObject) {
-	// Get the presence value from the state and convert it to HomeKit format
-	// In HomeKit, 1 = occupancy detected, 0 = occupancy not detected
-	v := state.ValueToBool("presence")
-	_ = sensor.service.OccupancyDetected.SetValue(boolToInt[v])
-
-	// Log when presence is detected (only log positive detections to reduce noise)
-	if v {
-		sensor.device.log.Info("presence detected")
+	// Update the motion state if the state contains a "presence" value
+	if state.Has("presence") {
+		sensor.setMotionDetected(state.ValueToBool("presence"))
+	}
+
+	// Update the ambient light level if available
+	if sensor.lightSensor != nil {
+		if lux, ok := lightLevelFromState(state); ok {
+			sensor.lightSensor.CurrentAmbientLightLevel.SetValue(lux)
+		}
 	}
 
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
 	// Update the low battery characteristic if available
-	if state.Has("lowbattery") && sensor.lowBatteryCharacteristic != nil {
-		batteryIsLow := state.ValueToBool("lowbattery")
-		// Convert boolean to int (0 = normal, 1 = low)
-		_ = sensor.lowBatteryCharacteristic.SetValue(boolToInt[batteryIsLow])
+	sensor.battery.UpdateState(state)
+}
+
+// setMotionDetected updates the MotionDetected characteristic.
+// Detected motion is reported immediately, while cleared motion is delayed by the
+// configured cooldown. A re-detection within the cooldown cancels the pending clear,
+// so HomeKit sees one continuous motion instead of several short ones.
+//
+// Parameters:
+//   - detected: A boolean indicating whether motion is detected
+func (sensor *PresenceSensor) setMotionDetected(detected bool) {
+	sensor.mu.Lock()
+	defer sensor.mu.Unlock()
+
+	if detected {
+		// Cancel a pending clear, the motion continues
+		if sensor.cooldownTimer != nil {
+			sensor.cooldownTimer.Stop()
+			sensor.cooldownTimer = nil
+		}
+
+		// Log when presence is detected (only log positive detections to reduce noise)
+		if !sensor.service.MotionDetected.Value() {
+			sensor.device.log.Info("presence detected")
+		}
+		sensor.service.MotionDetected.SetValue(true)
+		return
+	}
+
+	// Report cleared motion immediately if no cooldown is configured
+	if sensor.cooldown <= 0 {
+		sensor.service.MotionDetected.SetValue(false)
+		return
+	}
+
+	// Otherwise report it once the cooldown has expired
+	if sensor.cooldownTimer == nil && sensor.service.MotionDetected.Value() {
+		sensor.cooldownTimer = time.AfterFunc(sensor.cooldown, func() {
+			sensor.mu.Lock()
+			defer sensor.mu.Unlock()
+
+			sensor.cooldownTimer = nil
+			sensor.service.MotionDetected.SetValue(false)
+		})
+	}
+}
+
+// lightLevelFromState determines the ambient light level from a deCONZ state.
+// The measured "lux" value is preferred, followed by the logarithmic "lightlevel".
+// If both are missing, the level is approximated from the "dark" and "daylight" flags.
+//
+// Parameters:
+//   - state: The state object from deCONZ
+//
+// Returns:
+//   - float64: The ambient light level in lux
+//   - bool: False if the state doesn't contain any light information
+func lightLevelFromState(state deconz.MapObject) (float64, bool) {
+	switch {
+	case state.Has("lux"):
+		// HomeKit doesn't accept values below 0.0001 lux
+		return max(float64(state.ValueToInt("lux")), darkLux), true
+	case state.Has("lightlevel"):
+		return helper.LightLevelToLux(state.ValueToInt("lightlevel")), true
+	case state.Has("dark") && state.ValueToBool("dark"):
+		return darkLux, true
+	case state.Has("daylight") && state.ValueToBool("daylight"):
+		return daylightLux, true
+	case state.Has("dark") || state.Has("daylight"):
+		return dimLux, true
+	default:
+		return 0, false
 	}
 }
 
@@ -64,15 +163,13 @@ func (sensor *PresenceSensor) UpdateState(state deconz.MapObject) {
 // Parameters:
 //   - config: The updated configuration object from deCONZ
 func (sensor *PresenceSensor) UpdateConfig(config deconz.MapObject) {
-	// Update the battery level characteristic if available
-	if config.Has("battery") && sensor.batteryLevelCharacteristic != nil {
-		batteryLevel := config.ValueToInt("battery")
-		_ = sensor.batteryLevelCharacteristic.SetValue(batteryLevel)
-	}
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
 }
 
 // NewPresenceSensor creates a new presence sensor service.
 // This is used for motion sensors that detect presence/movement.
+// If the sensor reports light information, an additional light sensor service is added.
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
@@ -82,20 +179,25 @@ func (sensor *PresenceSensor) UpdateConfig(config deconz.MapObject) {
 func (device *Device) NewPresenceSensor(config *deconz.Subdevice) error {
 	sensor := new(PresenceSensor)
 	sensor.device = device
+	sensor.cooldown = device.options.PresenceCooldown
 
-	// Create a new HomeKit occupancy sensor service
-	sensor.service = service.NewOccupancySensor()
+	// Create a new HomeKit motion sensor service
+	sensor.service = service.NewMotionSensor()
+	refreshOnRead(sensor.service.MotionDetected.C, device.sensorRefresher(config.UniqueId, sensor))
 
-	// Add the low battery characteristic if the sensor reports battery status
-	if config.State.Has("lowbattery") {
-		sensor.lowBatteryCharacteristic = characteristic.NewStatusLowBattery()
-		sensor.service.AddC(sensor.lowBatteryCharacteristic.C)
-	}
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
 
-	// Add the battery level characteristic if the sensor reports battery config
-	if config.Config.Has("battery") {
-		sensor.batteryLevelCharacteristic = characteristic.NewBatteryLevel()
-		sensor.service.AddC(sensor.batteryLevelCharacteristic.C)
+	// Add a light sensor service if the sensor reports light information
+	if _, ok := lightLevelFromState(config.State); ok {
+		sensor.lightSensor = service.NewLightSensor()
+		device.Accessory.AddS(sensor.lightSensor.S)
 	}
 
 	// Initialize the sensor state from the current deCONZ state
@@ -0,0 +1,111 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"errors"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// PressureSensor represents an air pressure sensor in HomeKit.
+// It implements the DeviceService interface. HomeKit has no native pressure service,
+// so the pressure is exposed via the Eve air pressure service and characteristic.
+type PressureSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the Eve air pressure sensor service
+	service *service.S
+
+	// pressure is the Eve characteristic for the air pressure in hPa
+	pressure *characteristic.Float
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *PressureSensor) S() *service.S {
+	return sensor.service
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *PressureSensor) UpdateState(state deconz.MapObject) {
+	// deCONZ reports the pressure in hPa, which is the unit expected by Eve
+	if state.Has("pressure") {
+		sensor.pressure.SetValue(state.ValueToFloat("pressure"))
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *PressureSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewPressureSensor creates a new air pressure sensor service.
+// This is used for sensors that measure the atmospheric pressure (e.g. weather sensors).
+// The service is only added if Eve characteristics are enabled in the options,
+// because the Apple Home app doesn't support it.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewPressureSensor(config *deconz.Subdevice) error {
+	if !device.options.EveCharacteristics {
+		return errors.New("eve characteristics are disabled")
+	}
+
+	sensor := new(PressureSensor)
+	sensor.device = device
+
+	// Create a new Eve air pressure sensor service
+	sensor.service = service.New(TypeEveAirPressureSensor)
+	sensor.pressure = newEveCharacteristic(TypeEveAirPressure, "Air Pressure")
+	sensor.service.AddC(sensor.pressure.C)
+	refreshOnRead(sensor.pressure.C, device.sensorRefresher(config.UniqueId, sensor))
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// rotaryEventStart is the "rotaryevent" reported by deCONZ when a rotation has started.
+// While the rotation is in progress, deCONZ repeats the event with the value 2.
+const rotaryEventStart = 1
+
+// buttonTypes are the deCONZ types of the subdevices whose buttons are added by NewSwitch.
+var buttonTypes = []deconz.DeviceType{deconz.SwitchDevice, deconz.DimmerSwitchDevice, deconz.LevelControlSwitchDevice}
+
+// RotaryDevice represents a rotary control (e.g. a dimmer knob) in HomeKit.
+// It implements the DeviceService interface and maps rotations to two stateless
+// programmable switches, one for each direction, so automations can react to them.
+// Like a SwitchDevice, it adds its services directly to the accessory.
+type RotaryDevice struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// rotateUp is the HomeKit stateless programmable switch for clockwise rotations
+	rotateUp *service.StatelessProgrammableSwitch
+
+	// rotateDown is the HomeKit stateless programmable switch for counterclockwise rotations
+	rotateDown *service.StatelessProgrammableSwitch
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the rotary control reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+// For RotaryDevice, this returns nil because it doesn't have a single service,
+// but rather one service per direction that is added directly to the accessory.
+//
+// Returns:
+//   - *service.S: Always nil for RotaryDevice
+func (sensor *RotaryDevice) S() *service.S {
+	return nil
+}
+
+// UpdateState updates the rotary control's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Only the start of a rotation triggers a single press event, so that a long rotation
+// doesn't fire the automations repeatedly.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *RotaryDevice) UpdateState(state deconz.MapObject) {
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+
+	if !state.Has("rotaryevent") || !state.Has("expectedrotation") {
+		return
+	}
+
+	// Ignore the repeated events while the rotation is in progress
+	if state.ValueToInt("rotaryevent") != rotaryEventStart {
+		return
+	}
+
+	// The sign of the expected rotation indicates the direction
+	rotation := state.ValueToInt("expectedrotation")
+	switch {
+	case rotation > 0:
+		sensor.device.log.Infof("rotated up (%d)", rotation)
+		_ = sensor.rotateUp.ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventSinglePress)
+	case rotation < 0:
+		sensor.device.log.Infof("rotated down (%d)", rotation)
+		_ = sensor.rotateDown.ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventSinglePress)
+	}
+}
+
+// UpdateConfig updates the rotary control's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *RotaryDevice) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// addDirection adds a stateless programmable switch service for one direction of rotation.
+// Only single press events are supported.
+//
+// Parameters:
+//   - index: The service label index of the switch
+//
+// Returns:
+//   - *service.StatelessProgrammableSwitch: A pointer to the created service
+func (sensor *RotaryDevice) addDirection(index int) *service.StatelessProgrammableSwitch {
+	// Set the service label index for the HomeKit service
+	indexCharacteristic := characteristic.NewServiceLabelIndex()
+	_ = indexCharacteristic.SetValue(index)
+
+	// Create a new HomeKit stateless programmable switch service which only supports single presses
+	s := service.NewStatelessProgrammableSwitch()
+	s.ProgrammableSwitchEvent.C.ValidVals = []int{characteristic.ProgrammableSwitchEventSinglePress}
+	s.AddC(indexCharacteristic.C)
+
+	// Add the service directly to the accessory
+	sensor.device.Accessory.AddS(s.S)
+	return s
+}
+
+// NewRotaryDevice creates a new rotary control service.
+// This is used for all ZHARelativeRotary sensors (e.g. IKEA Symfonisk sound controllers
+// or the rotary ring of the Hue tap dial switch). In contrast to switches, no device
+// configuration from the button map is needed, since deCONZ reports rotations uniformly.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewRotaryDevice(config *deconz.Subdevice) error {
+	sensor := new(RotaryDevice)
+	sensor.device = device
+
+	// Add a service for each direction of rotation
+	// The service label indexes must be unique within the accessory, so if the device also
+	// has buttons (e.g. the Hue tap dial switch), the directions are labeled after them
+	offset := 0
+	if device.hasButtons {
+		offset = device.buttonCount()
+	}
+	sensor.rotateUp = sensor.addDirection(offset + 1)
+	sensor.rotateDown = sensor.addDirection(offset + 2)
+
+	// Add a battery service if the sensor reports battery status or level
+	batteryService := service.New(service.TypeBatteryService)
+	sensor.battery = newBattery(batteryService, config, device.options.LowBatteryThreshold)
+	if len(batteryService.Cs) > 0 {
+		device.Accessory.AddS(batteryService)
+	}
+
+	// Initialize the battery state, rotations from before the start are not replayed
+	sensor.UpdateConfig(config.Config)
+	sensor.battery.UpdateState(config.State)
+
+	// Register the service with the device
+	device.Services[config.UniqueId] = sensor
+	return nil
+}
+
+// buttonCount returns the number of buttons in the device configuration of the model.
+// This is the number of button services NewSwitch adds for the switch subdevice of the device.
+//
+// Returns:
+//   - int: The number of buttons, or 0 if the model has no device configuration
+func (device *Device) buttonCount() int {
+	deviceConfigs, err := loadDeviceConfigurations()
+	if err != nil {
+		return 0
+	}
+	return len(deviceConfigs[device.model].Buttons)
+}
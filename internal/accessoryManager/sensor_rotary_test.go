@@ -0,0 +1,63 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
+	"github.com/brutella/hap/characteristic"
+	"slices"
+	"testing"
+)
+
+// serviceLabelIndexes returns the service label indexes of all services of a device.
+func serviceLabelIndexes(device *Device) []int {
+	indexes := []int{}
+	for _, s := range device.Accessory.Ss {
+		for _, c := range s.Cs {
+			if c.Type == characteristic.TypeServiceLabelIndex {
+				indexes = append(indexes, c.Value().(int))
+			}
+		}
+	}
+	return indexes
+}
+
+func TestRotaryIndexesFollowButtons(t *testing.T) {
+	// A remote with four buttons and a rotary ring, like the Hue tap dial switch
+	withDeviceConfigurations(t, map[string]deviceConfiguration.DeviceConfiguration{
+		"RDM002": {Buttons: []deviceConfiguration.ButtonConfiguration{
+			{Name: "Button 1", EventMap: map[string]deviceConfiguration.ButtonEvent{"1002": deviceConfiguration.ButtonSinglePress}},
+			{Name: "Button 2", EventMap: map[string]deviceConfiguration.ButtonEvent{"2002": deviceConfiguration.ButtonSinglePress}},
+			{Name: "Button 3", EventMap: map[string]deviceConfiguration.ButtonEvent{"3002": deviceConfiguration.ButtonSinglePress}},
+			{Name: "Button 4", EventMap: map[string]deviceConfiguration.ButtonEvent{"4002": deviceConfiguration.ButtonSinglePress}},
+		}},
+	})
+
+	rotary := deconz.Subdevice{Type: deconz.RelativeRotaryDevice, UniqueId: "00:11:22:33:44:55:66:77-01-0014"}
+	buttons := deconz.Subdevice{Type: deconz.SwitchDevice, UniqueId: "00:11:22:33:44:55:66:77-01-fc00"}
+
+	tests := []struct {
+		name       string
+		subdevices []deconz.Subdevice
+		want       []int
+	}{
+		{"rotary only", []deconz.Subdevice{rotary}, []int{1, 2}},
+		{"buttons and rotary", []deconz.Subdevice{buttons, rotary}, []int{1, 2, 3, 4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newTestDevice(t, nil, &deconz.Device{
+				UniqueId:   "00:11:22:33:44:55:66:77",
+				Model:      "RDM002",
+				Name:       "Tap Dial",
+				Subdevices: tt.subdevices,
+			}, Options{})
+
+			got := serviceLabelIndexes(device)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("service label indexes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"context"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"sync"
+	"time"
+)
+
+// staleCheckInterval is the interval in which the sensors are checked for staleness.
+const staleCheckInterval = time.Minute
+
+// SensorStatus reports whether the sensors of a device are still reporting.
+// Sensors which are disabled in deCONZ ("config.on" is false) don't report, so the
+// StatusActive and StatusFault characteristics of the device's sensor services mark their
+// values as stale until they are enabled again. If a threshold is configured, devices which
+// haven't been seen by the gateway within the threshold are marked the same way.
+// It is safe for concurrent use.
+type SensorStatus struct {
+	// threshold is the time after which a device which hasn't been seen is reported as faulted,
+	// the staleness check is disabled if it is not positive
+	threshold time.Duration
+
+	// mu guards the last seen time and the characteristics
+	mu sync.Mutex
+
+	// lastSeen is the time the device was last seen by the gateway
+	lastSeen time.Time
+
+	// disabled contains the unique IDs of the sensors which are disabled in deCONZ
+	disabled map[string]bool
+
+	// actives are the StatusActive characteristics of the sensor services
+	actives []*characteristic.StatusActive
+
+	// faults are the StatusFault characteristics of the sensor services
+	faults []*characteristic.StatusFault
+}
+
+// newSensorStatus creates a new sensor status.
+//
+// Parameters:
+//   - threshold: The time after which a device which hasn't been seen is reported as faulted (0 to disable)
+//   - lastSeen: The time the device was last seen by the gateway (zero if unknown)
+//
+// Returns:
+//   - *SensorStatus: A pointer to the initialized SensorStatus
+func newSensorStatus(threshold time.Duration, lastSeen time.Time) *SensorStatus {
+	return &SensorStatus{threshold: threshold, lastSeen: lastSeen, disabled: make(map[string]bool)}
+}
+
+// addService adds the StatusActive and StatusFault characteristics to a sensor service.
+//
+// Parameters:
+//   - s: The HomeKit sensor service
+func (status *SensorStatus) addService(s *service.S) {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	active := characteristic.NewStatusActive()
+	s.AddC(active.C)
+	status.actives = append(status.actives, active)
+
+	fault := characteristic.NewStatusFault()
+	s.AddC(fault.C)
+	status.faults = append(status.faults, fault)
+	status.mu.Unlock()
+
+	// Initialize the characteristics
+	status.check(time.Now())
+}
+
+// UpdateLastSeen records the time the device was last seen and updates the characteristics.
+//
+// Parameters:
+//   - lastSeen: The time the device was last seen by the gateway
+func (status *SensorStatus) UpdateLastSeen(lastSeen time.Time) {
+	if status == nil {
+		return
+	}
+
+	status.mu.Lock()
+	status.lastSeen = lastSeen
+	status.mu.Unlock()
+
+	status.check(time.Now())
+}
+
+// SetEnabled records whether a sensor of the device is enabled in deCONZ and updates the characteristics.
+//
+// Parameters:
+//   - id: The unique ID of the sensor
+//   - enabled: The "on" value of the sensor's configuration
+//
+// Returns:
+//   - bool: True if the sensor was enabled or disabled by this call
+func (status *SensorStatus) SetEnabled(id string, enabled bool) bool {
+	if status == nil {
+		return false
+	}
+
+	status.mu.Lock()
+	changed := status.disabled[id] == enabled
+	if enabled {
+		delete(status.disabled, id)
+	} else {
+		status.disabled[id] = true
+	}
+	status.mu.Unlock()
+
+	status.check(time.Now())
+	return changed
+}
+
+// check updates the characteristics based on the time the device was last seen
+// and whether a sensor is disabled. Devices whose last seen time is unknown are not stale,
+// and no device is stale if the threshold is disabled.
+//
+// Parameters:
+//   - now: The current time
+//
+// Returns:
+//   - bool: True if the device is stale (disabled sensors are not taken into account)
+func (status *SensorStatus) check(now time.Time) bool {
+	if status == nil {
+		return false
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	stale := status.threshold > 0 && !status.lastSeen.IsZero() && now.Sub(status.lastSeen) > status.threshold
+	faulted := stale || len(status.disabled) > 0
+	for _, active := range status.actives {
+		active.SetValue(!faulted)
+	}
+	for _, fault := range status.faults {
+		if faulted {
+			_ = fault.SetValue(characteristic.StatusFaultGeneralFault)
+		} else {
+			_ = fault.SetValue(characteristic.StatusFaultNoFault)
+		}
+	}
+	return stale
+}
+
+// MonitorSensorStatus periodically checks whether the sensors are still reporting
+// and marks the sensors of devices which haven't been seen within the threshold as faulted.
+// This blocks until the context is cancelled.
+//
+// Parameters:
+//   - ctx: Context for cancelling the monitoring
+func (am *AccessoryManager) MonitorSensorStatus(ctx context.Context) {
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+
+	stale := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for id, device := range am.Devices {
+				// Only log when the status of a device changes
+				isStale := device.status.check(now)
+				if isStale && !stale[id] {
+					device.log.Warnf("not seen for more than %s, marking sensors as faulted", device.options.StaleThreshold)
+				} else if !isStale && stale[id] {
+					device.log.Info("seen again, marking sensors as active")
+				}
+				stale[id] = isStale
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"testing"
+	"time"
+)
+
+// statusOf returns the StatusActive and StatusFault values of a sensor service.
+func statusOf(t *testing.T, s *service.S) (bool, int) {
+	t.Helper()
+
+	active := s.C(characteristic.TypeStatusActive)
+	fault := s.C(characteristic.TypeStatusFault)
+	if active == nil || fault == nil {
+		t.Fatal("the service has no status characteristics")
+	}
+	return active.Value().(bool), fault.Value().(int)
+}
+
+func TestSensorDisabledInDeconzIsFaulted(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	id := config.Subdevices[0].UniqueId
+	am := NewAccessoryManager(nil, []*deconz.Device{config}, Options{StaleThreshold: time.Hour})
+	sensor := am.Services[id].(*TemperatureSensor)
+
+	steps := []struct {
+		name       string
+		event      string
+		wantActive bool
+		wantFault  int
+	}{
+		{"initial", "", true, characteristic.StatusFaultNoFault},
+		{"disabled", `{"on": false}`, false, characteristic.StatusFaultGeneralFault},
+		{"other config", `{"battery": 90}`, false, characteristic.StatusFaultGeneralFault},
+		{"enabled", `{"on": true}`, true, characteristic.StatusFaultNoFault},
+	}
+
+	for _, step := range steps {
+		if step.event != "" {
+			am.ProcessUpdate(message(t, `{"t":"event","e":"changed","r":"sensors","id":"5","uniqueid":"`+id+`","config":`+step.event+`}`))
+		}
+
+		active, fault := statusOf(t, sensor.S())
+		if active != step.wantActive || fault != step.wantFault {
+			t.Errorf("%s: StatusActive = %t, StatusFault = %d, want %t and %d", step.name, active, fault, step.wantActive, step.wantFault)
+		}
+	}
+}
+
+func TestSensorDisabledOnStartupIsFaulted(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	config.Subdevices[0].Config = extendedMap(t, `{"on": {"value": false}}`)
+	device := newTestDevice(t, nil, config, Options{StaleThreshold: time.Hour})
+	sensor := device.Services[config.Subdevices[0].UniqueId].(*TemperatureSensor)
+
+	if active, fault := statusOf(t, sensor.S()); active || fault != characteristic.StatusFaultGeneralFault {
+		t.Errorf("StatusActive = %t, StatusFault = %d, want false and a general fault", active, fault)
+	}
+
+	device.SetEnabled(config.Subdevices[0].UniqueId, true)
+	if active, fault := statusOf(t, sensor.S()); !active || fault != characteristic.StatusFaultNoFault {
+		t.Errorf("StatusActive after enabling = %t, StatusFault = %d, want true and no fault", active, fault)
+	}
+}
+
+func TestSensorDisabledWithoutStaleThresholdIsFaulted(t *testing.T) {
+	config := sensorDevice(t, deconz.TemperatureDevice, `{"temperature": {"value": 2150}}`)
+	config.LastSeen = "2020-01-01T00:00Z"
+	id := config.Subdevices[0].UniqueId
+	device := newTestDevice(t, nil, config, Options{StaleThreshold: 0})
+	sensor := device.Services[id].(*TemperatureSensor)
+
+	// A device which hasn't been seen for a long time isn't stale without a threshold
+	if active, fault := statusOf(t, sensor.S()); !active || fault != characteristic.StatusFaultNoFault {
+		t.Errorf("StatusActive = %t, StatusFault = %d, want true and no fault", active, fault)
+	}
+
+	device.SetEnabled(id, false)
+	if active, fault := statusOf(t, sensor.S()); active || fault != characteristic.StatusFaultGeneralFault {
+		t.Errorf("StatusActive after disabling = %t, StatusFault = %d, want false and a general fault", active, fault)
+	}
+
+	device.SetEnabled(id, true)
+	if active, fault := statusOf(t, sensor.S()); !active || fault != characteristic.StatusFaultNoFault {
+		t.Errorf("StatusActive after enabling = %t, StatusFault = %d, want true and no fault", active, fault)
+	}
+}
@@ -29,7 +29,13 @@ type SwitchDevice struct {
 	// These configurations define how deCONZ button events map to HomeKit button events
 	configs map[string]deviceConfiguration.ButtonConfiguration
 
-	batteryLevelCharacteristic *characteristic.BatteryLevel
+	// holding contains the IDs of the buttons which are currently held
+	// A held button has already triggered a long press, so its release doesn't trigger another one
+	holding map[string]bool
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the switch reports battery status
+	battery *Battery
 }
 
 // S returns the underlying HomeKit service.
@@ -51,6 +57,9 @@ func (sensor *SwitchDevice) S() *service.S {
 //   - state: The updated state object from deCONZ
 //   - _: The updated config object from deCONZ (not used for switches)
 func (sensor *SwitchDevice) UpdateState(state deconz.MapObject) {
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+
 	// Process button events from the deCONZ gateway
 	if state.Has("buttonevent") {
 		// Get the button event code from the state
@@ -66,8 +75,23 @@ func (sensor *SwitchDevice) UpdateState(state deconz.MapObject) {
 			_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventSinglePress)
 		case deviceConfiguration.ButtonDoublePress:
 			_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventDoublePress)
+		case deviceConfiguration.ButtonTriplePress:
+			// HomeKit has no triple press, so it is reported as a double press followed by a single press
+			_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventDoublePress)
+			_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventSinglePress)
+		case deviceConfiguration.ButtonHold:
+			// Trigger the long press as soon as the button is held, repeated hold events are ignored
+			if !sensor.holding[deviceId] {
+				sensor.holding[deviceId] = true
+				_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventLongPress)
+			}
 		case deviceConfiguration.ButtonLongPress:
-			_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventLongPress)
+			// The long press was already triggered if the button reported the hold
+			if sensor.holding[deviceId] {
+				delete(sensor.holding, deviceId)
+			} else {
+				_ = sensor.services[deviceId].ProgrammableSwitchEvent.SetValue(characteristic.ProgrammableSwitchEventLongPress)
+			}
 		}
 	}
 }
@@ -78,11 +102,22 @@ func (sensor *SwitchDevice) UpdateState(state deconz.MapObject) {
 // Parameters:
 //   - config: The updated configuration object from deCONZ
 func (sensor *SwitchDevice) UpdateConfig(config deconz.MapObject) {
-	// Update the battery level characteristic if available
-	if config.Has("battery") && sensor.batteryLevelCharacteristic != nil {
-		batteryLevel := config.ValueToInt("battery")
-		_ = sensor.batteryLevelCharacteristic.SetValue(batteryLevel)
-	}
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// buttonNumber returns the deCONZ button number of a button configuration.
+//
+// Parameters:
+//   - config: The button configuration
+//
+// Returns:
+//   - string: The button number (e.g. "1" for the events "1002" and "1003")
+func buttonNumber(config deviceConfiguration.ButtonConfiguration) string {
+	// All events of a button share the button number, so any event can be used
+	someEventId := slices.Collect(maps.Keys(config.EventMap))[0]
+	number, _ := deviceConfiguration.SplitEventId(someEventId)
+	return number
 }
 
 // addButton adds a button service to the switch device.
@@ -91,15 +126,13 @@ func (sensor *SwitchDevice) UpdateConfig(config deconz.MapObject) {
 //
 // Parameters:
 //   - config: The button configuration defining the button's behavior
-func (sensor *SwitchDevice) addButton(config deviceConfiguration.ButtonConfiguration) {
-	// Get a sample event ID to determine the button number
-	someEventId := slices.Collect(maps.Keys(config.EventMap))[0]
-	buttonNumber, _ := deviceConfiguration.SplitEventId(someEventId)
+//   - index: The one-based position of the button, which HomeKit uses as its label
+func (sensor *SwitchDevice) addButton(config deviceConfiguration.ButtonConfiguration, index int) {
+	buttonNumber := buttonNumber(config)
 
-	// Set the service label index (button number) for the HomeKit service
-	buttonIndex, _ := strconv.Atoi(buttonNumber)
+	// Set the service label index for the HomeKit service
 	indexCharacteristic := characteristic.NewServiceLabelIndex()
-	_ = indexCharacteristic.SetValue(buttonIndex)
+	_ = indexCharacteristic.SetValue(index)
 
 	// Determine which button press types (single, double, long) this button supports
 	enabledButtonStates := []int{}
@@ -118,7 +151,10 @@ func (sensor *SwitchDevice) addButton(config deviceConfiguration.ButtonConfigura
 			appendButtonState(characteristic.ProgrammableSwitchEventSinglePress)
 		case deviceConfiguration.ButtonDoublePress:
 			appendButtonState(characteristic.ProgrammableSwitchEventDoublePress)
-		case deviceConfiguration.ButtonLongPress:
+		case deviceConfiguration.ButtonTriplePress:
+			appendButtonState(characteristic.ProgrammableSwitchEventDoublePress)
+			appendButtonState(characteristic.ProgrammableSwitchEventSinglePress)
+		case deviceConfiguration.ButtonLongPress, deviceConfiguration.ButtonHold:
 			appendButtonState(characteristic.ProgrammableSwitchEventLongPress)
 		}
 	}
@@ -141,8 +177,23 @@ func (sensor *SwitchDevice) addButton(config deviceConfiguration.ButtonConfigura
 	sensor.device.Accessory.AddS(newButton.S)
 }
 
+// addBattery adds a battery service to the accessory if the subdevice reports battery status or level.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+func (sensor *SwitchDevice) addBattery(config *deconz.Subdevice) {
+	batteryService := service.New(service.TypeBatteryService)
+	sensor.battery = newBattery(batteryService, config, sensor.device.options.LowBatteryThreshold)
+	if len(batteryService.Cs) > 0 {
+		sensor.device.Accessory.AddS(batteryService)
+	}
+}
+
 // NewSwitch creates a new switch device service.
-// This is used for remote controls and wall switches with one or more buttons.
+// This is used for remote controls and wall switches with one or more buttons
+// (e.g. ZHASwitch sensors, dimmer switches and level control switches).
+// Some remotes report both a switch sensor and a dimmer or level control switch. Their
+// buttons are only added once and the events of all subdevices are routed to them.
 //
 // Parameters:
 //   - config: A pointer to the deCONZ subdevice configuration
@@ -150,43 +201,72 @@ func (sensor *SwitchDevice) addButton(config deviceConfiguration.ButtonConfigura
 // Returns:
 //   - error: An error if the service could not be created
 func (device *Device) NewSwitch(config *deconz.Subdevice) error {
+	// Reuse the buttons if another subdevice of the remote already added them
+	for _, s := range device.Services {
+		if existing, ok := s.(*SwitchDevice); ok {
+			// The battery may only be reported by this subdevice
+			if !existing.battery.present() {
+				existing.addBattery(config)
+			}
+			existing.battery.UpdateState(config.State)
+			existing.UpdateConfig(config.Config)
+			device.Services[config.UniqueId] = existing
+			return nil
+		}
+	}
+
 	sensor := new(SwitchDevice)
 	sensor.device = device
 	sensor.services = make(map[string]*service.StatelessProgrammableSwitch)
 	sensor.configs = make(map[string]deviceConfiguration.ButtonConfiguration)
+	sensor.holding = make(map[string]bool)
 
-	// Get detailed information about the sensor from the deCONZ gateway
-	sensorInfo, err := device.client.GetSensor(config.UniqueId)
-	if err != nil {
-		return err
+	// The model is usually known from the device, otherwise get it from the sensor details
+	model := device.model
+	if len(model) == 0 {
+		sensorInfo, err := fetchWithRetry(device.client.Context(), device.log, config.UniqueId, device.client.GetSensor)
+		if err != nil {
+			return err
+		}
+		model = sensorInfo.ModelId
 	}
 
 	// Load device configurations from the devices directory
 	// These configurations define how different button events map to HomeKit events
-	deviceConfigs, err := deviceConfiguration.LoadFromDirectory("./devices")
+	deviceConfigs, err := loadDeviceConfigurations()
 	if err != nil {
 		return fmt.Errorf("error loading device configurations: %v", err)
 	}
 
 	// Find the configuration for this specific device model
-	deviceConfig, ok := deviceConfigs[sensorInfo.ModelId]
+	deviceConfig, ok := deviceConfigs[model]
 	if !ok {
-		return fmt.Errorf("could not find device %s", sensorInfo.ModelId)
+		return fmt.Errorf("could not find device %s", model)
 	}
 
-	// Add a service for each button defined in the device configuration
-	for _, buttonConfig := range deviceConfig.Buttons {
-		sensor.addButton(buttonConfig)
+	// Declare that the buttons are labeled with numerals, so that HomeKit shows them as a group
+	// This is only needed for remotes with more than one button
+	if len(deviceConfig.Buttons) > 1 {
+		label := service.NewServiceLabel()
+		_ = label.ServiceLabelNamespace.SetValue(characteristic.ServiceLabelNamespaceArabicNumerals)
+		device.Accessory.AddS(label.S)
 	}
 
-	// Add the battery level characteristic if the sensor reports battery config
-	if config.Config.Has("battery") {
-		batteryService := service.New(service.TypeBatteryService)
-		sensor.batteryLevelCharacteristic = characteristic.NewBatteryLevel()
-		batteryService.AddC(sensor.batteryLevelCharacteristic.C)
-		device.Accessory.AddS(batteryService)
+	// Add a service for each button defined in the device configuration
+	// The buttons are ordered by their button number and labeled 1, 2, 3, ..., because HomeKit
+	// expects contiguous indexes, while the button numbers of deCONZ may have gaps
+	buttons := slices.SortedFunc(slices.Values(deviceConfig.Buttons), func(a, b deviceConfiguration.ButtonConfiguration) int {
+		numberA, _ := strconv.Atoi(buttonNumber(a))
+		numberB, _ := strconv.Atoi(buttonNumber(b))
+		return numberA - numberB
+	})
+	for i, buttonConfig := range buttons {
+		sensor.addButton(buttonConfig, i+1)
 	}
 
+	// Add a battery service if the sensor reports battery status or level
+	sensor.addBattery(config)
+
 	// Initialize the switch state
 	sensor.UpdateState(config.State)
 	sensor.UpdateConfig(config.Config)
@@ -0,0 +1,112 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/helper"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// TemperatureSensor represents a temperature sensor in HomeKit.
+// It implements the DeviceService interface and provides functionality for
+// monitoring the temperature from compatible sensors.
+type TemperatureSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit temperature sensor service
+	service *service.TemperatureSensor
+
+	// displayUnitsCharacteristic is the HomeKit characteristic for the preferred display unit
+	displayUnitsCharacteristic *characteristic.TemperatureDisplayUnits
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *TemperatureSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *TemperatureSensor) UpdateState(state deconz.MapObject) {
+	// Update the temperature if the state contains a "temperature" value
+	if state.Has("temperature") {
+		sensor.service.CurrentTemperature.SetValue(helper.DeciCelsiusToHK(state.ValueToInt("temperature")))
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *TemperatureSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewTemperatureSensor creates a new temperature sensor service.
+// This is used for sensors that measure the temperature.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewTemperatureSensor(config *deconz.Subdevice) error {
+	sensor := new(TemperatureSensor)
+	sensor.device = device
+
+	// Create a new HomeKit temperature sensor service
+	// The default range of HomeKit (0-100 °C) doesn't cover outdoor sensors
+	sensor.service = service.NewTemperatureSensor()
+	refreshOnRead(sensor.service.CurrentTemperature.C, device.sensorRefresher(config.UniqueId, sensor))
+	sensor.service.CurrentTemperature.SetMinValue(-50)
+	sensor.service.CurrentTemperature.SetStepValue(0.01)
+
+	// Add the display unit characteristic, which is honored by some controllers
+	// The temperature itself is always transferred in Celsius
+	sensor.displayUnitsCharacteristic = characteristic.NewTemperatureDisplayUnits()
+	_ = sensor.displayUnitsCharacteristic.SetValue(device.options.TemperatureDisplayUnits)
+	sensor.service.AddC(sensor.displayUnitsCharacteristic.C)
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the sensor state from the current deCONZ state
+	sensor.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/service"
+	"sync"
+	"time"
+)
+
+// DefaultVibrationTimeout is the default time after which a detected vibration is cleared.
+const DefaultVibrationTimeout = 10 * time.Second
+
+// VibrationSensor represents a vibration sensor in HomeKit.
+// It implements the DeviceService interface. HomeKit has no vibration service,
+// so vibrations (e.g. movement, tilt or drop) are reported as detected motion.
+type VibrationSensor struct {
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit motion sensor service
+	service *service.MotionSensor
+
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
+
+	// timeout is the time after which a detected vibration is cleared,
+	// because the sensor only reports the vibration itself
+	timeout time.Duration
+
+	// clearTimer clears the detected motion once the timeout has expired
+	clearTimer *time.Timer
+
+	// mu guards the motion state against concurrent updates from the clear timer
+	mu sync.Mutex
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (sensor *VibrationSensor) S() *service.S {
+	return sensor.service.S
+}
+
+// UpdateState updates the sensor's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (sensor *VibrationSensor) UpdateState(state deconz.MapObject) {
+	// Update the motion state if the state contains a "vibration" value
+	if state.Has("vibration") {
+		sensor.setVibration(state.ValueToBool("vibration"))
+	}
+
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
+	// Update the low battery characteristic if available
+	sensor.battery.UpdateState(state)
+}
+
+// setVibration updates the MotionDetected characteristic.
+// A vibration is momentary, so detected motion is cleared automatically after the
+// configured timeout. Another vibration within the timeout restarts it.
+// If the timeout is disabled, the motion is cleared when deCONZ resets the vibration.
+//
+// Parameters:
+//   - detected: A boolean indicating whether a vibration is detected
+func (sensor *VibrationSensor) setVibration(detected bool) {
+	sensor.mu.Lock()
+	defer sensor.mu.Unlock()
+
+	if !detected {
+		// Cleared vibrations are handled by the timer if a timeout is configured
+		if sensor.timeout <= 0 {
+			sensor.service.MotionDetected.SetValue(false)
+		}
+		return
+	}
+
+	if !sensor.service.MotionDetected.Value() {
+		sensor.device.log.Info("vibration detected")
+	}
+	sensor.service.MotionDetected.SetValue(true)
+
+	if sensor.timeout <= 0 {
+		return
+	}
+
+	// Restart the timeout, the vibration continues
+	if sensor.clearTimer != nil {
+		sensor.clearTimer.Stop()
+	}
+	sensor.clearTimer = time.AfterFunc(sensor.timeout, func() {
+		sensor.mu.Lock()
+		defer sensor.mu.Unlock()
+
+		sensor.clearTimer = nil
+		sensor.service.MotionDetected.SetValue(false)
+	})
+}
+
+// UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ
+func (sensor *VibrationSensor) UpdateConfig(config deconz.MapObject) {
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
+}
+
+// NewVibrationSensor creates a new vibration sensor service.
+// This is used for sensors that detect vibrations, e.g. on doors, windows or appliances.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewVibrationSensor(config *deconz.Subdevice) error {
+	sensor := new(VibrationSensor)
+	sensor.device = device
+	sensor.timeout = device.options.VibrationTimeout
+
+	// Create a new HomeKit motion sensor service
+	sensor.service = service.NewMotionSensor()
+
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
+
+	// Initialize the tamper and battery state from the current deCONZ state
+	// The last vibration is not restored, because it may have happened long ago
+	sensor.tamper.UpdateState(config.State)
+	sensor.battery.UpdateState(config.State)
+	sensor.UpdateConfig(config.Config)
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, sensor)
+	return nil
+}
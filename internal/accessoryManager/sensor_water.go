@@ -4,7 +4,6 @@ package accessoryManager
 
 import (
 	"deconz-homekit/internal/deconz"
-	"github.com/brutella/hap/characteristic"
 	"github.com/brutella/hap/service"
 )
 
@@ -18,10 +17,13 @@ type WaterSensor struct {
 	// service is the HomeKit leak sensor service
 	service *service.LeakSensor
 
-	// lowBatteryCharacteristic is the HomeKit characteristic for low battery status
-	// This is optional and only present if the sensor reports battery status
-	lowBatteryCharacteristic   *characteristic.StatusLowBattery
-	batteryLevelCharacteristic *characteristic.BatteryLevel
+	// tamper handles the tamper characteristic
+	// This is optional and only present if the sensor reports a tamper state
+	tamper *Tamper
+
+	// battery handles the battery characteristics
+	// These are optional and only present if the sensor reports battery status
+	battery *Battery
 }
 
 // S returns the underlying HomeKit service.
@@ -40,22 +42,25 @@ func (sensor *WaterSensor) S() *service.S {
 //   - state: The updated state object from deCONZ
 //   - config: The updated config object from deCONZ (not used for water sensors)
 func (sensor *WaterSensor) UpdateState(state deconz.MapObject) {
-	// Update the leak detection state based on the "water" value from deCONZ
-	// In HomeKit, 1 = leak detected, 0 = no leak detected
-	v := state.ValueToBool("water")
-	_ = sensor.service.LeakDetected.SetValue(boolToInt[v])
-
-	// Log when a leak is detected (only log positive detections to reduce noise)
-	if v {
-		sensor.device.log.Info("leak detected")
+	// Update the leak detection state if the state contains a "water" value
+	// Other updates (e.g. of the battery or tamper state) don't reset a detection
+	if state.Has("water") {
+		// In HomeKit, 1 = leak detected, 0 = no leak detected
+		v := state.ValueToBool("water")
+		changed := sensor.service.LeakDetected.Value() != boolToInt[v]
+		_ = sensor.service.LeakDetected.SetValue(boolToInt[v])
+
+		// Log when a leak is detected (only log new positive detections to reduce noise)
+		if v && changed {
+			sensor.device.log.Info("leak detected")
+		}
 	}
 
+	// Update the tamper characteristic if available
+	sensor.tamper.UpdateState(state)
+
 	// Update the low battery characteristic if available
-	if state.Has("lowbattery") && sensor.lowBatteryCharacteristic != nil {
-		batteryIsLow := state.ValueToBool("lowbattery")
-		// Convert boolean to int (0 = normal, 1 = low)
-		_ = sensor.lowBatteryCharacteristic.SetValue(boolToInt[batteryIsLow])
-	}
+	sensor.battery.UpdateState(state)
 }
 
 // UpdateConfig updates the sensor's configuration based on updates from the deCONZ gateway.
@@ -64,11 +69,8 @@ func (sensor *WaterSensor) UpdateState(state deconz.MapObject) {
 // Parameters:
 //   - config: The updated configuration object from deCONZ
 func (sensor *WaterSensor) UpdateConfig(config deconz.MapObject) {
-	// Update the battery level characteristic if available
-	if config.Has("battery") && sensor.batteryLevelCharacteristic != nil {
-		batteryLevel := config.ValueToInt("battery")
-		_ = sensor.batteryLevelCharacteristic.SetValue(batteryLevel)
-	}
+	// Update the battery characteristics if available
+	sensor.battery.UpdateConfig(config)
 }
 
 // NewWaterSensor creates a new water leak sensor service.
@@ -85,18 +87,16 @@ func (device *Device) NewWaterSensor(config *deconz.Subdevice) error {
 
 	// Create a new HomeKit leak sensor service
 	sensor.service = service.NewLeakSensor()
+	refreshOnRead(sensor.service.LeakDetected.C, device.sensorRefresher(config.UniqueId, sensor))
 
-	// Add the low battery characteristic if the sensor reports battery status
-	if config.State.Has("lowbattery") {
-		sensor.lowBatteryCharacteristic = characteristic.NewStatusLowBattery()
-		sensor.service.AddC(sensor.lowBatteryCharacteristic.C)
-	}
+	// Add the status characteristics if stale sensors are reported as faulted
+	device.status.addService(sensor.service.S)
 
-	// Add the battery level characteristic if the sensor reports battery config
-	if config.Config.Has("battery") {
-		sensor.batteryLevelCharacteristic = characteristic.NewBatteryLevel()
-		sensor.service.AddC(sensor.batteryLevelCharacteristic.C)
-	}
+	// Add the tamper characteristic if the sensor reports a tamper state
+	sensor.tamper = newTamper(sensor.service.S, config)
+
+	// Add the battery characteristics if the sensor reports battery status or level
+	sensor.battery = newBattery(sensor.service.S, config, device.options.LowBatteryThreshold)
 
 	// Initialize the sensor state from the current deCONZ state
 	sensor.UpdateState(config.State)
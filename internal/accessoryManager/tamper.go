@@ -0,0 +1,49 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+)
+
+// Tamper handles the tamper characteristic shared by all sensors.
+// Many Zigbee sensors report a "tampered" state when their housing is opened.
+type Tamper struct {
+	// tamperedCharacteristic is the HomeKit characteristic for the tamper status
+	tamperedCharacteristic *characteristic.StatusTampered
+}
+
+// newTamper adds the tamper characteristic to a service, if the deCONZ subdevice
+// reports a tamper state.
+//
+// Parameters:
+//   - s: The HomeKit service to add the characteristic to
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - *Tamper: A pointer to the initialized Tamper
+func newTamper(s *service.S, config *deconz.Subdevice) *Tamper {
+	t := new(Tamper)
+
+	// Add the tamper characteristic if the sensor reports a tamper state
+	if config.State.Has("tampered") || config.Config.Has("tampered") {
+		t.tamperedCharacteristic = characteristic.NewStatusTampered()
+		s.AddC(t.tamperedCharacteristic.C)
+	}
+
+	return t
+}
+
+// UpdateState updates the tamper status based on state updates from the deCONZ gateway.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (t *Tamper) UpdateState(state deconz.MapObject) {
+	if state.Has("tampered") && t.tamperedCharacteristic != nil {
+		isTampered := state.ValueToBool("tampered")
+		// Convert boolean to int (0 = not tampered, 1 = tampered)
+		_ = t.tamperedCharacteristic.SetValue(boolToInt[isTampered])
+	}
+}
@@ -0,0 +1,227 @@
+// Package accessoryManager provides functionality for creating and managing HomeKit accessories
+// that represent deCONZ devices.
+package accessoryManager
+
+import (
+	"deconz-homekit/internal/deconz"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Valve represents a Zigbee water valve in HomeKit.
+// It implements the DeviceService interface. deCONZ reports valves as on/off outputs,
+// so the on/off state of the output opens and closes the valve.
+// If a duration is set in HomeKit, the valve is closed by the bridge once it has expired.
+type Valve struct {
+	// ID is the unique identifier of the valve (from deCONZ)
+	ID string
+
+	// SetDuration is the HomeKit characteristic for the time the valve stays open
+	SetDuration *characteristic.SetDuration
+
+	// RemainingDuration is the HomeKit characteristic for the time until the valve is closed
+	RemainingDuration *characteristic.RemainingDuration
+
+	// feedback ignores the state echoed by deCONZ after a change from HomeKit
+	feedback *feedbackGuard
+
+	// commands is the queue of commands for the deCONZ gateway
+	commands commandQueue
+
+	// timer closes the valve once the duration has expired
+	timer *time.Timer
+
+	// closeAt is the time the valve is closed by the timer
+	closeAt time.Time
+
+	// mu guards the timer against concurrent updates
+	mu sync.Mutex
+
+	// device is a reference to the parent Device
+	device *Device
+
+	// service is the HomeKit valve service
+	service *service.Valve
+}
+
+// S returns the underlying HomeKit service.
+// This method implements the DeviceService interface.
+//
+// Returns:
+//   - *service.S: A pointer to the HomeKit service
+func (valve *Valve) S() *service.S {
+	return valve.service.S
+}
+
+// SetActive opens or closes the valve.
+// This method is called when the Active characteristic is changed through HomeKit.
+//
+// Parameters:
+//   - v: The Active value (characteristic.ActiveActive or characteristic.ActiveInactive)
+//   - previous: The previous Active value, which is restored if the command fails
+func (valve *Valve) SetActive(v, previous int) {
+	open := v == characteristic.ActiveActive
+	valve.device.log.Infof("set valve %s", onOffStr[open])
+
+	// Send the command to the deCONZ gateway
+	valve.feedback.updateChange()
+	valve.commands.send(valve.device.log, "set valve "+onOffStr[open], func() error {
+		return valve.device.client.SetLightOn(valve.ID, open)
+	}, func() {
+		valve.setOpen(previous == characteristic.ActiveActive)
+	})
+	valve.setOpen(open)
+}
+
+// setOpen updates the characteristics for an opened or closed valve
+// and starts or stops the timer closing the valve.
+//
+// Parameters:
+//   - open: A boolean indicating whether the valve is open
+func (valve *Valve) setOpen(open bool) {
+	valve.mu.Lock()
+	defer valve.mu.Unlock()
+
+	_ = valve.service.Active.SetValue(boolToInt[open])
+	_ = valve.service.InUse.SetValue(boolToInt[open])
+
+	// Stop a running timer, the valve was closed or opened again
+	if valve.timer != nil {
+		valve.timer.Stop()
+		valve.timer = nil
+	}
+
+	// Close the valve once the duration has expired
+	duration := time.Duration(valve.SetDuration.Value()) * time.Second
+	if !open || duration == 0 {
+		_ = valve.RemainingDuration.SetValue(0)
+		return
+	}
+
+	valve.closeAt = time.Now().Add(duration)
+	_ = valve.RemainingDuration.SetValue(valve.SetDuration.Value())
+	valve.timer = time.AfterFunc(duration, func() {
+		valve.device.log.Info("duration expired")
+		valve.SetActive(characteristic.ActiveInactive, characteristic.ActiveActive)
+	})
+}
+
+// remainingDuration returns the time until the valve is closed by the timer.
+// This is requested by HomeKit when the remaining duration is shown.
+//
+// Returns:
+//   - int: The remaining duration in seconds, or 0 if no timer is running
+func (valve *Valve) remainingDuration() int {
+	valve.mu.Lock()
+	defer valve.mu.Unlock()
+
+	if valve.timer == nil {
+		return 0
+	}
+	return max(int(math.Ceil(time.Until(valve.closeAt).Seconds())), 0)
+}
+
+// UpdateState updates the valve's state based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+//
+// Parameters:
+//   - state: The updated state object from deCONZ
+func (valve *Valve) UpdateState(state deconz.MapObject) {
+	// Ignore updates for a short period after a user-initiated change
+	// to prevent feedback loops
+	if valve.feedback.suppressed() {
+		return
+	}
+
+	// Update the valve if it was opened or closed outside of HomeKit
+	if state.Has("on") {
+		open := state.ValueToBool("on")
+		if open != (valve.service.Active.Value() == characteristic.ActiveActive) {
+			valve.device.log.Infof("valve %s", onOffStr[open])
+			valve.setOpen(open)
+		}
+	}
+}
+
+// UpdateConfig updates the valve's configuration based on updates from the deCONZ gateway.
+// This method implements the DeviceService interface.
+// Valves don't have configuration parameters that need to be updated.
+//
+// Parameters:
+//   - config: The updated configuration object from deCONZ (not used for valves)
+func (valve *Valve) UpdateConfig(_ deconz.MapObject) {
+	// nothing to do
+}
+
+// newValve creates a new valve service of the given type.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//   - valveType: The HomeKit valve type (e.g. characteristic.ValveTypeIrrigation)
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) newValve(config *deconz.Subdevice, valveType int) error {
+	valve := new(Valve)
+	valve.ID = config.UniqueId
+	valve.device = device
+	valve.feedback = newFeedbackGuard(device.options.feedbackWindow(device.ID, config.UniqueId))
+	valve.commands = newCommandQueue()
+
+	// Create a new HomeKit valve service
+	valve.service = service.NewValve()
+	_ = valve.service.ValveType.SetValue(valveType)
+	valve.service.Active.OnValueUpdate(func(v, previous int, r *http.Request) {
+		if r != nil {
+			valve.SetActive(v, previous)
+		}
+	})
+
+	// Add the duration characteristics, the timer is handled by the bridge
+	valve.SetDuration = characteristic.NewSetDuration()
+	valve.service.AddC(valve.SetDuration.C)
+	valve.RemainingDuration = characteristic.NewRemainingDuration()
+	valve.RemainingDuration.ValueRequestFunc = func(*http.Request) (interface{}, int) {
+		return valve.remainingDuration(), 0
+	}
+	valve.service.AddC(valve.RemainingDuration.C)
+
+	// Initialize the valve state from the current deCONZ state
+	if config.State.Has("on") {
+		valve.setOpen(config.State.ValueToBool("on"))
+	}
+
+	// Register the service with the device
+	device.addDeviceService(config.UniqueId, valve)
+	return nil
+}
+
+// NewValve creates a new generic valve service.
+// This is used for on/off outputs whose model is configured with the "valve" service
+// in the device configuration.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewValve(config *deconz.Subdevice) error {
+	return device.newValve(config, characteristic.ValveTypeGenericValve)
+}
+
+// NewIrrigationValve creates a new irrigation valve service.
+// This is used for on/off outputs whose model is configured with the "irrigation" service
+// in the device configuration.
+//
+// Parameters:
+//   - config: A pointer to the deCONZ subdevice configuration
+//
+// Returns:
+//   - error: An error if the service could not be created
+func (device *Device) NewIrrigationValve(config *deconz.Subdevice) error {
+	return device.newValve(config, characteristic.ValveTypeIrrigation)
+}
@@ -1,12 +1,16 @@
 // Package client provides HTTP client functionality for communicating with the deCONZ REST API.
 // It offers generic functions for making GET, POST, and PUT requests with JSON data,
 // and automatically handles serialization and deserialization of request and response data.
+// All requests are bound to a context, so that they are cancelled on shutdown.
 package client
 
 import (
 	"bytes"
+	"context"
+	"deconz-homekit/internal/metrics"
 	"encoding/json"
 	"net/http"
+	"time"
 )
 
 // parseResponse parses an HTTP response body into the specified type.
@@ -37,21 +41,34 @@ func parseResponse[R interface{}](resp *http.Response) (*R, error) {
 //   - R: The type to parse the response into
 //
 // Parameters:
+//   - ctx: Context for cancelling the request
 //   - url: The URL to send the request to
 //   - data: The data to send in the request body (will be serialized to JSON)
 //
 // Returns:
 //   - *R: A pointer to the parsed response data
 //   - error: An error if the request failed or the response could not be parsed
-func Post[R interface{}](url string, data any) (*R, error) {
+func Post[R interface{}](ctx context.Context, url string, data any) (*R, error) {
+	// Measure the latency of the request
+	defer metrics.ObserveRequest(http.MethodPost, time.Now())
+
 	// Serialize the request data to JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Send the POST request
-	resp, err := http.Post(url, "application/json", bytes.NewReader(jsonData))
+	// Create a new POST request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the content type header
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the request
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -68,13 +85,17 @@ func Post[R interface{}](url string, data any) (*R, error) {
 //   - R: The type to parse the response into
 //
 // Parameters:
+//   - ctx: Context for cancelling the request
 //   - url: The URL to send the request to
 //   - data: The data to send in the request body (will be serialized to JSON)
 //
 // Returns:
 //   - *R: A pointer to the parsed response data
 //   - error: An error if the request failed or the response could not be parsed
-func Put[R interface{}](url string, data any) (*R, error) {
+func Put[R interface{}](ctx context.Context, url string, data any) (*R, error) {
+	// Measure the latency of the request
+	defer metrics.ObserveRequest(http.MethodPut, time.Now())
+
 	// Serialize the request data to JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -82,7 +103,7 @@ func Put[R interface{}](url string, data any) (*R, error) {
 	}
 
 	// Create a new PUT request
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, err
 	}
@@ -108,14 +129,24 @@ func Put[R interface{}](url string, data any) (*R, error) {
 //   - R: The type to parse the response into
 //
 // Parameters:
+//   - ctx: Context for cancelling the request
 //   - url: The URL to send the request to
 //
 // Returns:
 //   - *R: A pointer to the parsed response data
 //   - error: An error if the request failed or the response could not be parsed
-func Get[R interface{}](url string) (*R, error) {
-	// Send the GET request
-	resp, err := http.Get(url)
+func Get[R interface{}](ctx context.Context, url string) (*R, error) {
+	// Measure the latency of the request
+	defer metrics.ObserveRequest(http.MethodGet, time.Now())
+
+	// Create a new GET request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1,17 +1,169 @@
 package deconz
 
+import (
+	"context"
+	"deconz-homekit/internal/client"
+	"deconz-homekit/internal/metrics"
+	"sync"
+	"time"
+)
+
 type ApiClient struct {
+	// ctx is the context of all requests, they are cancelled when it is done
+	ctx     context.Context
 	baseUrl string
 	apiKey  string
+	lights  *lightCache
+	log     Logger
+	limiter *rateLimiter
+
+	// requests limits the number of concurrent requests, each request holds a slot while it is running
+	requests chan struct{}
+
+	// refreshMu ensures that concurrent reads of the current light and sensor states cause a single request
+	refreshMu sync.Mutex
+
+	// sensors is the last snapshot of all sensors by their unique ID, it is guarded by refreshMu
+	sensors map[string]*Sensor
+
+	// sensorsFetched is the time the snapshot of the sensors was fetched
+	sensorsFetched time.Time
 }
 
-func NewApiClient(baseUrl string, apiKey string) *ApiClient {
+func NewApiClient(ctx context.Context, baseUrl string, apiKey string) *ApiClient {
 	return &ApiClient{
-		baseUrl: baseUrl,
-		apiKey:  apiKey,
+		ctx:      ctx,
+		baseUrl:  baseUrl,
+		apiKey:   apiKey,
+		lights:   newLightCache(LightCacheTTL),
+		log:      newStdLogger("[API] "),
+		limiter:  newRateLimiter(DefaultRateLimit),
+		requests: make(chan struct{}, DefaultConcurrencyLimit),
+	}
+}
+
+// Context returns the context of the client, which is done when the application shuts down.
+//
+// Returns:
+//   - context.Context: The context of all requests of the client
+func (ac *ApiClient) Context() context.Context {
+	return ac.ctx
+}
+
+// DefaultConcurrencyLimit is the default number of concurrent requests to the gateway.
+const DefaultConcurrencyLimit = 8
+
+// SetConcurrencyLimit sets the maximum number of concurrent requests to the gateway.
+// The limit applies to all requests, regardless of how many goroutines use the client,
+// so that a weak gateway isn't overwhelmed (e.g. by polling while many commands are sent).
+// Unlike the rate limit, which limits the number of commands per second, it limits the number
+// of requests in flight. Commands wait for the rate limit before they take a slot.
+// It must be called before the client is used.
+//
+// Parameters:
+//   - requests: The maximum number of concurrent requests (at least 1)
+func (ac *ApiClient) SetConcurrencyLimit(requests int) {
+	ac.requests = make(chan struct{}, max(requests, 1))
+}
+
+// acquire blocks until a request slot is available.
+//
+// Returns:
+//   - func(): The function releasing the slot once the request has finished
+//   - error: The error of the context if it is done before a slot is available
+func (ac *ApiClient) acquire() (func(), error) {
+	select {
+	case ac.requests <- struct{}{}:
+		return func() { <-ac.requests }, nil
+	case <-ac.ctx.Done():
+		return nil, ac.ctx.Err()
 	}
 }
 
+// get sends a GET request to the gateway within the concurrency limit.
+//
+// Parameters:
+//   - ac: A pointer to the API client
+//   - path: The path of the resource (e.g. "/lights")
+//
+// Returns:
+//   - *R: A pointer to the parsed response
+//   - error: Any error encountered during the request
+func get[R any](ac *ApiClient, path string) (*R, error) {
+	release, err := ac.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return client.Get[R](ac.ctx, ac.buildUrl(path))
+}
+
+// put sends a PUT request to the gateway within the concurrency limit.
+//
+// Parameters:
+//   - ac: A pointer to the API client
+//   - path: The path of the resource (e.g. "/lights/1/state")
+//   - data: The data to send in the request body
+//
+// Returns:
+//   - *R: A pointer to the parsed response
+//   - error: Any error encountered during the request
+func put[R any](ac *ApiClient, path string, data any) (*R, error) {
+	release, err := ac.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return client.Put[R](ac.ctx, ac.buildUrl(path), data)
+}
+
+// SetRateLimit sets the maximum number of commands per second sent to the gateway.
+// The limit is shared by all devices. Commands exceeding it are delayed, not dropped.
+//
+// Parameters:
+//   - commandsPerSecond: The maximum number of commands per second (0 to disable the limit)
+func (ac *ApiClient) SetRateLimit(commandsPerSecond float64) {
+	if commandsPerSecond <= 0 {
+		ac.limiter = nil
+		return
+	}
+	ac.limiter = newRateLimiter(commandsPerSecond)
+}
+
+// waitForCommand blocks until the rate limit allows sending another command.
+//
+// Returns:
+//   - error: The error of the context of the client if it is done while waiting
+func (ac *ApiClient) waitForCommand() error {
+	if ac.limiter == nil {
+		return nil
+	}
+	return ac.limiter.wait(ac.ctx)
+}
+
+// SetLogger replaces the logger of the API client, e.g. with the shared logger of the application.
+//
+// Parameters:
+//   - logger: The logger to use
+func (ac *ApiClient) SetLogger(logger Logger) {
+	ac.log = logger
+}
+
 func (ac *ApiClient) buildUrl(path string) string {
 	return ac.baseUrl + "/api/" + ac.apiKey + path
 }
+
+// countCommand records a command sent to the gateway in the metrics.
+//
+// Parameters:
+//   - kind: The kind of the command (e.g. "on", "bri", "ct")
+//   - err: The error returned by the command, if any
+//
+// Returns:
+//   - error: The error passed in, so the result can be returned directly
+func countCommand(kind string, err error) error {
+	metrics.CountCommand(kind, err)
+	return err
+}
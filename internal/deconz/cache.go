@@ -0,0 +1,110 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"sync"
+	"time"
+)
+
+// LightCacheTTL is the time after which cached light details are fetched again.
+const LightCacheTTL = 5 * time.Minute
+
+// LightStateTTL is the time after which the current states of the lights are fetched again.
+// It is short, because the states change frequently, but still allows a controller
+// to read many characteristics at once with a single request.
+const LightStateTTL = 2 * time.Second
+
+// SensorStateTTL is the time after which the current states of the sensors are fetched again.
+const SensorStateTTL = 2 * time.Second
+
+// lightCacheEntry is a cached light together with the time it was fetched.
+type lightCacheEntry struct {
+	light   *Light
+	fetched time.Time
+}
+
+// lightCache caches the details of lights, so that capabilities (e.g. the color
+// temperature range) don't have to be requested from the gateway more than once.
+// It is safe for concurrent use.
+type lightCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]lightCacheEntry
+}
+
+// newLightCache creates an empty light cache.
+//
+// Parameters:
+//   - ttl: The time after which cached lights expire
+//
+// Returns:
+//   - *lightCache: A pointer to the initialized cache
+func newLightCache(ttl time.Duration) *lightCache {
+	return &lightCache{
+		ttl:     ttl,
+		entries: make(map[string]lightCacheEntry),
+	}
+}
+
+// get returns a cached light, if it exists and has not expired.
+//
+// Parameters:
+//   - id: The identifier of the light
+//
+// Returns:
+//   - *Light: A pointer to the cached light
+//   - bool: False if the light is not cached or has expired
+func (c *lightCache) get(id string) (*Light, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || time.Since(entry.fetched) > c.ttl {
+		delete(c.entries, id)
+		return nil, false
+	}
+	return entry.light, true
+}
+
+// getFresh returns a cached light, if it has been fetched within the given age.
+//
+// Parameters:
+//   - id: The identifier of the light
+//   - maxAge: The maximum time since the light was fetched
+//
+// Returns:
+//   - *Light: A pointer to the cached light
+//   - bool: False if the light is not cached or is older than maxAge
+func (c *lightCache) getFresh(id string, maxAge time.Duration) (*Light, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || time.Since(entry.fetched) > maxAge {
+		return nil, false
+	}
+	return entry.light, true
+}
+
+// set stores a light in the cache.
+//
+// Parameters:
+//   - id: The identifier of the light
+//   - light: A pointer to the light to cache
+func (c *lightCache) set(id string, light *Light) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[id] = lightCacheEntry{light: light, fetched: time.Now()}
+}
+
+// invalidate removes a light from the cache.
+//
+// Parameters:
+//   - id: The identifier of the light
+func (c *lightCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
@@ -1,8 +1,6 @@
 package deconz
 
-import (
-	"deconz-homekit/internal/client"
-)
+import ()
 
 type Configuration struct {
 	ApiVersion          string  `json:"apiversion"`
@@ -34,12 +32,35 @@ type Configuration struct {
 }
 
 func (ac *ApiClient) GetConfiguration() (*Configuration, error) {
-	return client.Get[Configuration](ac.buildUrl("/config"))
+	return get[Configuration](ac, "/config")
 }
 
+// GatewayState is the full state of the gateway, as returned by /api/{key}.
 type GatewayState struct {
+	Config  Configuration     `json:"config"`
+	Lights  map[string]Light  `json:"lights"`
+	Sensors map[string]Sensor `json:"sensors"`
+	Groups  map[string]*Group `json:"groups"`
 }
 
-func (ac *ApiClient) GetState() (*GatewayState, error) {
-	return client.Get[GatewayState](ac.baseUrl + "/api/" + ac.apiKey)
+// GetFullState retrieves the configuration and all lights, sensors and groups with a single request.
+// The lights are added to the light cache and the group identifiers are copied into the groups,
+// like GetAllLights and GetGroups do.
+func (ac *ApiClient) GetFullState() (*GatewayState, error) {
+	state, err := get[GatewayState](ac, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for id, light := range state.Lights {
+		ac.lights.set(id, &light)
+		if len(light.UniqueID) > 0 {
+			ac.lights.set(light.UniqueID, &light)
+		}
+	}
+	for id, group := range state.Groups {
+		group.ID = id
+	}
+
+	return state, nil
 }
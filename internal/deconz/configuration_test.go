@@ -0,0 +1,50 @@
+package deconz
+
+import "testing"
+
+func TestGetFullState(t *testing.T) {
+	client, server := newFixtureClient(t, map[string]string{"": "full_state.json"})
+
+	state, err := client.GetFullState()
+	if err != nil {
+		t.Fatalf("GetFullState() error = %v", err)
+	}
+
+	if state.Config.BridgeId != "00212EFFFF012345" || state.Config.ApiVersion != "1.16.0" || state.Config.WebsocketPort != 443 {
+		t.Errorf("config = %+v", state.Config)
+	}
+	if len(state.Lights) != 2 || len(state.Sensors) != 1 || len(state.Groups) != 1 {
+		t.Fatalf("got %d lights, %d sensors and %d groups, want 2, 1 and 1", len(state.Lights), len(state.Sensors), len(state.Groups))
+	}
+
+	ceiling := state.Lights["1"]
+	if ceiling.Type != "Extended color light" || ceiling.ColorCapabilities == nil || *ceiling.ColorCapabilities != 31 {
+		t.Errorf("light 1 = %+v, want the extended color light", ceiling)
+	}
+	if ceiling.State.ColorTemperature == nil || *ceiling.State.ColorTemperature != 366 {
+		t.Errorf("ct = %v, want 366", ceiling.State.ColorTemperature)
+	}
+	if got := state.Sensors["2"].State.ValueToInt("temperature"); got != 2154 {
+		t.Errorf("temperature = %d, want 2154", got)
+	}
+
+	// The identifiers are copied into the groups, like GetGroups does
+	group := state.Groups["1"]
+	if group.ID != "1" || group.Name != "Living Room" || len(group.Lights) != 2 || group.State.AnyOn == nil || !*group.State.AnyOn {
+		t.Errorf("group 1 = %+v", group)
+	}
+
+	// The lights are cached by their resource ID and unique ID
+	for _, id := range []string{"2", "00:0d:6f:ff:fe:5a:1c:2e-01"} {
+		light, err := client.GetLight(id)
+		if err != nil {
+			t.Fatalf("GetLight(%s) error = %v", id, err)
+		}
+		if light.Name != "Plug" {
+			t.Errorf("GetLight(%s) = %s, want the plug", id, light.Name)
+		}
+	}
+	if got := server.requests.Load(); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,128 @@
+package deconz
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadFixture decodes a recorded response of the gateway from the testdata directory.
+func loadFixture[T any](t *testing.T, name string) T {
+	t.Helper()
+
+	var value T
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &value); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	return value
+}
+
+func TestDecodeExtendedColorLight(t *testing.T) {
+	light := loadFixture[map[string]Light](t, "lights.json")["1"]
+
+	if light.Type != "Extended color light" || light.ModelID != "LCT015" || light.UniqueID != "00:17:88:01:03:28:4a:1b-0b" {
+		t.Errorf("light = %s %s %s", light.Type, light.ModelID, light.UniqueID)
+	}
+
+	// Capabilities which are reported are present
+	if light.ColorCapabilities == nil || *light.ColorCapabilities != 31 {
+		t.Errorf("ColorCapabilities = %v, want 31", light.ColorCapabilities)
+	}
+	if light.CtMin == nil || *light.CtMin != 153 || light.CtMax == nil || *light.CtMax != 500 {
+		t.Errorf("CtMin and CtMax = %v %v, want 153 500", light.CtMin, light.CtMax)
+	}
+	if light.PowerUp == nil || *light.PowerUp != PowerUpOn|PowerUpBrightness|PowerUpColor {
+		t.Errorf("PowerUp = %v, want 7", light.PowerUp)
+	}
+
+	state := light.State
+	if state.On == nil || !*state.On || state.Brightness == nil || *state.Brightness != 1 {
+		t.Errorf("on and bri = %v %v, want true 1", state.On, state.Brightness)
+	}
+	if state.ColorMode == nil || *state.ColorMode != "xy" || state.XY == nil || *state.XY != [2]float64{0.6915, 0.3083} {
+		t.Errorf("colormode and xy = %v %v, want xy [0.6915 0.3083]", state.ColorMode, state.XY)
+	}
+
+	// Zero values are present and not confused with absent values
+	if state.Hue == nil || *state.Hue != 0 {
+		t.Errorf("Hue = %v, want 0", state.Hue)
+	}
+	if state.Saturation == nil || *state.Saturation != 254 || state.ColorTemperature == nil || *state.ColorTemperature != 153 {
+		t.Errorf("sat and ct = %v %v, want 254 153", state.Saturation, state.ColorTemperature)
+	}
+}
+
+func TestDecodeOnOffPlug(t *testing.T) {
+	plug := loadFixture[map[string]Light](t, "lights.json")["2"]
+
+	if plug.Type != "On/Off plug-in unit" || plug.ManufactureName != "IKEA of Sweden" || plug.SwVersion != "2.3.089" {
+		t.Errorf("plug = %s %s %s", plug.Type, plug.ManufactureName, plug.SwVersion)
+	}
+
+	// An off plug reports on as false, which must not be confused with a missing value
+	if plug.State.On == nil || *plug.State.On {
+		t.Errorf("On = %v, want false", plug.State.On)
+	}
+	if plug.State.Reachable == nil || !*plug.State.Reachable {
+		t.Errorf("Reachable = %v, want true", plug.State.Reachable)
+	}
+
+	// Values a plug doesn't support are absent
+	if plug.ColorCapabilities != nil || plug.CtMin != nil || plug.CtMax != nil || plug.PowerUp != nil {
+		t.Errorf("capabilities = %v %v %v %v, want none", plug.ColorCapabilities, plug.CtMin, plug.CtMax, plug.PowerUp)
+	}
+	if plug.State.Brightness != nil || plug.State.ColorTemperature != nil || plug.State.XY != nil || plug.State.ColorMode != nil {
+		t.Errorf("state = %+v, want no brightness or color", plug.State)
+	}
+
+	// Only the present values are passed on, like the values of an event
+	if keys := slices.Sorted(maps.Keys(plug.State.ObjectMap())); !slices.Equal(keys, []string{"alert", "on", "reachable"}) {
+		t.Errorf("ObjectMap() keys = %v, want [alert on reachable]", keys)
+	}
+}
+
+func TestDecodeTemperatureSensor(t *testing.T) {
+	sensor := loadFixture[map[string]Sensor](t, "sensors.json")["2"]
+
+	if sensor.Type != "ZHATemperature" || sensor.ModelId != "lumi.weather" || sensor.Endpoint != 1 {
+		t.Errorf("sensor = %s %s %d", sensor.Type, sensor.ModelId, sensor.Endpoint)
+	}
+	if got := sensor.State.ValueToFloat("temperature"); got != 2154 {
+		t.Errorf("temperature = %v, want 2154", got)
+	}
+	if got := sensor.Config.ValueToInt("battery"); got != 87 {
+		t.Errorf("battery = %d, want 87", got)
+	}
+	if !sensor.Config.Has("offset") || sensor.Config.ValueToInt("offset") != 0 {
+		t.Error("the offset of 0 is missing")
+	}
+	if sensor.State.Has("humidity") || sensor.Config.Has("temperature") {
+		t.Error("absent values are reported as present")
+	}
+}
+
+func TestDecodeMultiButtonRemote(t *testing.T) {
+	remote := loadFixture[Sensor](t, "remote.json")
+
+	if remote.Type != "ZHASwitch" || remote.ModelId != "TRADFRI remote control" || remote.UniqueId != "00:0d:6f:ff:fe:8a:2b:3c-01-1000" {
+		t.Errorf("remote = %s %s %s", remote.Type, remote.ModelId, remote.UniqueId)
+	}
+
+	// The button event contains the button (4) and the action (002, short release)
+	if got := remote.State.ValueToInt("buttonevent"); got != 4002 {
+		t.Errorf("buttonevent = %d, want 4002", got)
+	}
+	if got := remote.Config.ValueToString("group"); got != "4,5" {
+		t.Errorf("group = %q, want %q", got, "4,5")
+	}
+	if remote.LastSeen != "2024-05-01T09:41Z" {
+		t.Errorf("LastSeen = %q", remote.LastSeen)
+	}
+}
@@ -0,0 +1,524 @@
+// Package deconztest provides a fake deCONZ gateway for tests.
+// It serves the REST API resources used by the bridge (/devices, /lights, /sensors and /config)
+// and a WebSocket which sends scripted event frames, so that the whole path from a gateway
+// event to the HomeKit characteristics can be tested without a real gateway.
+package deconztest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// APIKey is the API key the gateway accepts.
+const APIKey = "0123456789"
+
+// Request is a write request received by the gateway.
+type Request struct {
+	// Method is the HTTP method of the request (e.g. "PUT")
+	Method string
+
+	// Path is the path of the resource below the API key (e.g. "/lights/1/state")
+	Path string
+
+	// Body is the decoded JSON body of the request
+	Body map[string]any
+}
+
+// Gateway is a fake deCONZ gateway backed by an httptest server.
+// The resources are kept as decoded JSON, so tests can use the payloads of real gateways.
+type Gateway struct {
+	*httptest.Server
+
+	// mu guards all fields below
+	mu sync.Mutex
+
+	// devices are the devices by their unique ID
+	devices map[string]map[string]any
+
+	// lights are the lights by their resource ID
+	lights map[string]map[string]any
+
+	// sensors are the sensors by their resource ID
+	sensors map[string]map[string]any
+
+	// config is the configuration of the gateway
+	config map[string]any
+
+	// requests are the write requests received so far
+	requests []Request
+
+	// reads are the paths of the read requests of the REST API received so far
+	reads []string
+
+	// failing makes all write requests fail with a deCONZ error
+	failing bool
+
+	// delay is the time the gateway takes to answer a request for lights or sensors
+	delay time.Duration
+
+	// script are the frames sent to every new WebSocket connection
+	script []string
+
+	// conns are the open WebSocket connections
+	conns []*websocket.Conn
+
+	// connected is signalled whenever a WebSocket connection was accepted
+	connected chan struct{}
+}
+
+// NewGateway starts a fake gateway, which is closed when the test has finished.
+//
+// Parameters:
+//   - t: The test using the gateway
+//
+// Returns:
+//   - *Gateway: A pointer to the running gateway
+func NewGateway(t testing.TB) *Gateway {
+	t.Helper()
+
+	g := &Gateway{
+		devices:   make(map[string]map[string]any),
+		lights:    make(map[string]map[string]any),
+		sensors:   make(map[string]map[string]any),
+		config:    map[string]any{"bridgeid": "00212EFFFF000000", "name": "Phoscon-GW", "apiversion": "1.16.0"},
+		connected: make(chan struct{}, 16),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws", g.serveEvents)
+	mux.HandleFunc("GET /api/"+APIKey, g.serveFullState)
+	mux.HandleFunc("GET /api/"+APIKey+"/config", g.serveConfig)
+	mux.HandleFunc("GET /api/"+APIKey+"/devices", g.serveDeviceList)
+	mux.HandleFunc("GET /api/"+APIKey+"/devices/{id}", g.serveDevice)
+	mux.HandleFunc("GET /api/"+APIKey+"/lights", g.serveAll(func() map[string]map[string]any { return g.lights }))
+	mux.HandleFunc("GET /api/"+APIKey+"/lights/{id}", g.serveOne(func() map[string]map[string]any { return g.lights }))
+	mux.HandleFunc("PUT /api/"+APIKey+"/lights/{id}/state", g.serveWrite)
+	mux.HandleFunc("PUT /api/"+APIKey+"/lights/{id}/config", g.serveWrite)
+	mux.HandleFunc("GET /api/"+APIKey+"/sensors", g.serveAll(func() map[string]map[string]any { return g.sensors }))
+	mux.HandleFunc("GET /api/"+APIKey+"/sensors/{id}", g.serveOne(func() map[string]map[string]any { return g.sensors }))
+	mux.HandleFunc("PUT /api/"+APIKey+"/sensors/{id}/config", g.serveWrite)
+
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := strings.CutPrefix(r.URL.Path, "/api/"+APIKey); ok && r.Method == http.MethodGet {
+			g.mu.Lock()
+			g.reads = append(g.reads, path)
+			g.mu.Unlock()
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(g.Close)
+
+	return g
+}
+
+// Close closes the WebSocket connections and shuts down the gateway.
+func (g *Gateway) Close() {
+	g.mu.Lock()
+	for _, conn := range g.conns {
+		_ = conn.Close()
+	}
+	g.conns = nil
+	g.mu.Unlock()
+
+	g.Server.Close()
+}
+
+// EventURL returns the URL of the WebSocket of the gateway.
+//
+// Returns:
+//   - string: The ws:// URL for deconz.NewEventClient
+func (g *Gateway) EventURL() string {
+	return "ws" + strings.TrimPrefix(g.URL, "http") + "/ws"
+}
+
+// AddDevice adds a device, which is served by /devices. The device must have a "uniqueid".
+// The lights and sensors of its subdevices have to be added separately.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - device: The JSON of the device, as returned by /devices/{uniqueid}
+func (g *Gateway) AddDevice(t testing.TB, device string) {
+	t.Helper()
+
+	resource := decode(t, device)
+	id, _ := resource["uniqueid"].(string)
+	if len(id) == 0 {
+		t.Fatalf("the device has no unique ID: %s", device)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.devices[id] = resource
+}
+
+// AddLight adds a light, which is served by /lights.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - id: The resource ID of the light (e.g. "1")
+//   - light: The JSON of the light, as returned by /lights/{id}
+func (g *Gateway) AddLight(t testing.TB, id string, light string) {
+	t.Helper()
+
+	resource := decode(t, light)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lights[id] = resource
+}
+
+// AddSensor adds a sensor, which is served by /sensors.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - id: The resource ID of the sensor (e.g. "1")
+//   - sensor: The JSON of the sensor, as returned by /sensors/{id}
+func (g *Gateway) AddSensor(t testing.TB, id string, sensor string) {
+	t.Helper()
+
+	resource := decode(t, sensor)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sensors[id] = resource
+}
+
+// SetConfig replaces the configuration, which is served by /config.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - config: The JSON of the configuration
+func (g *Gateway) SetConfig(t testing.TB, config string) {
+	t.Helper()
+
+	resource := decode(t, config)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.config = resource
+}
+
+// SetFailing sets whether write requests fail with a deCONZ error (e.g. for an unreachable light).
+//
+// Parameters:
+//   - failing: Whether write requests fail
+func (g *Gateway) SetFailing(failing bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failing = failing
+}
+
+// SetDelay sets the time the gateway takes to answer a request for lights or sensors
+// (e.g. for a busy gateway).
+//
+// Parameters:
+//   - delay: The time before the answer is sent
+func (g *Gateway) SetDelay(delay time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.delay = delay
+}
+
+// Script sets the frames which are sent to every new WebSocket connection.
+//
+// Parameters:
+//   - frames: The JSON frames to send after a client connected
+func (g *Gateway) Script(frames ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.script = frames
+}
+
+// Send sends a frame to all open WebSocket connections.
+// It waits for a connection, if no client has connected yet.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - frame: The JSON frame to send (e.g. an event)
+func (g *Gateway) Send(t testing.TB, frame string) {
+	t.Helper()
+
+	g.WaitForConnection(t)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, conn := range g.conns {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Errorf("failed to send the frame: %v", err)
+		}
+	}
+}
+
+// WaitForConnection waits until a client is connected to the WebSocket.
+//
+// Parameters:
+//   - t: The test using the gateway
+func (g *Gateway) WaitForConnection(t testing.TB) {
+	t.Helper()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		g.mu.Lock()
+		connected := len(g.conns) > 0
+		g.mu.Unlock()
+		if connected {
+			return
+		}
+
+		select {
+		case <-g.connected:
+		case <-deadline:
+			t.Fatal("no client connected to the WebSocket")
+		}
+	}
+}
+
+// Requests returns the write requests received so far.
+//
+// Returns:
+//   - []Request: The requests in the order they were received
+func (g *Gateway) Requests() []Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.requests)
+}
+
+// Reads returns the number of read requests of the REST API received so far,
+// whose path below the API key starts with the given prefix (e.g. "/lights/").
+//
+// Parameters:
+//   - prefix: The prefix of the paths to count (empty to count all reads)
+//
+// Returns:
+//   - int: The number of matching read requests
+func (g *Gateway) Reads(prefix string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	count := 0
+	for _, path := range g.reads {
+		if strings.HasPrefix(path, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// WaitForRequests waits until the gateway has received at least the given number of write requests.
+//
+// Parameters:
+//   - t: The test using the gateway
+//   - n: The number of requests to wait for
+//
+// Returns:
+//   - []Request: The requests received so far
+func (g *Gateway) WaitForRequests(t testing.TB, n int) []Request {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		requests := g.Requests()
+		if len(requests) >= n {
+			return requests
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d write requests, want %d", len(requests), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// serveEvents upgrades a request to a WebSocket, sends the scripted frames and
+// keeps the connection open until the client or the gateway closes it.
+func (g *Gateway) serveEvents(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	// Send the script before the connection is announced, so the frames are sent in order
+	g.mu.Lock()
+	for _, frame := range g.script {
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			g.mu.Unlock()
+			return
+		}
+	}
+	g.conns = append(g.conns, conn)
+	g.mu.Unlock()
+
+	select {
+	case g.connected <- struct{}{}:
+	default:
+	}
+
+	// Read until the connection is closed, this also answers the pings of the client
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	g.mu.Lock()
+	g.conns = slices.DeleteFunc(g.conns, func(c *websocket.Conn) bool { return c == conn })
+	g.mu.Unlock()
+}
+
+// serveFullState serves the configuration and all lights and sensors, like /api/{key}.
+func (g *Gateway) serveFullState(w http.ResponseWriter, _ *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeJSON(w, map[string]any{
+		"config":  g.config,
+		"lights":  g.lights,
+		"sensors": g.sensors,
+		"groups":  map[string]any{},
+	})
+}
+
+// serveConfig serves the configuration of the gateway.
+func (g *Gateway) serveConfig(w http.ResponseWriter, _ *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeJSON(w, g.config)
+}
+
+// serveDeviceList serves the unique IDs of all devices, sorted so the order is stable.
+func (g *Gateway) serveDeviceList(w http.ResponseWriter, _ *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeJSON(w, slices.Sorted(maps.Keys(g.devices)))
+}
+
+// serveDevice serves a single device by its unique ID.
+func (g *Gateway) serveDevice(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if device, ok := g.devices[r.PathValue("id")]; ok {
+		writeJSON(w, device)
+		return
+	}
+	writeNotFound(w, r.URL.Path)
+}
+
+// serveAll creates a handler serving all resources of a kind by their resource ID.
+func (g *Gateway) serveAll(resources func() map[string]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		g.wait()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		writeJSON(w, resources())
+	}
+}
+
+// wait delays the answer of a request for lights or sensors.
+func (g *Gateway) wait() {
+	g.mu.Lock()
+	delay := g.delay
+	g.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// serveOne creates a handler serving a single resource by its resource ID or unique ID,
+// as the gateway accepts both.
+func (g *Gateway) serveOne(resources func() map[string]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g.wait()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if _, resource := lookup(resources(), r.PathValue("id")); resource != nil {
+			writeJSON(w, resource)
+			return
+		}
+		writeNotFound(w, r.URL.Path)
+	}
+}
+
+// serveWrite records a write request, applies a state change to the stored light and
+// confirms every value, like the gateway does.
+func (g *Gateway) serveWrite(w http.ResponseWriter, r *http.Request) {
+	body := map[string]any{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	address := strings.TrimPrefix(r.URL.Path, "/api/"+APIKey)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, Request{Method: r.Method, Path: address, Body: body})
+
+	if g.failing {
+		writeJSON(w, []any{map[string]any{"error": map[string]any{
+			"type": 201, "address": address, "description": "resource not available",
+		}}})
+		return
+	}
+
+	// Apply state changes, so that refreshes return the new state
+	if strings.HasPrefix(address, "/lights/") && strings.HasSuffix(address, "/state") {
+		if _, light := lookup(g.lights, r.PathValue("id")); light != nil {
+			state, _ := light["state"].(map[string]any)
+			if state == nil {
+				state = map[string]any{}
+				light["state"] = state
+			}
+			maps.Copy(state, body)
+		}
+	}
+
+	response := []any{}
+	for _, key := range slices.Sorted(maps.Keys(body)) {
+		response = append(response, map[string]any{"success": map[string]any{address + "/" + key: body[key]}})
+	}
+	writeJSON(w, response)
+}
+
+// lookup finds a resource by its resource ID or its unique ID.
+func lookup(resources map[string]map[string]any, id string) (string, map[string]any) {
+	if resource, ok := resources[id]; ok {
+		return id, resource
+	}
+	for key, resource := range resources {
+		if uniqueId, _ := resource["uniqueid"].(string); uniqueId == id {
+			return key, resource
+		}
+	}
+	return "", nil
+}
+
+// decode decodes the JSON of a resource and fails the test if it is invalid.
+func decode(t testing.TB, resource string) map[string]any {
+	t.Helper()
+
+	decoded := map[string]any{}
+	if err := json.Unmarshal([]byte(resource), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return decoded
+}
+
+// writeJSON writes a value as the JSON response.
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeNotFound writes the error the gateway returns for a missing resource.
+func writeNotFound(w http.ResponseWriter, address string) {
+	w.WriteHeader(http.StatusNotFound)
+	writeJSON(w, []any{map[string]any{"error": map[string]any{
+		"type": 3, "address": address, "description": fmt.Sprintf("resource, %s, not available", address),
+	}}})
+}
@@ -1,11 +1,6 @@
 // Package deconz provides interfaces and types for interacting with the deCONZ REST API.
 package deconz
 
-import (
-	"deconz-homekit/internal/client"
-	"fmt"
-)
-
 // Value represents a device state or configuration value with its last update timestamp.
 // This structure is used to track when a particular value was last changed.
 type Value struct {
@@ -54,6 +49,9 @@ type Device struct {
 	// SwVersion is the firmware version running on the device
 	SwVersion string `json:"swversion"`
 
+	// LastSeen is the timestamp when the device was last seen by the gateway
+	LastSeen string `json:"lastseen"`
+
 	// Subdevices is a list of functional components within this device
 	Subdevices []Subdevice `json:"subdevices"`
 }
@@ -64,7 +62,7 @@ type Device struct {
 //   - *[]string: A pointer to a slice of device unique identifiers
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) ListDevices() (*[]string, error) {
-	return client.Get[[]string](ac.buildUrl("/devices"))
+	return get[[]string](ac, "/devices")
 }
 
 // GetDevice retrieves detailed information about a specific device from the deCONZ gateway.
@@ -76,7 +74,7 @@ func (ac *ApiClient) ListDevices() (*[]string, error) {
 //   - *Device: A pointer to the retrieved Device structure
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) GetDevice(uniqueId string) (*Device, error) {
-	return client.Get[Device](ac.buildUrl("/devices/" + uniqueId))
+	return get[Device](ac, "/devices/"+uniqueId)
 }
 
 // GetAllDevices retrieves detailed information about all devices from the deCONZ gateway.
@@ -96,10 +94,15 @@ func (ac *ApiClient) GetAllDevices() ([]*Device, error) {
 
 	// Query each device individually to get detailed information
 	for _, deviceId := range *devicesList {
+		// Stop early on shutdown instead of failing for every remaining device
+		if err := ac.ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		device, err := ac.GetDevice(deviceId)
 		if err != nil {
 			// Log the error but continue with other devices
-			fmt.Println(err)
+			ac.log.Warnf("failed to get device %s: %+v", deviceId, err)
 			continue
 		}
 		allDevices = append(allDevices, device)
@@ -132,6 +132,10 @@ const (
 	// These sensors detect and report motion or presence in an area.
 	PresenceSensorDevice DeviceType = "ZHAPresence"
 
+	// PowerDevice represents a ZHA power sensor.
+	// These sensors measure and report the current power, voltage, and current.
+	PowerDevice DeviceType = "ZHAPower"
+
 	// PressureDevice represents a ZHA pressure sensor.
 	// These sensors measure and report atmospheric pressure.
 	PressureDevice DeviceType = "ZHAPressure"
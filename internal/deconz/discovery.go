@@ -0,0 +1,92 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/brutella/dnssd"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscoveryService is the mDNS service type advertised by deCONZ gateways.
+const DiscoveryService = "_deconz._tcp.local."
+
+// Discover browses the local network for deCONZ gateways via mDNS (Bonjour).
+// The lookup runs until the timeout expires. It only succeeds if exactly one gateway
+// was found, because the correct gateway can't be chosen automatically otherwise.
+//
+// Parameters:
+//   - ctx: Context for cancelling the discovery
+//   - timeout: The duration to wait for gateways to respond
+//
+// Returns:
+//   - string: The host (IP address) of the discovered gateway
+//   - int: The port of the discovered gateway
+//   - error: An error if no gateway or more than one gateway was found
+func Discover(ctx context.Context, timeout time.Duration) (string, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Collect all gateways found during the lookup by their instance name
+	var mu sync.Mutex
+	entries := make(map[string]dnssd.BrowseEntry)
+	add := func(e dnssd.BrowseEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		entries[e.ServiceInstanceName()] = e
+	}
+	rmv := func(e dnssd.BrowseEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(entries, e.ServiceInstanceName())
+	}
+
+	// The lookup blocks until the context expires
+	if err := dnssd.LookupType(ctx, DiscoveryService, add, rmv); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return "", 0, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch len(entries) {
+	case 0:
+		return "", 0, errors.New("no deCONZ gateway found")
+	case 1:
+		for _, e := range entries {
+			return entryHost(e), e.Port, nil
+		}
+	}
+
+	// Several gateways were found, so the user has to choose one
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, fmt.Sprintf("%s (%s:%d)", e.Name, entryHost(e), e.Port))
+	}
+	slices.Sort(names)
+	return "", 0, fmt.Errorf("found %d deCONZ gateways: %s", len(entries), strings.Join(names, ", "))
+}
+
+// entryHost returns the address of a discovered service, preferring IPv4 addresses.
+//
+// Parameters:
+//   - e: The discovered service entry
+//
+// Returns:
+//   - string: The IP address or, if no address is known, the host name of the service
+func entryHost(e dnssd.BrowseEntry) string {
+	for _, ip := range e.IPs {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+	}
+	if len(e.IPs) > 0 {
+		return e.IPs[0].String()
+	}
+
+	return e.Host
+}
@@ -0,0 +1,148 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"deconz-homekit/internal/helper"
+	"maps"
+	"slices"
+)
+
+// Group represents a group of lights in the deCONZ ecosystem.
+// Groups are created in the Phoscon app and allow controlling several lights at once.
+type Group struct {
+	// ID is the identifier of the group (the key in the /groups response)
+	ID string `json:"id"`
+
+	// Action contains the last action that was sent to the group
+	Action LightState `json:"action"`
+
+	// DeviceMembership lists the sensors which created this group (hidden switch groups)
+	DeviceMembership []string `json:"devicemembership"`
+
+	// ETag is used for caching and resource versioning
+	ETag string `json:"etag"`
+
+	// Hidden indicates whether the group is hidden in the Phoscon app
+	Hidden bool `json:"hidden"`
+
+	// Lights is a list of light identifiers which are members of the group
+	Lights []string `json:"lights"`
+
+	// Name is the user-assigned name of the group
+	Name string `json:"name"`
+
+	// State contains the aggregated on/off state of all lights in the group
+	State GroupState `json:"state"`
+
+	// Type is the type of the group (e.g., "LightGroup")
+	Type string `json:"type"`
+}
+
+// GroupState represents the aggregated state of all lights in a group.
+type GroupState struct {
+	// AllOn indicates whether all lights of the group are turned on
+	AllOn *bool `json:"all_on,omitempty"`
+
+	// AnyOn indicates whether at least one light of the group is turned on
+	AnyOn *bool `json:"any_on,omitempty"`
+}
+
+// GetGroups retrieves all groups from the deCONZ gateway.
+//
+// Returns:
+//   - map[string]*Group: A map of group identifiers to Group structures
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) GetGroups() (map[string]*Group, error) {
+	groups, err := get[map[string]*Group](ac, "/groups")
+	if err != nil {
+		return nil, err
+	}
+
+	// The identifier is only part of the map key, so copy it into the group
+	for id, group := range *groups {
+		group.ID = id
+	}
+
+	return *groups, nil
+}
+
+// GetGroup retrieves detailed information about a specific group from the deCONZ gateway.
+//
+// Parameters:
+//   - id: The identifier of the group to retrieve
+//
+// Returns:
+//   - *Group: A pointer to the retrieved Group structure
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) GetGroup(id string) (*Group, error) {
+	group, err := get[Group](ac, "/groups/"+id)
+	if err != nil {
+		return nil, err
+	}
+	group.ID = id
+
+	return group, nil
+}
+
+// SetGroupState updates the state of all lights in a group with the provided settings.
+//
+// Parameters:
+//   - id: The identifier of the group to update
+//   - state: A pointer to a LightState structure containing the desired state changes
+//
+// Returns:
+//   - error: Any error encountered during the API request, or if the gateway refused a value
+func (ac *ApiClient) SetGroupState(id string, state *LightState) error {
+	if err := ac.waitForCommand(); err != nil {
+		return err
+	}
+	address := "/groups/" + id + "/action"
+	response, err := put[Response](ac, address, *state)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the gateway accepted every value of the state
+	return response.confirm(address, slices.Collect(maps.Keys(state.ObjectMap())))
+}
+
+// SetGroupOn turns all lights of a group on or off.
+//
+// Parameters:
+//   - id: The identifier of the group to control
+//   - on: Boolean value indicating whether to turn the group on (true) or off (false)
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetGroupOn(id string, on bool) error {
+	return countCommand("on", ac.SetGroupState(id, &LightState{
+		On: &on,
+	}))
+}
+
+// SetGroupBrightness sets the brightness of all lights of a group.
+// If brightness is 0, the group will be turned off.
+//
+// Parameters:
+//   - id: The identifier of the group to control
+//   - brightness: The desired brightness level as a percentage (0-100)
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetGroupBrightness(id string, brightness int) error {
+	return countCommand("bri", ac.SetGroupState(id, newBrightnessState(brightness, helper.DefaultMinBrightness)))
+}
+
+// SetGroupColorTemperature sets the color temperature of all lights of a group.
+//
+// Parameters:
+//   - id: The identifier of the group to control
+//   - mired: The desired color temperature in mireds
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetGroupColorTemperature(id string, mired int) error {
+	return countCommand("ct", ac.SetGroupState(id, &LightState{
+		ColorTemperature: &mired,
+	}))
+}
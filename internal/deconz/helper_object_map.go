@@ -1,13 +1,18 @@
 package deconz
 
-import "math"
+import (
+	"encoding/json"
+	"math"
+)
 
 type MapObject interface {
 	Has(key string) bool
 	ValueToBool(key string) bool
 	ValueToInt(key string) int
+	ValueToFloat(key string) float64
 	ValueToPercent(key string) int
 	ValueToString(key string) string
+	ValueToFloatSlice(key string) []float64
 }
 type ObjectMap map[string]interface{}
 
@@ -16,20 +21,29 @@ func (obj ObjectMap) Has(key string) bool {
 }
 
 func (obj ObjectMap) ValueToBool(key string) bool {
-	return obj[key].(bool)
+	value, _ := obj[key].(bool)
+	return value
 }
 
 func (obj ObjectMap) ValueToInt(key string) int {
-	return int(obj[key].(float64))
+	return toInt(obj[key])
+}
+
+func (obj ObjectMap) ValueToFloat(key string) float64 {
+	return toFloat(obj[key])
 }
 
 func (obj ObjectMap) ValueToString(key string) string {
-	return obj[key].(string)
+	value, _ := obj[key].(string)
+	return value
+}
+
+func (obj ObjectMap) ValueToFloatSlice(key string) []float64 {
+	return toFloatSlice(obj[key])
 }
 
 func (obj ObjectMap) ValueToPercent(key string) int {
-	value := obj[key].(float64)
-	return int(math.Round(value * 100.0 / 255.0))
+	return toPercent(obj[key])
 }
 
 type ExtendedObjectMap map[string]*struct {
@@ -42,18 +56,103 @@ func (obj ExtendedObjectMap) Has(key string) bool {
 }
 
 func (obj ExtendedObjectMap) ValueToBool(key string) bool {
-	return obj[key].Value.(bool)
+	if obj[key] == nil {
+		return false
+	}
+	value, _ := obj[key].Value.(bool)
+	return value
 }
 
 func (obj ExtendedObjectMap) ValueToInt(key string) int {
-	return int(obj[key].Value.(float64))
+	if obj[key] == nil {
+		return 0
+	}
+	return toInt(obj[key].Value)
+}
+
+func (obj ExtendedObjectMap) ValueToFloat(key string) float64 {
+	if obj[key] == nil {
+		return 0
+	}
+	return toFloat(obj[key].Value)
 }
 
 func (obj ExtendedObjectMap) ValueToString(key string) string {
-	return obj[key].Value.(string)
+	if obj[key] == nil {
+		return ""
+	}
+	value, _ := obj[key].Value.(string)
+	return value
+}
+
+func (obj ExtendedObjectMap) ValueToFloatSlice(key string) []float64 {
+	if obj[key] == nil {
+		return []float64{}
+	}
+	return toFloatSlice(obj[key].Value)
 }
 
 func (obj ExtendedObjectMap) ValueToPercent(key string) int {
-	value := obj[key].Value.(float64)
-	return int(math.Round(value * 100.0 / 255.0))
+	if obj[key] == nil {
+		return 0
+	}
+	return toPercent(obj[key].Value)
+}
+
+func toFloatSlice(value interface{}) []float64 {
+	values, _ := value.([]interface{})
+	result := make([]float64, 0, len(values))
+	for _, v := range values {
+		if f, ok := v.(float64); ok {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// toInt converts a numeric value to an int.
+// JSON numbers are decoded as float64, but values may also be created as integers
+// or json.Number. Unexpected types result in 0.
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		return int(toFloat(v))
+	default:
+		return int(toFloat(v))
+	}
+}
+
+// toFloat converts a numeric value to a float64 without losing fractional precision.
+// Like toInt, it accepts float64, integer and json.Number encodings. Unexpected types result in 0.
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	default:
+		return 0
+	}
+}
+
+func toPercent(value interface{}) int {
+	return int(math.Round(toFloat(value) * 100.0 / 255.0))
 }
@@ -0,0 +1,170 @@
+package deconz
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// extendedMap decodes a map in the format of the deCONZ devices endpoint.
+func extendedMap(t *testing.T, raw string) ExtendedObjectMap {
+	t.Helper()
+
+	obj := ExtendedObjectMap{}
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+		t.Fatalf("invalid extended map %s: %v", raw, err)
+	}
+	return obj
+}
+
+// objectMaps returns the same values as an ObjectMap and an ExtendedObjectMap.
+func objectMaps(t *testing.T, raw string) map[string]MapObject {
+	t.Helper()
+
+	plain := ObjectMap{}
+	if err := json.Unmarshal([]byte(raw), &plain); err != nil {
+		t.Fatalf("invalid object map %s: %v", raw, err)
+	}
+
+	// Wrap every value, e.g. {"on": true} becomes {"on": {"value": true}}
+	wrapped := map[string]map[string]any{}
+	for key, value := range plain {
+		wrapped[key] = map[string]any{"value": value}
+	}
+	data, _ := json.Marshal(wrapped)
+
+	return map[string]MapObject{"ObjectMap": plain, "ExtendedObjectMap": extendedMap(t, string(data))}
+}
+
+func TestMapObjectMissingAndNullValues(t *testing.T) {
+	for name, obj := range objectMaps(t, `{"colormode": null, "xy": null}`) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"colormode", "xy", "missing"} {
+				if got := obj.ValueToString(key); got != "" {
+					t.Errorf("ValueToString(%q) = %q, want empty", key, got)
+				}
+				if got := obj.ValueToFloatSlice(key); len(got) != 0 {
+					t.Errorf("ValueToFloatSlice(%q) = %v, want empty", key, got)
+				}
+				if got := obj.ValueToBool(key); got {
+					t.Errorf("ValueToBool(%q) = true, want false", key)
+				}
+				if got := obj.ValueToInt(key); got != 0 {
+					t.Errorf("ValueToInt(%q) = %d, want 0", key, got)
+				}
+				if got := obj.ValueToFloat(key); got != 0 {
+					t.Errorf("ValueToFloat(%q) = %v, want 0", key, got)
+				}
+				if got := obj.ValueToPercent(key); got != 0 {
+					t.Errorf("ValueToPercent(%q) = %d, want 0", key, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMapObjectValues(t *testing.T) {
+	for name, obj := range objectMaps(t, `{"colormode": "xy", "xy": [0.3, 0.4], "on": true, "bri": 255}`) {
+		t.Run(name, func(t *testing.T) {
+			if got := obj.ValueToString("colormode"); got != "xy" {
+				t.Errorf("ValueToString(colormode) = %q, want %q", got, "xy")
+			}
+			if got := obj.ValueToFloatSlice("xy"); len(got) != 2 || got[0] != 0.3 || got[1] != 0.4 {
+				t.Errorf("ValueToFloatSlice(xy) = %v, want [0.3 0.4]", got)
+			}
+			if !obj.ValueToBool("on") {
+				t.Error("ValueToBool(on) = false, want true")
+			}
+			if got := obj.ValueToPercent("bri"); got != 100 {
+				t.Errorf("ValueToPercent(bri) = %d, want 100", got)
+			}
+
+			// Values of another type are treated as missing
+			if got := obj.ValueToString("bri"); got != "" {
+				t.Errorf("ValueToString(bri) = %q, want empty", got)
+			}
+		})
+	}
+}
+
+// valueMaps returns the same Go value as an ObjectMap and an ExtendedObjectMap,
+// e.g. for values which are created in code instead of being decoded from JSON.
+func valueMaps(key string, value any) map[string]MapObject {
+	return map[string]MapObject{
+		"ObjectMap":         ObjectMap{key: value},
+		"ExtendedObjectMap": ExtendedObjectMap{key: {Value: value}},
+	}
+}
+
+func TestMapObjectIntRepresentations(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  int
+	}{
+		{"float64", float64(1002), 1002},
+		{"fractional float64", 21.7, 21},
+		{"negative float64", float64(-45), -45},
+		{"int", 1002, 1002},
+		{"int64", int64(1002), 1002},
+		{"int32", int32(1002), 1002},
+		{"json.Number", json.Number("1002"), 1002},
+		{"fractional json.Number", json.Number("21.7"), 21},
+		{"invalid json.Number", json.Number("abc"), 0},
+		{"string", "1002", 0},
+		{"bool", true, 0},
+	}
+
+	for _, tt := range tests {
+		for name, obj := range valueMaps("buttonevent", tt.value) {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if got := obj.ValueToInt("buttonevent"); got != tt.want {
+					t.Errorf("ValueToInt(%#v) = %d, want %d", tt.value, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMapObjectFloatRepresentations(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  float64
+	}{
+		{"float64", 21.57, 21.57},
+		{"whole float64", float64(1013), 1013},
+		{"negative float64", -12.34, -12.34},
+		{"float32", float32(0.5), 0.5},
+		{"int", 2154, 2154},
+		{"int64", int64(2154), 2154},
+		{"int32", int32(-500), -500},
+		{"json.Number", json.Number("21.57"), 21.57},
+		{"invalid json.Number", json.Number("abc"), 0},
+		{"string", "21.57", 0},
+		{"bool", true, 0},
+	}
+
+	for _, tt := range tests {
+		for name, obj := range valueMaps("temperature", tt.value) {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if got := obj.ValueToFloat("temperature"); got != tt.want {
+					t.Errorf("ValueToFloat(%#v) = %v, want %v", tt.value, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMapObjectFloatFromJSON(t *testing.T) {
+	// Fractional values aren't truncated, unlike ValueToInt
+	for name, obj := range objectMaps(t, `{"humidity": 4567, "pressure": 1013.25, "temperature": -2.5}`) {
+		t.Run(name, func(t *testing.T) {
+			want := map[string]float64{"humidity": 4567, "pressure": 1013.25, "temperature": -2.5}
+			for key, value := range want {
+				if got := obj.ValueToFloat(key); got != value {
+					t.Errorf("ValueToFloat(%q) = %v, want %v", key, got, value)
+				}
+			}
+		})
+	}
+}
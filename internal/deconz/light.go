@@ -2,8 +2,12 @@
 package deconz
 
 import (
-	"deconz-homekit/internal/client"
+	"deconz-homekit/internal/helper"
+	"encoding/json"
+	"fmt"
+	"maps"
 	"math"
+	"slices"
 )
 
 // Light represents a light device in the deCONZ ecosystem.
@@ -11,7 +15,7 @@ import (
 // including its capabilities, identification, and current settings.
 type Light struct {
 	// ColorCapabilities indicates the color features supported by the light
-	// Bit 0: Enhanced Hue, Bit 1: Enhanced Saturation, Bit 2: XY, Bit 3: Color Temperature
+	// Bit 0: Hue/Saturation, Bit 1: Enhanced Hue, Bit 2: Color Loop, Bit 3: XY, Bit 4: Color Temperature
 	ColorCapabilities *int `json:"colorcapabilities,omitempty"`
 
 	// CtMax is the maximum color temperature in mireds (higher = warmer)
@@ -38,7 +42,7 @@ type Light struct {
 	// ModelID is the model identifier of the light
 	ModelID string `json:"modelid"`
 
-	// PowerUp defines the behavior of the light when powered on
+	// PowerUp defines the behavior of the light when powered on (see PowerUpOn, PowerUpBrightness and PowerUpColor)
 	PowerUp *int `json:"powerup,omitempty"`
 
 	// SwVersion is the firmware version running on the light
@@ -54,6 +58,21 @@ type Light struct {
 	State LightState `json:"state"`
 }
 
+// Bits of the powerup setting of a light.
+// The powerup setting is a bitmap of the values a light restores after a power cut. Values whose
+// bit is not set are reset to the defaults of the light (usually on with full brightness),
+// so a value of 0 makes the light always turn on with its defaults. Lights may only support some bits.
+const (
+	// PowerUpOn restores the on/off state
+	PowerUpOn = 1 << 0
+
+	// PowerUpBrightness restores the brightness
+	PowerUpBrightness = 1 << 1
+
+	// PowerUpColor restores the color and color temperature
+	PowerUpColor = 1 << 2
+)
+
 // LightState represents the current state of a light device.
 // This includes properties like on/off status, brightness, color, and other settings.
 // All fields are pointers to allow for partial updates when changing state.
@@ -92,7 +111,21 @@ type LightState struct {
 	Reachable *bool `json:"reachable,omitempty"`
 }
 
+// ObjectMap converts the light state to an ObjectMap, like the state of a WebSocket event.
+//
+// Returns:
+//   - ObjectMap: The light state as a map
+func (state LightState) ObjectMap() ObjectMap {
+	result := ObjectMap{}
+	if data, err := json.Marshal(state); err == nil {
+		_ = json.Unmarshal(data, &result)
+	}
+	return result
+}
+
 // GetLight retrieves detailed information about a specific light from the deCONZ gateway.
+// The result is cached for LightCacheTTL, so repeated lookups of the same light
+// (e.g. while setting up several services) only cause a single API request.
 //
 // Parameters:
 //   - id: The identifier of the light to retrieve
@@ -101,7 +134,122 @@ type LightState struct {
 //   - *Light: A pointer to the retrieved Light structure
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) GetLight(id string) (*Light, error) {
-	return client.Get[Light](ac.buildUrl("/lights/" + id))
+	if light, ok := ac.lights.get(id); ok {
+		return light, nil
+	}
+
+	light, err := get[Light](ac, "/lights/"+id)
+	if err != nil {
+		return nil, err
+	}
+	ac.lights.set(id, light)
+
+	return light, nil
+}
+
+// GetAllLights retrieves detailed information about all lights from the deCONZ gateway
+// with a single API request. The lights are added to the cache, so that subsequent
+// calls to GetLight don't cause additional requests.
+//
+// Returns:
+//   - map[string]Light: A map of light identifiers to Light structures
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) GetAllLights() (map[string]Light, error) {
+	lights, err := get[map[string]Light](ac, "/lights")
+	if err != nil {
+		return nil, err
+	}
+
+	// Lights can be requested by their identifier or their unique ID, so cache both
+	for id, light := range *lights {
+		ac.lights.set(id, &light)
+		if len(light.UniqueID) > 0 {
+			ac.lights.set(light.UniqueID, &light)
+		}
+	}
+
+	return *lights, nil
+}
+
+// GetCurrentLight retrieves a light with its current state from the deCONZ gateway.
+// The states of all lights are fetched with a single request and reused for LightStateTTL,
+// so that reading the state of many lights at once only causes a single API request.
+//
+// Parameters:
+//   - id: The identifier or unique ID of the light to retrieve
+//
+// Returns:
+//   - *Light: A pointer to the retrieved Light structure
+//   - error: Any error encountered during the API request, or if the light doesn't exist
+func (ac *ApiClient) GetCurrentLight(id string) (*Light, error) {
+	ac.refreshMu.Lock()
+	defer ac.refreshMu.Unlock()
+
+	if light, ok := ac.lights.getFresh(id, LightStateTTL); ok {
+		return light, nil
+	}
+
+	if _, err := ac.GetAllLights(); err != nil {
+		return nil, err
+	}
+
+	if light, ok := ac.lights.get(id); ok {
+		return light, nil
+	}
+	return nil, fmt.Errorf("light %s not found", id)
+}
+
+// InvalidateLight removes a light from the cache, so that the next call to
+// GetLight fetches its details from the gateway again.
+//
+// Parameters:
+//   - id: The identifier of the light
+func (ac *ApiClient) InvalidateLight(id string) {
+	ac.lights.invalidate(id)
+}
+
+// GetLightPowerup retrieves the powerup setting of a light from the deCONZ gateway.
+// The setting is always requested from the gateway, because it may have been changed in Phoscon.
+//
+// Parameters:
+//   - id: The identifier of the light
+//
+// Returns:
+//   - int: The powerup bitmap (see PowerUpOn, PowerUpBrightness and PowerUpColor)
+//   - error: Any error encountered during the API request, or if the light has no powerup setting
+func (ac *ApiClient) GetLightPowerup(id string) (int, error) {
+	light, err := get[Light](ac, "/lights/"+id)
+	if err != nil {
+		return 0, err
+	}
+	if light.PowerUp == nil {
+		return 0, fmt.Errorf("light %s has no powerup setting", id)
+	}
+	return *light.PowerUp, nil
+}
+
+// SetLightPowerup changes the powerup setting of a light, which controls the state of
+// the light after a power cut.
+//
+// Parameters:
+//   - id: The identifier of the light
+//   - powerup: The powerup bitmap (see PowerUpOn, PowerUpBrightness and PowerUpColor)
+//
+// Returns:
+//   - error: Any error encountered during the API request, or if the gateway refused the value
+func (ac *ApiClient) SetLightPowerup(id string, powerup int) error {
+	if err := ac.waitForCommand(); err != nil {
+		return err
+	}
+	address := "/lights/" + id + "/config"
+	response, err := put[Response](ac, address, map[string]int{"powerup": powerup})
+	if err != nil {
+		return countCommand("powerup", err)
+	}
+
+	// The cached details of the light contain the old setting
+	ac.lights.invalidate(id)
+	return countCommand("powerup", response.confirm(address, []string{"powerup"}))
 }
 
 // SetLightState updates the state of a light with the provided settings.
@@ -112,10 +260,19 @@ func (ac *ApiClient) GetLight(id string) (*Light, error) {
 //   - state: A pointer to a LightState structure containing the desired state changes
 //
 // Returns:
-//   - error: Any error encountered during the API request
+//   - error: Any error encountered during the API request, or if the gateway refused a value
 func (ac *ApiClient) SetLightState(id string, state *LightState) error {
-	_, err := client.Put[any](ac.buildUrl("/lights/"+id+"/state"), *state)
-	return err
+	if err := ac.waitForCommand(); err != nil {
+		return err
+	}
+	address := "/lights/" + id + "/state"
+	response, err := put[Response](ac, address, *state)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the gateway accepted every value of the state
+	return response.confirm(address, slices.Collect(maps.Keys(state.ObjectMap())))
 }
 
 // SetLightOn turns a light on or off.
@@ -127,35 +284,25 @@ func (ac *ApiClient) SetLightState(id string, state *LightState) error {
 // Returns:
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) SetLightOn(id string, on bool) error {
-	return ac.SetLightState(id, &LightState{
+	return countCommand("on", ac.SetLightState(id, &LightState{
 		On: &on,
-	})
+	}))
 }
 
 // SetLightBrightness sets the brightness of a light.
 // If brightness is 0, the light will be turned off.
-// If brightness is greater than 0, the light will be turned on and set to the specified brightness.
+// If brightness is greater than 0, only the brightness is set. HomeKit turns the light on
+// with a separate command, so the brightness command doesn't affect the color of the light.
 //
 // Parameters:
 //   - id: The identifier of the light to control
 //   - brightness: The desired brightness level as a percentage (0-100)
+//   - minimum: The raw brightness 1% is mapped to (see helper.PercentToBrightness)
 //
 // Returns:
 //   - error: Any error encountered during the API request
-func (ac *ApiClient) SetLightBrightness(id string, brightness int) error {
-	state := new(LightState)
-	f := false
-	state.On = &f
-
-	// convert percentage to value
-	value := uint8(math.Round(float64(brightness * 255.0 / 100.0)))
-	if value > 0 {
-		t := true
-		state.On = &t
-		state.Brightness = &value
-	}
-
-	return ac.SetLightState(id, state)
+func (ac *ApiClient) SetLightBrightness(id string, brightness int, minimum int) error {
+	return countCommand("bri", ac.SetLightState(id, newBrightnessState(brightness, minimum)))
 }
 
 // SetLightColorTemperature sets the color temperature of a light.
@@ -169,7 +316,97 @@ func (ac *ApiClient) SetLightBrightness(id string, brightness int) error {
 // Returns:
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) SetLightColorTemperature(id string, mired int) error {
-	return ac.SetLightState(id, &LightState{
+	return countCommand("ct", ac.SetLightState(id, &LightState{
 		ColorTemperature: &mired,
-	})
+	}))
+}
+
+// SetLightHueSaturation sets the color of a light as hue and saturation.
+// Both values are always sent together, so that deCONZ switches the light to the
+// "hs" color mode with exactly this color, instead of combining one of them with
+// the stale value of another color mode.
+//
+// Parameters:
+//   - id: The identifier of the light to control
+//   - hue: The desired hue in degrees (0-360)
+//   - saturation: The desired saturation as a percentage (0-100)
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetLightHueSaturation(id string, hue float64, saturation float64) error {
+	// convert degrees and percentage to values
+	hueValue := uint16(math.Round(hue * 65535.0 / 360.0))
+	saturationValue := uint8(math.Round(saturation * 255.0 / 100.0))
+	return countCommand("hs", ac.SetLightState(id, &LightState{
+		Hue:        &hueValue,
+		Saturation: &saturationValue,
+	}))
+}
+
+// SetLightXY sets the color of a light as coordinates in the CIE xy color space.
+// This is used for lights in the "xy" color mode, so that they stay in their color mode
+// (some lights only support xy colors).
+//
+// Parameters:
+//   - id: The identifier of the light to control
+//   - x: The x coordinate of the color (0-1)
+//   - y: The y coordinate of the color (0-1)
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetLightXY(id string, x float64, y float64) error {
+	// deCONZ accepts four decimal places
+	xy := [2]float64{math.Round(x*10000) / 10000, math.Round(y*10000) / 10000}
+	return countCommand("xy", ac.SetLightState(id, &LightState{
+		XY: &xy,
+	}))
+}
+
+// Effects which can be set with SetLightEffect.
+const (
+	// EffectNone stops a running effect
+	EffectNone = "none"
+
+	// EffectColorLoop cycles the light through all colors
+	EffectColorLoop = "colorloop"
+)
+
+// SetLightEffect starts or stops an effect on a light.
+//
+// Parameters:
+//   - id: The identifier of the light to control
+//   - effect: The effect to set (EffectNone or EffectColorLoop)
+//
+// Returns:
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) SetLightEffect(id string, effect string) error {
+	return countCommand("effect", ac.SetLightState(id, &LightState{
+		Effect: &effect,
+	}))
+}
+
+// newBrightnessState builds a LightState for a brightness percentage.
+// A brightness of 0 turns the light off, any other value only contains the converted
+// brightness (e.g. {"bri": 127}). No other fields are included, so that changing the
+// brightness never changes the color or color mode of the light.
+//
+// Parameters:
+//   - brightness: The desired brightness level as a percentage (0-100)
+//   - minimum: The raw brightness 1% is mapped to (see helper.PercentToBrightness)
+//
+// Returns:
+//   - *LightState: A pointer to the LightState containing the on and brightness values
+func newBrightnessState(brightness int, minimum int) *LightState {
+	state := new(LightState)
+
+	// convert percentage to value
+	value := uint8(helper.PercentToBrightness(brightness, minimum))
+	if value > 0 {
+		state.Brightness = &value
+	} else {
+		f := false
+		state.On = &f
+	}
+
+	return state
 }
@@ -0,0 +1,61 @@
+package deconz
+
+import (
+	"context"
+	"deconz-homekit/internal/deconz/deconztest"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBrightnessStateSerialization(t *testing.T) {
+	tests := []struct {
+		name       string
+		brightness int
+		minimum    int
+		want       string
+	}{
+		{"lowest", 1, 1, `{"bri":1}`},
+		{"half", 50, 1, `{"bri":126}`},
+		{"full", 100, 1, `{"bri":254}`},
+		{"lowest with a minimum", 1, 30, `{"bri":30}`},
+		{"off", 0, 1, `{"on":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(newBrightnessState(tt.brightness, tt.minimum))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("newBrightnessState(%d, %d) = %s, want %s", tt.brightness, tt.minimum, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLightBrightnessSendsOnlyBrightness(t *testing.T) {
+	gateway := deconztest.NewGateway(t)
+	gateway.AddLight(t, "5", `{
+		"type": "Extended color light",
+		"uniqueid": "00:17:88:01:02:03:04:05-0b",
+		"state": {"on": true, "bri": 254, "colormode": "xy", "xy": [0.64, 0.33], "hue": 0, "sat": 254}
+	}`)
+	client := NewApiClient(context.Background(), gateway.URL, deconztest.APIKey)
+	client.SetLogger(testLogger{})
+
+	if err := client.SetLightBrightness("5", 50, 1); err != nil {
+		t.Fatalf("SetLightBrightness() error = %v", err)
+	}
+
+	// No color fields, so the color of the light is kept
+	requests := gateway.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(requests))
+	}
+	want := map[string]any{"bri": 126.0}
+	if requests[0].Path != "/lights/5/state" || !reflect.DeepEqual(requests[0].Body, want) {
+		t.Errorf("sent %s %v, want /lights/5/state %v", requests[0].Path, requests[0].Body, want)
+	}
+}
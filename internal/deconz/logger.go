@@ -0,0 +1,49 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import "log"
+
+// Logger is the interface of the logger used by the deconz package.
+// It is satisfied by the logger of github.com/charmbracelet/log, so the
+// application can inject its shared logger.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// stdLogger is the default Logger, which writes to the standard library logger.
+type stdLogger struct {
+	// prefix is prepended to every message
+	prefix string
+}
+
+// newStdLogger creates a Logger which writes to the standard library logger.
+//
+// Parameters:
+//   - prefix: The prefix prepended to every message (e.g. "[Events] ")
+//
+// Returns:
+//   - Logger: The created logger
+func newStdLogger(prefix string) Logger {
+	return &stdLogger{prefix: prefix}
+}
+
+// Debugf discards debug messages, they are only shown by an injected logger.
+func (l *stdLogger) Debugf(string, ...interface{}) {}
+
+// Infof logs an informational message.
+func (l *stdLogger) Infof(format string, args ...interface{}) {
+	log.Printf(l.prefix+format, args...)
+}
+
+// Warnf logs a warning.
+func (l *stdLogger) Warnf(format string, args ...interface{}) {
+	log.Printf(l.prefix+format, args...)
+}
+
+// Errorf logs an error.
+func (l *stdLogger) Errorf(format string, args ...interface{}) {
+	log.Printf(l.prefix+format, args...)
+}
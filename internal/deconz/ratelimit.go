@@ -0,0 +1,83 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit is the default number of commands per second sent to the gateway.
+const DefaultRateLimit = 10
+
+// rateLimiter is a token bucket limiting the number of commands sent to the gateway.
+// Zigbee networks can't relay commands arbitrarily fast, so commands exceeding the
+// rate are delayed (not dropped) until a token is available. It is safe for concurrent use.
+type rateLimiter struct {
+	mu sync.Mutex
+
+	// rate is the number of tokens added per second
+	rate float64
+
+	// burst is the maximum number of tokens in the bucket
+	burst float64
+
+	// tokens is the number of tokens currently available
+	tokens float64
+
+	// last is the time the tokens were last refilled
+	last time.Time
+}
+
+// newRateLimiter creates a new rate limiter with a full bucket.
+// The bucket holds the commands of one second, so short bursts (e.g. a scene) are sent immediately.
+//
+// Parameters:
+//   - rate: The number of commands per second
+//
+// Returns:
+//   - *rateLimiter: A pointer to the initialized rate limiter
+func newRateLimiter(rate float64) *rateLimiter {
+	burst := math.Max(rate, 1)
+	return &rateLimiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a command may be sent.
+//
+// Parameters:
+//   - ctx: The context, waiting is cancelled when it is done
+//
+// Returns:
+//   - error: The error of the context if it is done before a command may be sent
+func (rl *rateLimiter) wait(ctx context.Context) error {
+	for {
+		rl.mu.Lock()
+
+		// Refill the bucket based on the elapsed time
+		now := time.Now()
+		rl.tokens = math.Min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.rate)
+		rl.last = now
+
+		// Take a token if one is available
+		if rl.tokens >= 1 {
+			rl.tokens--
+			rl.mu.Unlock()
+			return nil
+		}
+
+		// Otherwise wait until the next token is available
+		delay := time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+		rl.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
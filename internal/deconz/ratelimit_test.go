@@ -0,0 +1,51 @@
+package deconz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	rl := newRateLimiter(5)
+
+	// The commands of one second are sent immediately
+	start := time.Now()
+	for range 5 {
+		if err := rl.wait(context.Background()); err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("the burst took %v, want no delay", elapsed)
+	}
+
+	// The next command waits for a token
+	start = time.Now()
+	if err := rl.wait(context.Background()); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("the command after the burst took %v, want about 200ms", elapsed)
+	}
+}
+
+func TestRateLimiterWaitIsCancelled(t *testing.T) {
+	rl := newRateLimiter(0.1)
+	if err := rl.wait(context.Background()); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	// The next token is only available after 10 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := rl.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("wait() returned after %v, want right after the cancellation", elapsed)
+	}
+}
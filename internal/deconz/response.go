@@ -0,0 +1,69 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Response is the response of the deCONZ REST API to a write request.
+// It contains an entry for each changed value, which is either a success
+// (e.g. {"success": {"/lights/5/state/on": true}}) or an error.
+type Response []struct {
+	// Success maps the addresses of the changed values to their new values
+	Success map[string]any `json:"success,omitempty"`
+
+	// Error describes why a value could not be changed
+	Error *ResponseError `json:"error,omitempty"`
+}
+
+// ResponseError is an error reported by the deCONZ REST API.
+type ResponseError struct {
+	// Type is the numeric error type (e.g. 3 if the resource is not available)
+	Type int `json:"type"`
+
+	// Address is the address of the resource or value the error refers to
+	Address string `json:"address"`
+
+	// Description is a human-readable description of the error
+	Description string `json:"description"`
+}
+
+// Error returns the description of the error.
+// This method implements the error interface.
+//
+// Returns:
+//   - string: The description including the error type and address
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("deCONZ error %d at %s: %s", e.Type, e.Address, e.Description)
+}
+
+// confirm verifies that the gateway accepted all values of a write request.
+// The gateway reports errors and unchanged values only in the response body, so without
+// this check a refused command (e.g. to an unreachable light) would look successful.
+//
+// Parameters:
+//   - address: The address the request was sent to (e.g. "/lights/5/state")
+//   - keys: The keys of the values which were sent
+//
+// Returns:
+//   - error: The first error reported by the gateway, or an error if a value wasn't confirmed
+func (r Response) confirm(address string, keys []string) error {
+	confirmed := []string{}
+	for _, entry := range r {
+		if entry.Error != nil {
+			return entry.Error
+		}
+		for key := range entry.Success {
+			confirmed = append(confirmed, key)
+		}
+	}
+
+	for _, key := range keys {
+		if !slices.Contains(confirmed, address+"/"+key) {
+			return fmt.Errorf("deCONZ did not confirm %s/%s", address, key)
+		}
+	}
+
+	return nil
+}
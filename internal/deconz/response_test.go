@@ -0,0 +1,89 @@
+package deconz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetLightStateConfirmsResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+		wantType int
+	}{
+		{
+			name:     "success",
+			response: `[{"success":{"/lights/5/state/on":true}}]`,
+		},
+		{
+			name:     "error",
+			response: `[{"error":{"type":201,"address":"/lights/5/state/on","description":"parameter, on, is not modifiable. Device is set to off."}}]`,
+			wantErr:  true,
+			wantType: 201,
+		},
+		{
+			name:     "success for another value",
+			response: `[{"success":{"/lights/5/state/bri":254}}]`,
+			wantErr:  true,
+		},
+		{
+			name:     "success for another light",
+			response: `[{"success":{"/lights/6/state/on":true}}]`,
+			wantErr:  true,
+		},
+		{
+			name:     "empty",
+			response: `[]`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.URL.Path != "/api/key/lights/5/state" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			t.Cleanup(server.Close)
+
+			client := NewApiClient(context.Background(), server.URL, "key")
+			client.SetLogger(testLogger{})
+
+			err := client.SetLightOn("5", true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetLightOn() error = %v, want an error: %t", err, tt.wantErr)
+			}
+
+			// Errors of the gateway are passed on, so callers can tell why a command failed
+			var responseErr *ResponseError
+			if errors.As(err, &responseErr) != (tt.wantType != 0) {
+				t.Fatalf("SetLightOn() error = %#v, want a ResponseError: %t", err, tt.wantType != 0)
+			}
+			if responseErr != nil && responseErr.Type != tt.wantType {
+				t.Errorf("error type = %d, want %d", responseErr.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestResponseConfirmAllKeys(t *testing.T) {
+	response := Response{
+		{Success: map[string]any{"/lights/5/state/hue": 1000.0}},
+		{Success: map[string]any{"/lights/5/state/sat": 254.0}},
+	}
+
+	if err := response.confirm("/lights/5/state", []string{"hue", "sat"}); err != nil {
+		t.Errorf("confirm(hue, sat) error = %v", err)
+	}
+	if err := response.confirm("/lights/5/state", []string{"hue", "sat", "on"}); err == nil {
+		t.Error("confirm(hue, sat, on) error = nil, want an error for the unconfirmed value")
+	}
+}
@@ -1,7 +1,13 @@
 // Package deconz provides interfaces and types for interacting with the deCONZ REST API.
 package deconz
 
-import "deconz-homekit/internal/client"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
 
 // Sensor represents a sensor device in the deCONZ ecosystem.
 // This struct contains all the properties and state information for a sensor,
@@ -14,7 +20,7 @@ type Sensor struct {
 	Config ObjectMap `json:"config"`
 
 	// Endpoint is the Zigbee endpoint number for this sensor
-	Endpoint int `json:"endpoint"`
+	Endpoint int `json:"ep"`
 
 	// ETag is used for caching and resource versioning
 	ETag string `json:"etag"`
@@ -54,5 +60,94 @@ type Sensor struct {
 //   - *Sensor: A pointer to the retrieved Sensor structure
 //   - error: Any error encountered during the API request
 func (ac *ApiClient) GetSensor(id string) (*Sensor, error) {
-	return client.Get[Sensor](ac.buildUrl("/sensors/" + id))
+	return get[Sensor](ac, "/sensors/"+id)
+}
+
+// ListSensors retrieves a list of all sensor identifiers from the deCONZ gateway.
+//
+// Returns:
+//   - []string: A sorted slice of sensor identifiers
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) ListSensors() ([]string, error) {
+	sensors, err := ac.GetAllSensors()
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Sorted(maps.Keys(sensors)), nil
+}
+
+// GetAllSensors retrieves detailed information about all sensors from the deCONZ gateway
+// with a single API request.
+//
+// Returns:
+//   - map[string]Sensor: A map of sensor identifiers to Sensor structures
+//   - error: Any error encountered during the API request
+func (ac *ApiClient) GetAllSensors() (map[string]Sensor, error) {
+	sensors, err := get[map[string]Sensor](ac, "/sensors")
+	if err != nil {
+		return nil, err
+	}
+
+	return *sensors, nil
+}
+
+// GetCurrentSensor returns the current state of a sensor by its unique ID.
+// All sensors are fetched with a single request, which is reused for SensorStateTTL,
+// so that a controller reading many sensors at once doesn't cause a request per sensor.
+//
+// Parameters:
+//   - uniqueId: The unique identifier of the sensor
+//
+// Returns:
+//   - *Sensor: A pointer to the current Sensor
+//   - error: An error if the sensors could not be fetched or the sensor doesn't exist
+func (ac *ApiClient) GetCurrentSensor(uniqueId string) (*Sensor, error) {
+	ac.refreshMu.Lock()
+	defer ac.refreshMu.Unlock()
+
+	if ac.sensors == nil || time.Since(ac.sensorsFetched) > SensorStateTTL {
+		sensors, err := ac.GetAllSensors()
+		if err != nil {
+			return nil, err
+		}
+
+		ac.sensors = make(map[string]*Sensor, len(sensors))
+		for _, sensor := range sensors {
+			ac.sensors[sensor.UniqueId] = &sensor
+		}
+		ac.sensorsFetched = time.Now()
+	}
+
+	if sensor, ok := ac.sensors[uniqueId]; ok {
+		return sensor, nil
+	}
+	return nil, fmt.Errorf("sensor %s not found", uniqueId)
+}
+
+// timestampLayouts are the layouts of the timestamps reported by deCONZ.
+// "lastseen" only has a precision of minutes, while "lastupdated" has milliseconds.
+var timestampLayouts = []string{
+	"2006-01-02T15:04Z",
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05.000",
+	time.RFC3339,
+}
+
+// ParseTimestamp parses a timestamp reported by deCONZ (e.g. "lastseen").
+// The timestamps are in UTC.
+//
+// Parameters:
+//   - value: The timestamp to parse
+//
+// Returns:
+//   - time.Time: The parsed time
+//   - error: An error if the timestamp doesn't match any known layout
+func ParseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid timestamp: " + value)
 }
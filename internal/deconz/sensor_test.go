@@ -0,0 +1,106 @@
+package deconz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fixtureServer serves recorded responses of the gateway from the testdata directory.
+type fixtureServer struct {
+	*httptest.Server
+
+	// requests is the number of requests served so far
+	requests atomic.Int32
+}
+
+// newFixtureClient starts a server which serves the given fixtures by their path below the
+// API key (e.g. "/sensors" to "sensors.json") and returns a client connected to it.
+func newFixtureClient(t *testing.T, fixtures map[string]string) (*ApiClient, *fixtureServer) {
+	t.Helper()
+
+	server := new(fixtureServer)
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests.Add(1)
+		fixture, ok := fixtures[strings.TrimPrefix(r.URL.Path, "/api/key")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join("testdata", fixture))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := NewApiClient(ctx, server.URL, "key")
+	client.SetLogger(testLogger{})
+	return client, server
+}
+
+func TestGetAllSensors(t *testing.T) {
+	client, _ := newFixtureClient(t, map[string]string{"/sensors": "sensors.json"})
+
+	sensors, err := client.GetAllSensors()
+	if err != nil {
+		t.Fatalf("GetAllSensors() error = %v", err)
+	}
+	if len(sensors) != 5 {
+		t.Fatalf("GetAllSensors() returned %d sensors, want 5", len(sensors))
+	}
+
+	temperature := sensors["2"]
+	if temperature.Type != "ZHATemperature" || temperature.UniqueId != "00:15:8d:00:04:5c:12:34-01-0402" {
+		t.Errorf("sensor 2 = %s %s, want the temperature sensor", temperature.Type, temperature.UniqueId)
+	}
+	if got := temperature.State.ValueToInt("temperature"); got != 2154 {
+		t.Errorf("temperature = %d, want 2154", got)
+	}
+	if got := temperature.Config.ValueToInt("battery"); got != 87 {
+		t.Errorf("battery = %d, want 87", got)
+	}
+	if temperature.Endpoint != 1 || temperature.LastSeen != "2024-05-01T10:02Z" {
+		t.Errorf("endpoint and last seen = %d %q, want 1 %q", temperature.Endpoint, temperature.LastSeen, "2024-05-01T10:02Z")
+	}
+
+	// Sensors without an endpoint or last seen time (e.g. the daylight sensor) are decoded as well
+	if daylight := sensors["1"]; daylight.Endpoint != 0 || len(daylight.LastSeen) > 0 || !daylight.State.ValueToBool("daylight") {
+		t.Errorf("daylight sensor = %+v", daylight)
+	}
+}
+
+func TestListSensors(t *testing.T) {
+	client, _ := newFixtureClient(t, map[string]string{"/sensors": "sensors.json"})
+
+	ids, err := client.ListSensors()
+	if err != nil {
+		t.Fatalf("ListSensors() error = %v", err)
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !slices.Equal(ids, want) {
+		t.Errorf("ListSensors() = %v, want %v", ids, want)
+	}
+}
+
+func TestGetCurrentSensorSharesRequests(t *testing.T) {
+	client, server := newFixtureClient(t, map[string]string{"/sensors": "sensors.json"})
+
+	for _, uniqueId := range []string{"00:15:8d:00:02:1a:56:78-01-0006", "00:17:88:01:02:0b:9a:bc-02-0406"} {
+		if _, err := client.GetCurrentSensor(uniqueId); err != nil {
+			t.Fatalf("GetCurrentSensor(%s) error = %v", uniqueId, err)
+		}
+	}
+	if _, err := client.GetCurrentSensor("00:00:00:00:00:00:00:00-01"); err == nil {
+		t.Error("GetCurrentSensor() of a missing sensor succeeded")
+	}
+
+	// All sensors are fetched once and reused within SensorStateTTL
+	if got := server.requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Package deconz provides interfaces and types for interacting with the deCONZ REST API.
+package deconz
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewTLSDialer creates a WebSocket dialer for wss:// connections to a gateway with a
+// self-signed certificate. The certificate is either verified against a custom CA or,
+// if insecure is set, not verified at all. Without a CA file the system roots are used.
+//
+// Parameters:
+//   - caFile: The path of a PEM file with the CA certificates to trust (empty to use the system roots)
+//   - insecure: Whether the certificate of the gateway is accepted without verification
+//
+// Returns:
+//   - *websocket.Dialer: A dialer based on the default dialer with the TLS configuration
+//   - error: An error if the CA file could not be read or contains no certificates
+func NewTLSDialer(caFile string, insecure bool) (*websocket.Dialer, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+
+	if len(caFile) > 0 {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("the CA file contains no PEM certificates")
+		}
+	}
+
+	// Copy the default dialer, so that the proxy and handshake settings stay the same
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = config
+	return &dialer, nil
+}
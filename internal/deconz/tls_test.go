@@ -0,0 +1,98 @@
+package deconz
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTLSEventServer starts a WebSocket server with a self-signed certificate, which sends an event
+// after a client connected. The certificate is written to a PEM file for NewTLSDialer.
+func newTLSEventServer(t *testing.T) (url string, caFile string) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"t":"event","e":"changed","r":"lights","id":"5","state":{"on":true}}`))
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return "wss" + strings.TrimPrefix(server.URL, "https"), caFile
+}
+
+func TestEventClientWithTLSDialer(t *testing.T) {
+	url, caFile := newTLSEventServer(t)
+
+	tests := []struct {
+		name     string
+		caFile   string
+		insecure bool
+	}{
+		{"custom CA", caFile, false},
+		{"insecure", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer, err := NewTLSDialer(tt.caFile, tt.insecure)
+			if err != nil {
+				t.Fatalf("NewTLSDialer() error = %v", err)
+			}
+			events := newCollector()
+
+			ec, err := NewEventClient(context.Background(), url, dialer, events.add, 0, testLogger{})
+			if err != nil {
+				t.Fatalf("NewEventClient() error = %v", err)
+			}
+			t.Cleanup(func() { _ = ec.Stop() })
+
+			if got := *events.wait(t, 1)[0].RessourceID; got != "5" {
+				t.Errorf("event for %s, want 5", got)
+			}
+		})
+	}
+}
+
+func TestEventClientRejectsUntrustedCertificate(t *testing.T) {
+	url, _ := newTLSEventServer(t)
+
+	// The default dialer only trusts the system roots
+	ec, err := NewEventClient(context.Background(), url, nil, func(*Messsage) {}, 0, testLogger{})
+	if err == nil {
+		_ = ec.Stop()
+		t.Fatal("NewEventClient() error = nil, want an error for the self-signed certificate")
+	}
+}
+
+func TestNewTLSDialerInvalidCAFile(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("no certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	for _, caFile := range []string{filepath.Join(t.TempDir(), "missing.pem"), invalid} {
+		if _, err := NewTLSDialer(caFile, false); err == nil {
+			t.Errorf("NewTLSDialer(%q) error = nil, want an error", caFile)
+		}
+	}
+}
@@ -2,10 +2,15 @@
 package deconz
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/websocket"
-	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // RessourceType represents the type of resource in the deCONZ ecosystem.
@@ -74,12 +79,18 @@ type Messsage struct {
 	// Config contains configuration changes (only for changed events)
 	Config *ObjectMap `json:"config,omitempty"`
 
+	// Attr contains attribute changes like the model or firmware version (only for changed events)
+	Attr *ObjectMap `json:"attr,omitempty"`
+
 	// Name contains the name change (only for changed events)
 	Name *string `json:"name,omitempty"`
 
 	// State contains state changes (only for changed events)
 	State *ObjectMap `json:"state,omitempty"`
 
+	// Action contains the last action sent to a group (only for changed group events)
+	Action *ObjectMap `json:"action,omitempty"`
+
 	// Group contains group information (only for added events)
 	Group *interface{} `json:"group,omitempty"`
 
@@ -90,72 +101,406 @@ type Messsage struct {
 	Sensor *interface{} `json:"sensor,omitempty"`
 }
 
+// DefaultEventBufferSize is the default number of events which are buffered
+// while the previous events are still being processed.
+const DefaultEventBufferSize = 256
+
+// Default settings of the watchdog of the event stream.
+const (
+	// DefaultWatchdogTimeout is the default time without any frame after which the connection is considered stale
+	DefaultWatchdogTimeout = 2 * time.Minute
+
+	// DefaultWatchdogRetries is the default number of reconnects before the watchdog gives up
+	DefaultWatchdogRetries = 3
+)
+
+// pingTimeout is the maximum time to wait for sending a ping to the gateway
+const pingTimeout = 5 * time.Second
+
+// eventConn is a single WebSocket connection of the EventClient.
+type eventConn struct {
+	// conn is the WebSocket connection to the deCONZ gateway
+	conn *websocket.Conn
+
+	// done is closed when the goroutine reading the connection has stopped
+	done chan struct{}
+
+	// closing is set when the connection is closed intentionally (by Stop or a reconnect)
+	closing atomic.Bool
+}
+
+// close closes the connection and waits until the goroutine reading it has stopped.
+//
+// Returns:
+//   - error: Any error encountered while closing the connection
+func (c *eventConn) close() error {
+	c.closing.Store(true)
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
 // EventClient manages a WebSocket connection to the deCONZ gateway.
 // It receives real-time events about changes in the Zigbee network.
 type EventClient struct {
-	// client is the WebSocket connection to the deCONZ gateway
-	client *websocket.Conn
+	// ctx is the context for establishing connections
+	ctx context.Context
 
-	// done is a channel used to signal when the client should stop
-	done chan struct{}
+	// path is the WebSocket URL of the deCONZ gateway
+	path string
+
+	// dialer establishes the connections, including reconnects
+	dialer *websocket.Dialer
+
+	// mu guards the current connection and serializes reconnects with Stop
+	mu sync.Mutex
+
+	// current is the active WebSocket connection
+	current *eventConn
+
+	// processed is closed when the goroutine processing the events has stopped
+	processed chan struct{}
+
+	// events buffers the received events until they are processed
+	events chan *Messsage
+
+	// quit is closed to signal that the client is stopping
+	quit chan struct{}
+
+	// stopOnce ensures that the client is only stopped once
+	stopOnce sync.Once
+
+	// lastEvent is the time the last event was received (in Unix nanoseconds)
+	lastEvent atomic.Int64
+
+	// lastFrame is the time the last frame of any kind was received, including pongs (in Unix nanoseconds)
+	lastFrame atomic.Int64
+
+	// log is the logger for connection and message errors
+	log Logger
 }
 
 // NewEventClient creates a new WebSocket connection to the deCONZ gateway.
-// It starts a goroutine that listens for events and processes them using the provided function.
+// It starts a goroutine that listens for events and a goroutine that processes them using the
+// provided function. The events are buffered in between, so that the WebSocket is read continuously
+// even if processing is slow (e.g. during a burst of events caused by a scene). If the buffer is full,
+// the oldest event is dropped.
 //
 // Parameters:
 //   - ctx: Context for controlling the connection lifecycle
 //   - path: The WebSocket URL to connect to
+//   - dialer: The dialer for the connections, e.g. from NewTLSDialer for wss:// (nil to use the default dialer)
 //   - eventFn: A function that will be called for each event received
+//   - bufferSize: The number of events to buffer (0 or less to use DefaultEventBufferSize)
+//   - logger: The logger for connection errors (nil to use the standard library logger)
 //
 // Returns:
 //   - *EventClient: A pointer to the created EventClient
 //   - error: Any error encountered during connection setup
-func NewEventClient(ctx context.Context, path string, eventFn func(msg *Messsage)) (*EventClient, error) {
-	ec := new(EventClient)
+func NewEventClient(ctx context.Context, path string, dialer *websocket.Dialer, eventFn func(msg *Messsage), bufferSize int, logger Logger) (*EventClient, error) {
+	ec := &EventClient{ctx: ctx, path: path, dialer: dialer}
+	if ec.dialer == nil {
+		ec.dialer = websocket.DefaultDialer
+	}
+	ec.log = logger
+	if ec.log == nil {
+		ec.log = newStdLogger("[Events] ")
+	}
 
 	// Establish the WebSocket connection
-	c, _, err := websocket.DefaultDialer.DialContext(ctx, path, nil)
+	c, err := ec.connect()
 	if err != nil {
-		log.Printf("[Events] websocket connection error: %+v", err)
+		ec.log.Errorf("websocket connection error: %+v", err)
 		return nil, err
 	}
-	ec.client = c
+	ec.current = c
 
-	// Create a channel for signaling when to stop
-	ec.done = make(chan struct{})
+	// Create the channels for signaling when to stop
+	ec.processed = make(chan struct{})
+	ec.quit = make(chan struct{})
 
-	// Start a goroutine to listen for events
+	// Create the buffer for the received events
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+	ec.events = make(chan *Messsage, bufferSize)
+
+	// Start a goroutine to process the buffered events
+	// It stops after the remaining events have been processed
 	go func() {
-		defer close(ec.done)
-		for {
-			// Read the next message from the WebSocket
-			_, message, err := c.ReadMessage()
-			if err != nil {
-				log.Printf("[Events] websocket read error: %+v", err)
-				continue
+		defer close(ec.processed)
+		for eventMsg := range ec.events {
+			eventFn(eventMsg)
+		}
+	}()
+
+	// Start a goroutine to listen for events
+	go ec.read(c)
+
+	return ec, nil
+}
+
+// connect establishes a new WebSocket connection to the deCONZ gateway.
+// Every received frame, including pongs and pings, updates the time of the last frame.
+//
+// Returns:
+//   - *eventConn: A pointer to the new connection, which isn't read yet
+//   - error: Any error encountered while connecting
+func (ec *EventClient) connect() (*eventConn, error) {
+	conn, _, err := ec.dialer.DialContext(ec.ctx, ec.path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record pongs as well as pings, the default ping handler still has to answer pings
+	pingHandler := conn.PingHandler()
+	conn.SetPingHandler(func(data string) error {
+		ec.lastFrame.Store(time.Now().UnixNano())
+		return pingHandler(data)
+	})
+	conn.SetPongHandler(func(string) error {
+		ec.lastFrame.Store(time.Now().UnixNano())
+		return nil
+	})
+
+	return &eventConn{conn: conn, done: make(chan struct{})}, nil
+}
+
+// read reads the frames of a connection and passes the events to the processing goroutine.
+// It returns when the connection fails or is closed.
+//
+// Parameters:
+//   - c: A pointer to the connection to read
+func (ec *EventClient) read(c *eventConn) {
+	defer close(c.done)
+	for {
+		// Read the next message from the WebSocket
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			// The connection was closed by Stop or a reconnect
+			if c.closing.Load() {
+				return
 			}
 
-			// Parse the message into a Messsage struct
-			eventMsg := new(Messsage)
-			if err := json.Unmarshal(message, eventMsg); err != nil {
-				log.Printf("[Events] message unmarshal error: %+v", err)
-				continue
+			// A failed connection can't be read from again
+			ec.log.Errorf("websocket read error: %+v", err)
+			return
+		}
+		ec.lastFrame.Store(time.Now().UnixNano())
+
+		// Parse the message into a Messsage struct, skipping frames which are not events
+		eventMsg := ec.decode(message)
+		if eventMsg == nil {
+			continue
+		}
+
+		// Pass the event to the processing goroutine
+		ec.lastEvent.Store(time.Now().UnixNano())
+		ec.enqueue(eventMsg)
+	}
+}
+
+// Reconnect replaces the current WebSocket connection with a new one.
+// The new connection is established before the current one is closed, so the
+// current connection is kept if the gateway can't be reached.
+//
+// Returns:
+//   - error: Any error encountered while connecting, or an error if the client is stopped
+func (ec *EventClient) Reconnect() error {
+	// Connect without holding the lock, so that Connected doesn't block while dialing
+	c, err := ec.connect()
+	if err != nil {
+		return err
+	}
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	// Discard the new connection if the client was stopped in the meantime
+	select {
+	case <-ec.quit:
+		_ = c.conn.Close()
+		return errors.New("event client is stopped")
+	default:
+	}
+
+	// Close the old connection before reading the new one, so the events stay in order
+	old := ec.current
+	ec.current = c
+	_ = old.close()
+	go ec.read(c)
+
+	return nil
+}
+
+// Watch monitors the connection until the context is cancelled.
+// The gateway is pinged regularly, so that even a quiet connection receives frames. If the
+// connection failed or no frame was received within the timeout, the connection is considered
+// stale and is re-established. If the connection is still stale after the given number of
+// reconnects, Watch gives up and returns an error, so that the bridge can be restarted.
+//
+// Parameters:
+//   - ctx: Context for stopping the watchdog
+//   - timeout: The maximum time without any frame from the gateway
+//   - retries: The number of consecutive reconnects before giving up
+//
+// Returns:
+//   - error: An error if the connection couldn't be restored, or nil if the context was cancelled
+func (ec *EventClient) Watch(ctx context.Context, timeout time.Duration, retries int) error {
+	// Ping several times within the timeout, so that a single lost pong isn't fatal
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	failures := 0
+	reconnected := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ec.quit:
+			return nil
+		case <-ticker.C:
+		}
+
+		// The connection is healthy as long as frames arrive, the reconnect counts as
+		// activity until the new connection had the chance to receive a frame
+		lastFrame := time.Unix(0, ec.lastFrame.Load())
+		lastActivity := lastFrame
+		if reconnected.After(lastActivity) {
+			lastActivity = reconnected
+		}
+		if ec.Connected() && time.Since(lastActivity) < timeout {
+			// Only a frame on the new connection proves that the reconnect succeeded
+			if lastFrame.After(reconnected) {
+				failures = 0
 			}
+			ec.ping()
+			continue
+		}
 
-			// Process the event using the provided function
-			eventFn(eventMsg)
+		if failures >= retries {
+			return fmt.Errorf("event stream still stale after %d reconnects", failures)
 		}
-	}()
+		failures++
 
-	return ec, nil
+		ec.log.Warnf("event stream is stale, reconnecting (attempt %d of %d)", failures, retries)
+		reconnected = time.Now()
+		if err := ec.Reconnect(); err != nil {
+			ec.log.Errorf("websocket reconnect error: %+v", err)
+			continue
+		}
+		ec.ping()
+	}
+}
+
+// ping sends a ping to the gateway, which answers with a pong.
+// Errors are ignored, because a failed connection is detected by the missing frames.
+func (ec *EventClient) ping() {
+	ec.mu.Lock()
+	c := ec.current
+	ec.mu.Unlock()
+
+	_ = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout))
+}
+
+// decode parses a WebSocket frame into an event message.
+// deCONZ occasionally sends empty or non-JSON frames (e.g. keepalives) and may add fields
+// the Messsage struct doesn't model. These frames are skipped without flooding the log,
+// decoding errors of JSON frames are only logged at debug level.
+//
+// Parameters:
+//   - message: The raw content of the WebSocket frame
+//
+// Returns:
+//   - *Messsage: A pointer to the parsed event, or nil if the frame is not an event
+func (ec *EventClient) decode(message []byte) *Messsage {
+	// Skip empty and non-JSON frames quietly
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 || !json.Valid(message) {
+		return nil
+	}
+
+	eventMsg := new(Messsage)
+	if err := json.Unmarshal(message, eventMsg); err != nil {
+		ec.log.Debugf("skipping message which couldn't be decoded: %v", err)
+		return nil
+	}
+
+	// Only event messages are processed
+	if eventMsg.Type != "event" {
+		ec.log.Debugf("skipping message of type %q", eventMsg.Type)
+		return nil
+	}
+
+	return eventMsg
+}
+
+// enqueue adds an event to the buffer without blocking.
+// If the buffer is full, the oldest event is dropped to make room for the new one,
+// because newer events supersede older states of the same device.
+//
+// Parameters:
+//   - eventMsg: A pointer to the received event
+func (ec *EventClient) enqueue(eventMsg *Messsage) {
+	for {
+		select {
+		case ec.events <- eventMsg:
+			return
+		default:
+		}
+
+		// The buffer is full, drop the oldest event
+		select {
+		case dropped := <-ec.events:
+			ec.log.Warnf("event buffer full, dropped the oldest event (%s %s)", dropped.EventType, dropped.RessourceType)
+		default:
+		}
+	}
+}
+
+// Connected reports whether the WebSocket connection is still open and events are processed.
+//
+// Returns:
+//   - bool: True if the connection is open
+func (ec *EventClient) Connected() bool {
+	ec.mu.Lock()
+	c := ec.current
+	ec.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// LastEvent returns the time the last event was received from the deCONZ gateway.
+//
+// Returns:
+//   - time.Time: The time of the last event, or the zero time if no event was received yet
+func (ec *EventClient) LastEvent() time.Time {
+	if t := ec.lastEvent.Load(); t > 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
 }
 
-// Stop closes the WebSocket connection and stops the event processing goroutine.
+// Stop closes the WebSocket connection and waits until the buffered events have been processed.
+// It is safe to call Stop multiple times.
 //
 // Returns:
 //   - error: Any error encountered while closing the connection
 func (ec *EventClient) Stop() error {
-	close(ec.done)
-	return ec.client.Close()
+	var err error
+	ec.stopOnce.Do(func() {
+		ec.mu.Lock()
+		defer ec.mu.Unlock()
+
+		close(ec.quit)
+		err = ec.current.close()
+		close(ec.events)
+		<-ec.processed
+	})
+	return err
 }
@@ -0,0 +1,392 @@
+package deconz
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLogger discards all messages of the client.
+type testLogger struct{}
+
+func (testLogger) Debugf(string, ...interface{}) {}
+func (testLogger) Infof(string, ...interface{})  {}
+func (testLogger) Warnf(string, ...interface{})  {}
+func (testLogger) Errorf(string, ...interface{}) {}
+
+// eventServer is a WebSocket server which sends scripted frames to every connection.
+type eventServer struct {
+	*httptest.Server
+
+	// mu guards conns
+	mu sync.Mutex
+
+	// conns are the connections accepted so far
+	conns []*websocket.Conn
+}
+
+// newEventServer starts a WebSocket server which sends the given frames after a client connected.
+func newEventServer(t *testing.T, frames ...string) *eventServer {
+	t.Helper()
+	return newStaleEventServer(t, 0, frames...)
+}
+
+// newStaleEventServer starts a WebSocket server whose first connections are stale: they stay
+// open, but neither send frames nor answer pings. Later connections send the given frames.
+func newStaleEventServer(t *testing.T, stale int, frames ...string) *eventServer {
+	t.Helper()
+
+	s := new(eventServer)
+	release := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		n := len(s.conns)
+		s.mu.Unlock()
+
+		// Pings are only answered while the connection is read
+		if n <= stale {
+			<-release
+			return
+		}
+
+		for _, frame := range frames {
+			if err = conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		}
+
+		// Keep the connection open and answer pings until the client closes it
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+	t.Cleanup(func() { close(release) })
+
+	return s
+}
+
+// url returns the WebSocket URL of the server.
+func (s *eventServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// connections returns the number of connections accepted so far.
+func (s *eventServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// collector records the events passed to the event function of a client.
+type collector struct {
+	mu     sync.Mutex
+	events []*Messsage
+	added  chan struct{}
+}
+
+func newCollector() *collector {
+	return &collector{added: make(chan struct{}, 100)}
+}
+
+func (c *collector) add(msg *Messsage) {
+	c.mu.Lock()
+	c.events = append(c.events, msg)
+	c.mu.Unlock()
+	c.added <- struct{}{}
+}
+
+// wait waits until the given number of events was received.
+func (c *collector) wait(t *testing.T, n int) []*Messsage {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		c.mu.Lock()
+		if len(c.events) >= n {
+			events := c.events
+			c.mu.Unlock()
+			return events
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.added:
+		case <-timeout:
+			t.Fatalf("received %d events, want %d", len(c.events), n)
+		}
+	}
+}
+
+func TestEventClientStartAndStop(t *testing.T) {
+	server := newEventServer(t, `{"t":"event","e":"changed","r":"lights","id":"1","uniqueid":"00:11-01","state":{"on":true}}`)
+	events := newCollector()
+
+	ec, err := NewEventClient(context.Background(), server.url(), nil, events.add, 0, testLogger{})
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+
+	received := events.wait(t, 1)
+	if got := *received[0].UniqueID; got != "00:11-01" {
+		t.Errorf("UniqueID = %q, want %q", got, "00:11-01")
+	}
+	if !ec.Connected() {
+		t.Error("Connected() = false, want true")
+	}
+
+	// Stopping twice must neither panic nor block
+	if err = ec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err = ec.Stop(); err != nil {
+		t.Errorf("second Stop() error = %v", err)
+	}
+	if ec.Connected() {
+		t.Error("Connected() after Stop() = true, want false")
+	}
+}
+
+func TestEventClientDropsOldestEvents(t *testing.T) {
+	ec := &EventClient{events: make(chan *Messsage, 2), log: testLogger{}}
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		ec.enqueue(&Messsage{RessourceID: &id})
+	}
+
+	// The buffer keeps the newest events in their order
+	for _, want := range []string{"3", "4"} {
+		if got := <-ec.events; *got.RessourceID != want {
+			t.Errorf("buffered event %s, want %s", *got.RessourceID, want)
+		}
+	}
+	if len(ec.events) != 0 {
+		t.Errorf("%d events left in the buffer, want 0", len(ec.events))
+	}
+}
+
+func TestEventClientKeepsReadingWhileProcessingIsSlow(t *testing.T) {
+	frames := make([]string, 20)
+	for i := range frames {
+		frames[i] = fmt.Sprintf(`{"t":"event","e":"changed","r":"lights","id":"%d","state":{"on":true}}`, i)
+	}
+	server := newEventServer(t, frames...)
+
+	// The first event blocks the processing until all frames have been read
+	events := newCollector()
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	ec, err := NewEventClient(context.Background(), server.url(), nil, func(msg *Messsage) {
+		once.Do(func() {
+			close(blocked)
+			<-release
+		})
+		events.add(msg)
+	}, 4, testLogger{})
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ec.Stop() })
+
+	// Give the client the time to read the remaining frames
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was processed")
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	// The newest event is never dropped and the events stay in order
+	waitForLast := time.After(5 * time.Second)
+	for {
+		events.mu.Lock()
+		n := len(events.events)
+		last := ""
+		if n > 0 {
+			last = *events.events[n-1].RessourceID
+		}
+		events.mu.Unlock()
+		if last == "19" {
+			break
+		}
+		select {
+		case <-events.added:
+		case <-waitForLast:
+			t.Fatalf("the last event was not processed, the last processed event is %q", last)
+		}
+	}
+
+	received := events.wait(t, 1)
+	if len(received) >= len(frames) {
+		t.Errorf("processed %d events, want the oldest events to be dropped", len(received))
+	}
+	previous := -1
+	for _, msg := range received {
+		var id int
+		_, _ = fmt.Sscan(*msg.RessourceID, &id)
+		if id <= previous {
+			t.Errorf("event %d was processed after event %d", id, previous)
+		}
+		previous = id
+	}
+}
+
+// countingLogger counts the warnings and errors of the client.
+type countingLogger struct {
+	testLogger
+
+	// problems is the number of warnings and errors
+	problems atomic.Int32
+}
+
+func (l *countingLogger) Warnf(string, ...interface{})  { l.problems.Add(1) }
+func (l *countingLogger) Errorf(string, ...interface{}) { l.problems.Add(1) }
+
+func TestEventClientDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   string
+		wantNil bool
+	}{
+		{"empty", "", true},
+		{"whitespace", " \n", true},
+		{"ping", "ping", true},
+		{"malformed", `{"t":"event","e":"changed"`, true},
+		{"wrong field type", `{"t":"event","e":"changed","r":"lights","id":5}`, true},
+		{"other message type", `{"t":"pong"}`, true},
+		{"array", `[1, 2]`, true},
+		{"event", `{"t":"event","e":"changed","r":"lights","id":"5","state":{"on":true}}`, false},
+		{"event with unknown fields", `{"t":"event","e":"changed","r":"lights","id":"5","unknown":{"a":1}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := new(countingLogger)
+			ec := &EventClient{log: logger}
+
+			got := ec.decode([]byte(tt.frame))
+			if (got == nil) != tt.wantNil {
+				t.Errorf("decode(%q) = %+v, want nil: %t", tt.frame, got, tt.wantNil)
+			}
+			if n := logger.problems.Load(); n > 0 {
+				t.Errorf("decode(%q) logged %d warnings or errors, want 0", tt.frame, n)
+			}
+		})
+	}
+}
+
+func TestEventClientSkipsFramesWhichAreNotEvents(t *testing.T) {
+	server := newEventServer(t,
+		"",
+		"ping",
+		`{"t":"event","e":"changed"`,
+		`{"t":"event","e":"changed","r":"lights","id":"5","state":{"on":true}}`,
+	)
+	events := newCollector()
+	logger := new(countingLogger)
+
+	ec, err := NewEventClient(context.Background(), server.url(), nil, events.add, 0, logger)
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+
+	// The connection survives the invalid frames and the event is processed
+	received := events.wait(t, 1)
+	if got := *received[0].RessourceID; got != "5" {
+		t.Errorf("event for %s, want 5", got)
+	}
+	if !ec.Connected() {
+		t.Error("Connected() = false, want true")
+	}
+	if err = ec.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if got := len(events.events); got != 1 {
+		t.Errorf("processed %d events, want 1", got)
+	}
+	if n := logger.problems.Load(); n > 0 {
+		t.Errorf("logged %d warnings or errors, want 0", n)
+	}
+}
+
+func TestEventClientWatchReconnectsStaleConnection(t *testing.T) {
+	// The first connection stays silent, the second one delivers the event
+	server := newStaleEventServer(t, 1, `{"t":"event","e":"changed","r":"lights","id":"5","state":{"on":true}}`)
+	events := newCollector()
+
+	ec, err := NewEventClient(context.Background(), server.url(), nil, events.add, 0, testLogger{})
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ec.Stop() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan error, 1)
+	go func() { watched <- ec.Watch(ctx, 200*time.Millisecond, 3) }()
+
+	received := events.wait(t, 1)
+	if got := *received[0].RessourceID; got != "5" {
+		t.Errorf("event for %s, want 5", got)
+	}
+	if got := server.connections(); got != 2 {
+		t.Errorf("%d connections, want 2", got)
+	}
+	if !ec.Connected() {
+		t.Error("Connected() = false, want true")
+	}
+
+	// The healthy connection is kept and Watch returns without an error when it is cancelled
+	time.Sleep(400 * time.Millisecond)
+	if got := server.connections(); got != 2 {
+		t.Errorf("%d connections after the reconnect, want 2", got)
+	}
+	cancel()
+	if err = <-watched; err != nil {
+		t.Errorf("Watch() error = %v", err)
+	}
+}
+
+func TestEventClientWatchGivesUp(t *testing.T) {
+	// All connections stay silent
+	server := newStaleEventServer(t, 100)
+
+	ec, err := NewEventClient(context.Background(), server.url(), nil, func(*Messsage) {}, 0, testLogger{})
+	if err != nil {
+		t.Fatalf("NewEventClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ec.Stop() })
+
+	watched := make(chan error, 1)
+	go func() { watched <- ec.Watch(context.Background(), 100*time.Millisecond, 2) }()
+
+	select {
+	case err = <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() didn't give up")
+	}
+	if err == nil {
+		t.Error("Watch() error = nil, want an error after the reconnects")
+	}
+
+	// The initial connection and one per reconnect
+	if got := server.connections(); got != 3 {
+		t.Errorf("%d connections, want 3", got)
+	}
+}
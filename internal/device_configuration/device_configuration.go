@@ -5,10 +5,14 @@ package deviceConfiguration
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/tidwall/pretty"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // ButtonEvent represents a type of button press event.
@@ -23,10 +27,105 @@ const (
 	// ButtonDoublePress represents a double press of a button
 	ButtonDoublePress ButtonEvent = "DOUBLE_PRESS"
 
-	// ButtonLongPress represents a long press of a button
+	// ButtonLongPress represents a long press of a button, which is reported when the button is released
 	ButtonLongPress ButtonEvent = "LONG_PRESS"
+
+	// ButtonTriplePress represents a triple press of a button
+	// HomeKit has no triple press, so it is reported as a double press followed by a single press
+	ButtonTriplePress ButtonEvent = "TRIPLE_PRESS"
+
+	// ButtonHold represents the start of a press-and-hold, which is reported while the button is still pressed
+	// Some remotes repeat it as long as the button is held, the release is reported as ButtonLongPress
+	ButtonHold ButtonEvent = "HOLD"
+)
+
+// buttonEvents contains all valid button events.
+var buttonEvents = []ButtonEvent{ButtonSinglePress, ButtonDoublePress, ButtonLongPress, ButtonTriplePress, ButtonHold}
+
+// Service represents a HomeKit service which is used instead of the default service of a device.
+type Service string
+
+// Constants defining the services which can be selected in a device configuration.
+const (
+	// ServiceFan exposes a light as a fan, whose brightness controls the rotation speed
+	ServiceFan Service = "fan"
+
+	// ServiceValve exposes an on/off output as a generic valve
+	ServiceValve Service = "valve"
+
+	// ServiceIrrigation exposes an on/off output as an irrigation valve
+	ServiceIrrigation Service = "irrigation"
+
+	// ServiceOutlet exposes an on/off device as an outlet (the default of plugs and on/off outputs)
+	ServiceOutlet Service = "outlet"
+
+	// ServiceSwitch exposes an on/off device as a generic switch, e.g. a wall relay
+	ServiceSwitch Service = "switch"
+
+	// ServiceLightbulb exposes an on/off device as a light with the capabilities reported by deCONZ
+	ServiceLightbulb Service = "lightbulb"
+
+	// ServiceGarageDoor exposes an on/off output together with a contact sensor as a garage door opener
+	// This service is only available in the overrides, because it requires the unique ID of the sensor
+	ServiceGarageDoor Service = "garageDoor"
+)
+
+// Category represents the HomeKit accessory category of a device, which is used instead
+// of the category derived from the type of the device.
+type Category string
+
+// Constants defining the categories which can be selected for a device.
+const (
+	// CategoryLightbulb is the category of lights
+	CategoryLightbulb Category = "lightbulb"
+
+	// CategoryOutlet is the category of plugs and on/off outputs
+	CategoryOutlet Category = "outlet"
+
+	// CategorySwitch is the category of stateful switches
+	CategorySwitch Category = "switch"
+
+	// CategoryProgrammableSwitch is the category of remotes and buttons
+	CategoryProgrammableSwitch Category = "programmableSwitch"
+
+	// CategorySensor is the category of sensors
+	CategorySensor Category = "sensor"
+
+	// CategoryFan is the category of fans
+	CategoryFan Category = "fan"
+
+	// CategoryGarageDoorOpener is the category of garage door openers
+	CategoryGarageDoorOpener Category = "garageDoorOpener"
+
+	// CategoryDoorLock is the category of door locks
+	CategoryDoorLock Category = "doorLock"
+
+	// CategoryWindowCovering is the category of blinds and shutters
+	CategoryWindowCovering Category = "windowCovering"
+
+	// CategoryThermostat is the category of thermostats
+	CategoryThermostat Category = "thermostat"
+
+	// CategoryAirPurifier is the category of air purifiers
+	CategoryAirPurifier Category = "airPurifier"
+
+	// CategorySprinkler is the category of irrigation systems
+	CategorySprinkler Category = "sprinkler"
+
+	// CategoryFaucet is the category of faucets and generic valves
+	CategoryFaucet Category = "faucet"
+
+	// CategoryOther is the category of devices which fit no other category
+	CategoryOther Category = "other"
 )
 
+// Categories contains all valid categories.
+var Categories = []Category{
+	CategoryLightbulb, CategoryOutlet, CategorySwitch, CategoryProgrammableSwitch, CategorySensor,
+	CategoryFan, CategoryGarageDoorOpener, CategoryDoorLock, CategoryWindowCovering, CategoryThermostat,
+	CategoryAirPurifier, CategorySprinkler, CategoryFaucet, CategoryOther,
+}
+
 // ButtonConfiguration represents the configuration for a single button on a device.
 // It defines the button's name and how its raw events map to button press types.
 type ButtonConfiguration struct {
@@ -55,6 +154,72 @@ type DeviceConfiguration struct {
 
 	// Buttons is a list of button configurations for this device
 	Buttons []ButtonConfiguration `json:"buttons"`
+
+	// Service selects a different HomeKit service for the lights of this device
+	// (e.g. ServiceFan for fan controllers or ServiceValve for water valves, which deCONZ reports as lights)
+	Service Service `json:"service,omitempty"`
+
+	// Category replaces the HomeKit accessory category derived from the type of the devices
+	Category Category `json:"category,omitempty"`
+}
+
+// Validate checks that the configuration can be used for a device.
+// A configuration must apply to at least one model and define buttons, a service or a category.
+// All events of a button must have a valid event code of the same button number and
+// a known button event, otherwise button presses would be silently ignored.
+//
+// Returns:
+//   - error: An error describing all problems, or nil if the configuration is valid
+func (dc *DeviceConfiguration) Validate() error {
+	var errs []error
+
+	if len(dc.Models) == 0 {
+		errs = append(errs, errors.New("no models"))
+	}
+	for _, model := range dc.Models {
+		if len(model) == 0 {
+			errs = append(errs, errors.New("empty model"))
+		}
+	}
+
+	switch dc.Service {
+	case "", ServiceFan, ServiceValve, ServiceIrrigation, ServiceOutlet, ServiceSwitch, ServiceLightbulb, ServiceGarageDoor:
+	default:
+		errs = append(errs, fmt.Errorf("unsupported service %q", dc.Service))
+	}
+	if len(dc.Category) > 0 && !slices.Contains(Categories, dc.Category) {
+		errs = append(errs, fmt.Errorf("unknown category %q", dc.Category))
+	}
+	if len(dc.Buttons) == 0 && len(dc.Service) == 0 && len(dc.Category) == 0 {
+		errs = append(errs, errors.New("neither buttons, a service nor a category"))
+	}
+
+	for i, button := range dc.Buttons {
+		if len(button.EventMap) == 0 {
+			errs = append(errs, fmt.Errorf("button %d (%s): no events", i+1, button.Name))
+			continue
+		}
+
+		buttonNumbers := make(map[string]bool)
+		for code, event := range button.EventMap {
+			if _, err := strconv.Atoi(code); err != nil || len(code) <= 3 {
+				errs = append(errs, fmt.Errorf("button %d (%s): invalid event code %q", i+1, button.Name, code))
+				continue
+			}
+			if !slices.Contains(buttonEvents, event) {
+				errs = append(errs, fmt.Errorf("button %d (%s): unknown event %q for %s", i+1, button.Name, event, code))
+			}
+			buttonNumber, _ := SplitEventId(code)
+			buttonNumbers[buttonNumber] = true
+		}
+
+		// The button number is taken from any of the event codes, so they must all match
+		if len(buttonNumbers) > 1 {
+			errs = append(errs, fmt.Errorf("button %d (%s): event codes of different buttons", i+1, button.Name))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // SaveToFile saves the device configuration to a JSON file.
@@ -104,6 +269,12 @@ func LoadFromDirectory(dir string) (map[string]DeviceConfiguration, error) {
 			// Parse the JSON into a DeviceConfiguration
 			config := new(DeviceConfiguration)
 			if err = json.Unmarshal(file, config); err == nil {
+				// Skip invalid configurations instead of registering broken buttons
+				if err := config.Validate(); err != nil {
+					fmt.Printf("Invalid device configuration file %s: %s\n", fileName, strings.ReplaceAll(err.Error(), "\n", "; "))
+					continue
+				}
+
 				// Add the configuration to the map for each model it applies to
 				for _, model := range config.Models {
 					configMap[model] = *config
@@ -122,7 +293,7 @@ func LoadFromDirectory(dir string) (map[string]DeviceConfiguration, error) {
 }
 
 // SplitEventId splits a button event ID into a button number and an event code.
-// For example, "1001" would be split into "10" (button number) and "01" (event code).
+// For example, "1001" would be split into "1" (button number) and "001" (event code).
 // This is used to identify which button was pressed and what type of press it was.
 //
 // Parameters:
@@ -133,6 +304,9 @@ func LoadFromDirectory(dir string) (map[string]DeviceConfiguration, error) {
 //   - string: The event code
 func SplitEventId(event string) (string, string) {
 	// The last 3 characters are the event code, the rest is the button number
+	if len(event) <= 3 {
+		return "", event
+	}
 	prefix := event[:len(event)-3]
 	suffix := event[len(event)-3:]
 	return prefix, suffix
@@ -0,0 +1,114 @@
+package deviceConfiguration
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	button := ButtonConfiguration{Name: "On", EventMap: map[string]ButtonEvent{"1002": ButtonSinglePress, "1003": ButtonLongPress}}
+	tests := []struct {
+		name    string
+		config  DeviceConfiguration
+		wantErr string
+	}{
+		{"buttons", DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{button}}, ""},
+		{"service", DeviceConfiguration{Models: []string{"Fan"}, Service: ServiceFan}, ""},
+		{"garage door", DeviceConfiguration{Models: []string{"Relay"}, Service: ServiceGarageDoor}, ""},
+		{"category", DeviceConfiguration{Models: []string{"Sensor"}, Category: CategorySensor}, ""},
+		{"no models", DeviceConfiguration{Buttons: []ButtonConfiguration{button}}, "no models"},
+		{"empty model", DeviceConfiguration{Models: []string{""}, Buttons: []ButtonConfiguration{button}}, "empty model"},
+		{"nothing to configure", DeviceConfiguration{Models: []string{"RWL021"}}, "neither buttons"},
+		{"unknown service", DeviceConfiguration{Models: []string{"Fan"}, Service: "blender"}, `unsupported service "blender"`},
+		{"unknown category", DeviceConfiguration{Models: []string{"Fan"}, Category: "toaster"}, `unknown category "toaster"`},
+		{
+			name:    "no events",
+			config:  DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{{Name: "On"}}},
+			wantErr: "button 1 (On): no events",
+		},
+		{
+			name: "event code without a button number",
+			config: DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{
+				{Name: "On", EventMap: map[string]ButtonEvent{"002": ButtonSinglePress}},
+			}},
+			wantErr: `invalid event code "002"`,
+		},
+		{
+			name: "event code which is not a number",
+			config: DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{
+				{Name: "On", EventMap: map[string]ButtonEvent{"on_press": ButtonSinglePress}},
+			}},
+			wantErr: `invalid event code "on_press"`,
+		},
+		{
+			name: "unknown event",
+			config: DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{
+				{Name: "On", EventMap: map[string]ButtonEvent{"1002": "QUADRUPLE_PRESS"}},
+			}},
+			wantErr: `unknown event "QUADRUPLE_PRESS" for 1002`,
+		},
+		{
+			name: "events of different buttons",
+			config: DeviceConfiguration{Models: []string{"RWL021"}, Buttons: []ButtonConfiguration{
+				{Name: "On", EventMap: map[string]ButtonEvent{"1002": ButtonSinglePress, "2002": ButtonSinglePress}},
+			}},
+			wantErr: "event codes of different buttons",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFromDirectorySkipsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"valid.json": `{
+			"schemaVersion": "1.0",
+			"manufacturer": "Signify Netherlands B.V.",
+			"models": ["RWL021", "RWL020"],
+			"buttons": [{"name": "On", "eventMap": {"1002": "SINGLE_PRESS"}}]
+		}`,
+		"malformed.json":     `{"models": ["Broken"], "buttons": [`,
+		"empty_events.json":  `{"models": ["EmptyEvents"], "buttons": [{"name": "On", "eventMap": {}}]}`,
+		"invalid_code.json":  `{"models": ["InvalidCode"], "buttons": [{"name": "On", "eventMap": {"x1002": "SINGLE_PRESS"}}]}`,
+		"unknown_event.json": `{"models": ["UnknownEvent"], "buttons": [{"name": "On", "eventMap": {"1002": "PRESS"}}]}`,
+		"no_models.json":     `{"buttons": [{"name": "On", "eventMap": {"1002": "SINGLE_PRESS"}}]}`,
+		"ignored.txt":        `not a configuration`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configs, err := LoadFromDirectory(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDirectory() error = %v", err)
+	}
+
+	// Only the valid configuration is registered, for each of its models
+	models := slices.Sorted(maps.Keys(configs))
+	if !slices.Equal(models, []string{"RWL020", "RWL021"}) {
+		t.Errorf("loaded the models %v, want [RWL020 RWL021]", models)
+	}
+	if got := configs["RWL021"].Buttons[0].EventMap["1002"]; got != ButtonSinglePress {
+		t.Errorf("event 1002 = %q, want %q", got, ButtonSinglePress)
+	}
+}
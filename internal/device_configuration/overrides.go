@@ -0,0 +1,115 @@
+// Package deviceConfiguration provides functionality for loading, parsing, and managing
+// device configuration files. These configurations define how different Zigbee devices
+// (particularly remote controls and switches) map their button events to HomeKit actions.
+package deviceConfiguration
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Override represents user-defined settings for a single device, which take
+// precedence over the values reported by deCONZ.
+type Override struct {
+	// Name replaces the deCONZ name of the device
+	Name string `json:"name,omitempty"`
+
+	// Manufacturer replaces the manufacturer reported by deCONZ in the accessory information
+	Manufacturer string `json:"manufacturer,omitempty"`
+
+	// Model replaces the model reported by deCONZ in the accessory information
+	Model string `json:"model,omitempty"`
+
+	// Firmware replaces the firmware version reported by deCONZ in the accessory information
+	Firmware string `json:"firmware,omitempty"`
+
+	// Type replaces the deCONZ type (e.g. "On/Off plug-in unit") and thereby
+	// selects the HomeKit service used for the device
+	Type string `json:"type,omitempty"`
+
+	// Service selects a different HomeKit service for the device (e.g. ServiceGarageDoor)
+	Service Service `json:"service,omitempty"`
+
+	// Category replaces the HomeKit accessory category derived from the type of the device
+	Category Category `json:"category,omitempty"`
+
+	// ContactSensor is the unique ID of the contact sensor reporting the door position
+	// of a garage door (only for ServiceGarageDoor)
+	ContactSensor string `json:"contactSensor,omitempty"`
+
+	// FeedbackWindow replaces the time state updates from deCONZ are ignored after a change
+	// from HomeKit (e.g. "2s" for devices in a slow part of the mesh)
+	FeedbackWindow *Duration `json:"feedbackWindow,omitempty"`
+
+	// MinBrightness is the raw deCONZ brightness (1-253) HomeKit's 1% is mapped to,
+	// for lights which flicker or appear off at very low brightness values
+	MinBrightness int `json:"minBrightness,omitempty"`
+
+	// PowerUp is the powerup setting (a bitmap of the values restored after a power cut)
+	// which is applied to the light on startup, if it differs from the setting in deCONZ
+	PowerUp *int `json:"powerUp,omitempty"`
+}
+
+// Duration is a time.Duration which is written as a string (e.g. "1.5s") in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string like "1.5s" or "500ms".
+//
+// Parameters:
+//   - data: The JSON encoded duration
+//
+// Returns:
+//   - error: An error if the duration is not a valid duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string like "1.5s".
+//
+// Returns:
+//   - []byte: The JSON encoded duration
+//   - error: Always nil
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadOverrides loads the device overrides from a JSON file.
+// The file contains an object mapping unique IDs to overrides, for example:
+//
+//	{
+//	  "00:11:22:33:44:55:66:77": { "name": "Desk Lamp" },
+//	  "00:11:22:33:44:55:66:88-01": { "type": "On/Off plug-in unit" },
+//	  "00:11:22:33:44:55:66:99-01": { "service": "garageDoor", "contactSensor": "00:11:22:33:44:55:66:aa-01-0006" }
+//	}
+//
+// Parameters:
+//   - file: The path to the file to load
+//
+// Returns:
+//   - map[string]Override: A map of unique IDs to overrides
+//   - error: An error if the file could not be read or parsed
+func LoadOverrides(file string) (map[string]Override, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]Override)
+	if err = json.Unmarshal(data, &overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
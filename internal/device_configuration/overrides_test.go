@@ -0,0 +1,37 @@
+package deviceConfiguration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadOverrides(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "overrides.json")
+	data := `{
+		"00:11:22:33:44:55:66:77": {"name": "Desk Lamp", "feedbackWindow": "1.5s"},
+		"00:11:22:33:44:55:66:88-01": {"type": "On/Off plug-in unit", "service": "outlet"}
+	}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides, err := LoadOverrides(file)
+	if err != nil {
+		t.Fatalf("LoadOverrides() error = %v", err)
+	}
+
+	lamp := overrides["00:11:22:33:44:55:66:77"]
+	if lamp.Name != "Desk Lamp" {
+		t.Errorf("Name = %q, want %q", lamp.Name, "Desk Lamp")
+	}
+	if lamp.FeedbackWindow == nil || time.Duration(*lamp.FeedbackWindow) != 1500*time.Millisecond {
+		t.Errorf("FeedbackWindow = %v, want 1.5s", lamp.FeedbackWindow)
+	}
+
+	plug := overrides["00:11:22:33:44:55:66:88-01"]
+	if plug.Type != "On/Off plug-in unit" || plug.Service != ServiceOutlet || len(plug.Name) > 0 {
+		t.Errorf("plug override = %+v, want the type and service only", plug)
+	}
+}
@@ -0,0 +1,23 @@
+// Package helper provides conversion functions between the value formats used by
+// deCONZ and the formats expected by HomeKit.
+package helper
+
+// pm25Levels are the upper limits of the PM2.5 density in µg/m³ for the HomeKit air quality
+// levels excellent, good, fair and inferior. They follow the breakpoints of the US air quality index.
+var pm25Levels = []float64{12, 35.4, 55.4, 150.4}
+
+// PM25ToAirQuality converts a PM2.5 density to the air quality level of HomeKit.
+//
+// Parameters:
+//   - density: The PM2.5 density in µg/m³
+//
+// Returns:
+//   - int: The air quality (1 = excellent, 2 = good, 3 = fair, 4 = inferior, 5 = poor)
+func PM25ToAirQuality(density float64) int {
+	for i, limit := range pm25Levels {
+		if density <= limit {
+			return i + 1
+		}
+	}
+	return len(pm25Levels) + 1
+}
@@ -0,0 +1,69 @@
+// Package helper provides conversion functions between the value formats used by
+// deCONZ and the formats expected by HomeKit.
+package helper
+
+import "math"
+
+// DefaultMinBrightness is the raw deCONZ brightness HomeKit's 1% is mapped to by default.
+const DefaultMinBrightness = 1
+
+// MaxBrightness is the highest usable raw brightness of deCONZ lights.
+// Zigbee reserves 255, which some bulbs treat specially, so 100% is mapped to 254.
+const MaxBrightness = 254
+
+// PercentToBrightness converts a HomeKit brightness in percent to the raw deCONZ brightness.
+//
+// The range 1-100% is mapped linearly to minimum-254, so that 1% results in the lowest
+// usable brightness of the light. This avoids bulbs which flicker or appear off at very
+// low raw values. 0% results in 0, which turns the light off.
+//
+// Parameters:
+//   - percent: The brightness in percent (0-100)
+//   - minimum: The raw brightness 1% is mapped to (1-253)
+//
+// Returns:
+//   - int: The raw brightness (0 or minimum-254)
+func PercentToBrightness(percent int, minimum int) int {
+	if percent <= 0 {
+		return 0
+	}
+	percent = min(percent, 100)
+	minimum = clampMinBrightness(minimum)
+
+	return int(math.Round(float64(minimum) + float64(percent-1)*float64(MaxBrightness-minimum)/99))
+}
+
+// BrightnessToPercent converts a raw deCONZ brightness to the HomeKit brightness in percent.
+// This is the inverse of PercentToBrightness, values below the minimum are reported as 1%
+// and the reserved value 255 as 100%. Converting a percentage to the raw brightness and back
+// results in the same percentage, unless the minimum leaves fewer raw values than percentages.
+//
+// Parameters:
+//   - brightness: The raw brightness (0-255)
+//   - minimum: The raw brightness 1% is mapped to (1-253)
+//
+// Returns:
+//   - int: The brightness in percent (0-100)
+func BrightnessToPercent(brightness int, minimum int) int {
+	if brightness <= 0 {
+		return 0
+	}
+	minimum = clampMinBrightness(minimum)
+	if brightness <= minimum {
+		return 1
+	}
+
+	percent := int(math.Round(1 + float64(brightness-minimum)*99/float64(MaxBrightness-minimum)))
+	return min(percent, 100)
+}
+
+// clampMinBrightness limits the minimum brightness to the valid range 1-253.
+//
+// Parameters:
+//   - minimum: The configured minimum brightness
+//
+// Returns:
+//   - int: The minimum brightness within 1-253
+func clampMinBrightness(minimum int) int {
+	return max(1, min(minimum, MaxBrightness-1))
+}
@@ -0,0 +1,72 @@
+package helper
+
+import "testing"
+
+func TestPercentToBrightness(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent int
+		minimum int
+		want    int
+	}{
+		{"off", 0, 1, 0},
+		{"off with a minimum", 0, 30, 0},
+		{"negative", -5, 1, 0},
+		{"lowest", 1, 1, 1},
+		{"lowest with a minimum", 1, 30, 30},
+		{"half", 50, 1, 126},
+		{"full", 100, 1, MaxBrightness},
+		{"full with a minimum", 100, 30, MaxBrightness},
+		{"above full", 101, 1, MaxBrightness},
+		{"minimum below the range", 1, 0, 1},
+		{"minimum above the range", 1, 254, 253},
+		{"full with the highest minimum", 100, 254, MaxBrightness},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PercentToBrightness(tt.percent, tt.minimum); got != tt.want {
+				t.Errorf("PercentToBrightness(%d, %d) = %d, want %d", tt.percent, tt.minimum, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrightnessToPercent(t *testing.T) {
+	tests := []struct {
+		name       string
+		brightness int
+		minimum    int
+		want       int
+	}{
+		{"off", 0, 1, 0},
+		{"lowest", 1, 1, 1},
+		{"below the minimum", 10, 30, 1},
+		{"minimum", 30, 30, 1},
+		{"full", MaxBrightness, 1, 100},
+		{"reserved", 255, 1, 100},
+		{"reserved with a minimum", 255, 30, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BrightnessToPercent(tt.brightness, tt.minimum); got != tt.want {
+				t.Errorf("BrightnessToPercent(%d, %d) = %d, want %d", tt.brightness, tt.minimum, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrightnessRoundTrip(t *testing.T) {
+	for _, minimum := range []int{1, 30, 128} {
+		for percent := range 101 {
+			raw := PercentToBrightness(percent, minimum)
+			if raw != 0 && (raw < minimum || raw > MaxBrightness) {
+				t.Errorf("PercentToBrightness(%d, %d) = %d, want a value within %d-%d", percent, minimum, raw, minimum, MaxBrightness)
+			}
+			if got := BrightnessToPercent(raw, minimum); got != percent {
+				t.Errorf("BrightnessToPercent(PercentToBrightness(%d, %d)) = %d", percent, minimum, got)
+			}
+		}
+	}
+}
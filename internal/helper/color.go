@@ -0,0 +1,188 @@
+// Package helper provides conversion functions between the value formats used by
+// deCONZ and the formats expected by HomeKit.
+package helper
+
+import "math"
+
+// XYToHueSat converts a color in the CIE xy color space (as reported by deCONZ)
+// to the hue and saturation used by HomeKit.
+//
+// The xy coordinates are converted to XYZ using the given brightness as luminance,
+// then to linear sRGB (D65) and gamma corrected.
+//
+// Gamut clamping: coordinates outside the sRGB triangle (e.g. the deep greens many
+// Zigbee lights can show) produce negative channel values, which are clamped to 0.
+// Such colors are mapped to the most saturated sRGB color with a similar hue, so
+// converting them back with HueSatToXY yields a point on the edge of the sRGB gamut
+// instead of the original coordinates. Coordinates inside the gamut round-trip.
+//
+// Parameters:
+//   - x: The x coordinate of the color (0-1)
+//   - y: The y coordinate of the color (0-1)
+//   - brightness: The brightness of the color (0-1)
+//
+// Returns:
+//   - hue: The hue in degrees (0-360)
+//   - sat: The saturation in percent (0-100)
+func XYToHueSat(x, y, brightness float64) (hue float64, sat float64) {
+	// A y coordinate of 0 is undefined, treat it as white
+	if y <= 0 {
+		return 0, 0
+	}
+
+	// Convert xy and the luminance to XYZ
+	Y := brightness
+	if Y <= 0 {
+		Y = 1
+	}
+	X := (Y / y) * x
+	Z := (Y / y) * (1 - x - y)
+
+	// Convert XYZ to linear sRGB (D65)
+	r := X*3.2404542 - Y*1.5371385 - Z*0.4985314
+	g := -X*0.9692660 + Y*1.8760108 + Z*0.0415560
+	b := X*0.0556434 - Y*0.2040259 + Z*1.0572252
+
+	// Clamp colors outside the gamut and normalize to the brightest channel
+	r, g, b = max(r, 0), max(g, 0), max(b, 0)
+	if m := max(r, g, b); m > 1 {
+		r, g, b = r/m, g/m, b/m
+	}
+
+	return rgbToHueSat(gammaCorrect(r), gammaCorrect(g), gammaCorrect(b))
+}
+
+// HueSatToXY converts the hue and saturation used by HomeKit to a color in the
+// CIE xy color space (as expected by deCONZ). This is the inverse of XYToHueSat.
+//
+// The color is converted to sRGB, linearized and converted to XYZ (D65), from which
+// the xy chromaticity is derived. Out-of-range hue and saturation values are clamped
+// to 0-360 and 0-100. Since every sRGB color lies inside the CIE gamut, the result
+// is always a valid xy coordinate; black returns the D65 white point.
+//
+// Parameters:
+//   - hue: The hue in degrees (0-360)
+//   - sat: The saturation in percent (0-100)
+//   - brightness: The brightness of the color (0-1)
+//
+// Returns:
+//   - x: The x coordinate of the color (0-1)
+//   - y: The y coordinate of the color (0-1)
+func HueSatToXY(hue, sat, brightness float64) (x float64, y float64) {
+	hue = math.Mod(min(max(hue, 0), 360), 360)
+	sat = min(max(sat, 0), 100) / 100
+	if brightness <= 0 {
+		brightness = 1
+	}
+	brightness = min(brightness, 1)
+
+	// Convert HSV to RGB and remove the gamma correction
+	r, g, b := hueSatToRGB(hue, sat, brightness)
+	r, g, b = gammaExpand(r), gammaExpand(g), gammaExpand(b)
+
+	// Convert linear sRGB (D65) to XYZ
+	X := r*0.4124564 + g*0.3575761 + b*0.1804375
+	Y := r*0.2126729 + g*0.7151522 + b*0.0721750
+	Z := r*0.0193339 + g*0.1191920 + b*0.9503041
+
+	sum := X + Y + Z
+	if sum <= 0 {
+		return 0.3127, 0.3290
+	}
+
+	return X / sum, Y / sum
+}
+
+// hueSatToRGB converts a color in HSV to RGB.
+//
+// Parameters:
+//   - hue: The hue in degrees (0-360)
+//   - sat: The saturation (0-1)
+//   - value: The value/brightness (0-1)
+//
+// Returns:
+//   - r, g, b: The channel values of the color (0-1)
+func hueSatToRGB(hue, sat, value float64) (r, g, b float64) {
+	c := value * sat
+	h := hue / 60
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	m := value - c
+
+	switch {
+	case h < 1:
+		r, g, b = c, x, 0
+	case h < 2:
+		r, g, b = x, c, 0
+	case h < 3:
+		r, g, b = 0, c, x
+	case h < 4:
+		r, g, b = 0, x, c
+	case h < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return r + m, g + m, b + m
+}
+
+// gammaExpand removes the sRGB gamma correction from a channel value.
+//
+// Parameters:
+//   - v: The gamma corrected channel value (0-1)
+//
+// Returns:
+//   - float64: The linear channel value (0-1)
+func gammaExpand(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// gammaCorrect applies the sRGB gamma correction to a linear channel value.
+//
+// Parameters:
+//   - v: The linear channel value (0-1)
+//
+// Returns:
+//   - float64: The gamma corrected channel value (0-1)
+func gammaCorrect(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// rgbToHueSat converts an RGB color to hue and saturation (HSV).
+//
+// Parameters:
+//   - r, g, b: The channel values of the color (0-1)
+//
+// Returns:
+//   - hue: The hue in degrees (0-360)
+//   - sat: The saturation in percent (0-100)
+func rgbToHueSat(r, g, b float64) (hue float64, sat float64) {
+	cMax := max(r, g, b)
+	cMin := min(r, g, b)
+	delta := cMax - cMin
+
+	// Black and grey colors have no hue and saturation
+	if cMax <= 0 || delta <= 0 {
+		return 0, 0
+	}
+
+	switch cMax {
+	case r:
+		hue = 60 * math.Mod((g-b)/delta, 6)
+	case g:
+		hue = 60 * ((b-r)/delta + 2)
+	default:
+		hue = 60 * ((r-g)/delta + 4)
+	}
+	if hue < 0 {
+		hue += 360
+	}
+
+	return hue, delta / cMax * 100
+}
@@ -0,0 +1,52 @@
+package helper
+
+import (
+	"math"
+	"testing"
+)
+
+// hueDistance returns the distance between two hues on the color wheel.
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return min(d, 360-d)
+}
+
+func TestHueSatToXYRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		hue   float64
+		sat   float64
+		wantX float64
+		wantY float64
+	}{
+		// The xy coordinates of the sRGB primaries
+		{"red", 0, 100, 0.64, 0.33},
+		{"green", 120, 100, 0.30, 0.60},
+		{"blue", 240, 100, 0.15, 0.06},
+		// A warm white of about 2700 K
+		{"warm white", 30, 65, 0.460, 0.411},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := HueSatToXY(tt.hue, tt.sat, 1)
+			if math.Abs(x-tt.wantX) > 0.01 || math.Abs(y-tt.wantY) > 0.01 {
+				t.Errorf("HueSatToXY(%v, %v) = %.4f, %.4f, want %.4f, %.4f", tt.hue, tt.sat, x, y, tt.wantX, tt.wantY)
+			}
+
+			hue, sat := XYToHueSat(x, y, 1)
+			if hueDistance(hue, tt.hue) > 0.5 || math.Abs(sat-tt.sat) > 0.5 {
+				t.Errorf("XYToHueSat(%.4f, %.4f) = %.1f, %.1f, want %v, %v", x, y, hue, sat, tt.hue, tt.sat)
+			}
+		})
+	}
+}
+
+func TestHueSatToXYWhitePoint(t *testing.T) {
+	// Colors without saturation and black are the D65 white point
+	for _, tt := range []struct{ hue, sat, brightness float64 }{{0, 0, 1}, {200, 0, 0.5}} {
+		if x, y := HueSatToXY(tt.hue, tt.sat, tt.brightness); math.Abs(x-0.3127) > 0.001 || math.Abs(y-0.3290) > 0.001 {
+			t.Errorf("HueSatToXY(%v, %v, %v) = %.4f, %.4f, want the D65 white point", tt.hue, tt.sat, tt.brightness, x, y)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package helper provides conversion functions between the value formats used by
+// deCONZ and the formats expected by HomeKit.
+package helper
+
+import "math"
+
+// Range of the CurrentAmbientLightLevel characteristic of HomeKit in lux.
+const (
+	MinLux = 0.0001
+	MaxLux = 100000
+)
+
+// MaxLightLevel is the highest light level reported by deCONZ.
+const MaxLightLevel = 65535
+
+// LightLevelToLux converts a deCONZ light level to lux.
+//
+// deCONZ reports the light level on a logarithmic scale (lightlevel = 10000 * log10(lux) + 1),
+// so the lux value is 10^((lightlevel - 1) / 10000). A light level of 0 means that no light
+// was measured. The result is clamped to the range accepted by HomeKit.
+//
+// Parameters:
+//   - level: The light level reported by deCONZ (0-65535)
+//
+// Returns:
+//   - float64: The illuminance in lux (0.0001-100000)
+func LightLevelToLux(level int) float64 {
+	if level <= 0 {
+		return MinLux
+	}
+
+	lux := math.Pow(10, float64(level-1)/10000)
+	return max(MinLux, min(lux, MaxLux))
+}
+
+// LuxToLightLevel converts lux to a deCONZ light level.
+// This is the inverse of LightLevelToLux, the result is clamped to the range 0-65535.
+//
+// Parameters:
+//   - lux: The illuminance in lux
+//
+// Returns:
+//   - int: The light level (0-65535)
+func LuxToLightLevel(lux float64) int {
+	if lux <= 0 {
+		return 0
+	}
+
+	level := math.Round(10000*math.Log10(lux) + 1)
+	return int(max(0, min(level, MaxLightLevel)))
+}
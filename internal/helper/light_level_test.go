@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLightLevelToLux(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+		want  float64
+	}{
+		{"no light", 0, MinLux},
+		{"negative", -1, MinLux},
+		{"one lux", 1, 1},
+		{"ten lux", 10001, 10},
+		{"bright daylight", 40000, 9997.7},
+		{"highest level", MaxLightLevel, MaxLux},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LightLevelToLux(tt.level); math.Abs(got-tt.want) > 0.05 {
+				t.Errorf("LightLevelToLux(%d) = %v, want %v", tt.level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLuxToLightLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		lux  float64
+		want int
+	}{
+		{"no light", 0, 0},
+		{"negative", -5, 0},
+		{"below the range", MinLux, 0},
+		{"one lux", 1, 1},
+		{"ten thousand lux", 10000, 40001},
+		{"above the range", 1e7, MaxLightLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LuxToLightLevel(tt.lux); got != tt.want {
+				t.Errorf("LuxToLightLevel(%v) = %d, want %d", tt.lux, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLightLevelRoundTrip(t *testing.T) {
+	// Levels above 50001 exceed the range of HomeKit and are clamped
+	for _, level := range []int{1, 2, 100, 10001, 23000, 40000, 50001} {
+		if got := LuxToLightLevel(LightLevelToLux(level)); got != level {
+			t.Errorf("LuxToLightLevel(LightLevelToLux(%d)) = %d", level, got)
+		}
+	}
+}
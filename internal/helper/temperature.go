@@ -0,0 +1,30 @@
+// Package helper provides conversion functions between the value formats used by
+// deCONZ and the formats expected by HomeKit.
+package helper
+
+import "math"
+
+// DeciCelsiusToHK converts a temperature reported by deCONZ to the HomeKit format.
+// deCONZ reports temperatures as integers in hundredths of a degree Celsius
+// (e.g. 2150 = 21.5 °C), while HomeKit expects a float in degrees Celsius.
+//
+// Parameters:
+//   - v: The temperature in hundredths of a degree Celsius
+//
+// Returns:
+//   - float64: The temperature in degrees Celsius
+func DeciCelsiusToHK(v int) float64 {
+	return float64(v) / 100.0
+}
+
+// HKToDeciCelsius converts a HomeKit temperature to the format used by deCONZ.
+// This is the inverse of DeciCelsiusToHK and rounds to the nearest hundredth of a degree.
+//
+// Parameters:
+//   - v: The temperature in degrees Celsius
+//
+// Returns:
+//   - int: The temperature in hundredths of a degree Celsius
+func HKToDeciCelsius(v float64) int {
+	return int(math.Round(v * 100.0))
+}
@@ -0,0 +1,61 @@
+package helper
+
+import "testing"
+
+func TestDeciCelsiusToHK(t *testing.T) {
+	tests := []struct {
+		name string
+		in   int
+		want float64
+	}{
+		{"zero", 0, 0},
+		{"whole degrees", 2100, 21},
+		{"fractional", 2150, 21.5},
+		{"hundredths", 2157, 21.57},
+		{"negative", -500, -5},
+		{"negative fractional", -1234, -12.34},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeciCelsiusToHK(tt.in); got != tt.want {
+				t.Errorf("DeciCelsiusToHK(%d) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHKToDeciCelsius(t *testing.T) {
+	tests := []struct {
+		name string
+		in   float64
+		want int
+	}{
+		{"zero", 0, 0},
+		{"whole degrees", 21, 2100},
+		{"fractional", 21.5, 2150},
+		{"hundredths", 21.57, 2157},
+		{"rounded down", 21.504, 2150},
+		{"rounded up", 21.506, 2151},
+		{"negative", -5, -500},
+		{"negative fractional", -12.34, -1234},
+		{"negative rounded", -5.006, -501},
+		{"negative close to zero", -0.004, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HKToDeciCelsius(tt.in); got != tt.want {
+				t.Errorf("HKToDeciCelsius(%v) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemperatureRoundTrip(t *testing.T) {
+	for v := -5000; v <= 10000; v++ {
+		if got := HKToDeciCelsius(DeciCelsiusToHK(v)); got != v {
+			t.Errorf("HKToDeciCelsius(DeciCelsiusToHK(%d)) = %d", v, got)
+		}
+	}
+}
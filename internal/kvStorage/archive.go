@@ -0,0 +1,209 @@
+// Package kvStorage provides a simple key-value storage implementation using SQLite.
+package kvStorage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// archiveMagic identifies an archive and the version of its format.
+const archiveMagic = "DECONZ-HOMEKIT-ARCHIVE-1\n"
+
+const (
+	// saltSize is the size of the random salt for deriving a key from a passphrase
+	saltSize = 16
+
+	// keyIterations is the number of PBKDF2 iterations for deriving a key from a passphrase
+	keyIterations = 600000
+)
+
+// ErrStoreNotEmpty is returned by Import if the store already contains data and overwriting wasn't requested.
+var ErrStoreNotEmpty = errors.New("the storage already contains data")
+
+// Export writes all entries of a store as an encrypted archive.
+// The archive contains the HomeKit pairings and the deCONZ API key, so it is encrypted with
+// AES-256-GCM using a key derived from the passphrase. It can be restored with Import,
+// e.g. to move the bridge to a new host without pairing it again.
+//
+// Parameters:
+//   - store: The store to export
+//   - w: The writer to write the archive to
+//   - passphrase: The passphrase to encrypt the archive with
+//
+// Returns:
+//   - error: Any error encountered while reading the store or writing the archive
+func Export(store Store, w io.Writer, passphrase string) error {
+	if len(passphrase) == 0 {
+		return errors.New("no passphrase for the archive")
+	}
+
+	// An empty suffix matches all keys
+	keys, err := store.KeysWithSuffix("")
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if entries[key], err = store.Get(key); err != nil {
+			return fmt.Errorf("could not read %s: %w", key, err)
+		}
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// The archive consists of the magic, the salt, the nonce and the encrypted entries
+	salt := make([]byte, saltSize)
+	if _, err = rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+
+	archive := bytes.NewBufferString(archiveMagic)
+	archive.Write(salt)
+	archive.Write(nonce)
+	archive.Write(aead.Seal(nil, nonce, plaintext, []byte(archiveMagic)))
+
+	_, err = archive.WriteTo(w)
+	return err
+}
+
+// Import restores the entries of an archive created by Export.
+// A store which already contains data (e.g. the pairings of another bridge) is only overwritten
+// if force is set. The whole archive is decrypted and validated before the store is changed,
+// then the entries of the archive are written and only afterwards the existing entries which are
+// not part of the archive are removed. If writing fails, the store keeps the existing pairings
+// instead of ending up empty.
+//
+// Parameters:
+//   - store: The store to restore the entries to
+//   - r: The reader to read the archive from
+//   - passphrase: The passphrase the archive was encrypted with
+//   - force: Whether existing data may be overwritten
+//
+// Returns:
+//   - int: The number of restored entries
+//   - error: ErrStoreNotEmpty, an error if the archive is invalid or the passphrase is wrong,
+//     or any error encountered while writing the store
+func Import(store Store, r io.Reader, passphrase string, force bool) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check the format before decrypting, so that other files are reported clearly
+	header := len(archiveMagic) + saltSize
+	if len(data) < header || string(data[:len(archiveMagic)]) != archiveMagic {
+		return 0, errors.New("not an archive of the bridge")
+	}
+	aead, err := passphraseCipher(passphrase, data[len(archiveMagic):header])
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < header+aead.NonceSize() {
+		return 0, errors.New("the archive is truncated")
+	}
+	nonce := data[header : header+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, data[header+aead.NonceSize():], []byte(archiveMagic))
+	if err != nil {
+		return 0, errors.New("wrong passphrase or damaged archive")
+	}
+
+	entries := make(map[string][]byte)
+	if err = json.Unmarshal(plaintext, &entries); err != nil {
+		return 0, fmt.Errorf("invalid archive: %w", err)
+	}
+	if err = validateEntries(entries); err != nil {
+		return 0, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	// Don't mix the archive with existing pairings unless requested
+	existing, err := store.KeysWithSuffix("")
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 && !force {
+		return 0, ErrStoreNotEmpty
+	}
+
+	for key, value := range entries {
+		if err = store.Set(key, value); err != nil {
+			return 0, fmt.Errorf("could not restore %s: %w", key, err)
+		}
+	}
+
+	// Remove the entries of the previous data, which would otherwise be mixed with the archive
+	for _, key := range existing {
+		if _, ok := entries[key]; ok {
+			continue
+		}
+		if err = store.Delete(key); err != nil {
+			return 0, fmt.Errorf("could not remove %s: %w", key, err)
+		}
+	}
+
+	return len(entries), nil
+}
+
+// validateEntries checks the entries of an archive before they are restored.
+// An archive without entries is rejected, because restoring it with force would
+// only remove the existing data.
+//
+// Parameters:
+//   - entries: The decrypted entries of the archive
+//
+// Returns:
+//   - error: An error describing the first invalid entry, or nil if all entries are valid
+func validateEntries(entries map[string][]byte) error {
+	if len(entries) == 0 {
+		return errors.New("no entries")
+	}
+	for key, value := range entries {
+		if len(key) == 0 {
+			return errors.New("entry without a key")
+		}
+		if value == nil {
+			return fmt.Errorf("no value for %s", key)
+		}
+	}
+	return nil
+}
+
+// passphraseCipher creates an AES-256-GCM cipher with a key derived from a passphrase,
+// e.g. for an archive or the encrypted values of the storage.
+//
+// Parameters:
+//   - passphrase: The passphrase to derive the key from
+//   - salt: The random salt stored with the encrypted data
+//
+// Returns:
+//   - cipher.AEAD: The AES-256-GCM cipher
+//   - error: Any error encountered while deriving the key
+func passphraseCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, keyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,178 @@
+package kvStorage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sealArchive encrypts raw entries like Export, e.g. for archives Export wouldn't create.
+func sealArchive(t *testing.T, passphrase string, plaintext string) []byte {
+	t.Helper()
+
+	salt := make([]byte, saltSize)
+	_, _ = rand.Read(salt)
+	aead, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+
+	archive := bytes.NewBufferString(archiveMagic)
+	archive.Write(salt)
+	archive.Write(nonce)
+	archive.Write(aead.Seal(nil, nonce, []byte(plaintext), []byte(archiveMagic)))
+	return archive.Bytes()
+}
+
+// memoryWith creates an in-memory store with the given entries.
+func memoryWith(t *testing.T, entries map[string]string) *MemoryStorage {
+	t.Helper()
+
+	store := NewMemory()
+	for key, value := range entries {
+		if err := store.Set(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// contents returns all entries of a store.
+func contents(t *testing.T, store Store) map[string]string {
+	t.Helper()
+
+	keys, err := store.KeysWithSuffix("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, err := store.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[key] = string(value)
+	}
+	return result
+}
+
+// failingStore fails to write a single key.
+type failingStore struct {
+	*MemoryStorage
+
+	// key is the key which can't be written
+	key string
+}
+
+func (s failingStore) Set(key string, value []byte) error {
+	if key == s.key {
+		return errors.New("disk full")
+	}
+	return s.MemoryStorage.Set(key, value)
+}
+
+func TestExportImport(t *testing.T) {
+	entries := map[string]string{"keypair": "secret", "AA:BB.pairing": "controller", "deconz.apikey": "0123456789"}
+
+	archive := new(bytes.Buffer)
+	if err := Export(memoryWith(t, entries), archive, "passphrase"); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	restored := NewMemory()
+	count, err := Import(restored, bytes.NewReader(archive.Bytes()), "passphrase", false)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if count != len(entries) || !maps.Equal(contents(t, restored), entries) {
+		t.Errorf("Import() restored %d entries %v, want %v", count, contents(t, restored), entries)
+	}
+
+	if _, err = Import(NewMemory(), bytes.NewReader(archive.Bytes()), "wrong", false); err == nil {
+		t.Error("Import() with a wrong passphrase error = nil")
+	}
+}
+
+func TestImportIntoPopulatedStore(t *testing.T) {
+	archive := new(bytes.Buffer)
+	if err := Export(memoryWith(t, map[string]string{"keypair": "new", "AA:BB.pairing": "new controller"}), archive, "passphrase"); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	existing := map[string]string{"keypair": "old", "CC:DD.pairing": "old controller"}
+
+	// Existing pairings are kept unless overwriting was requested
+	store := memoryWith(t, existing)
+	if _, err := Import(store, bytes.NewReader(archive.Bytes()), "passphrase", false); !errors.Is(err, ErrStoreNotEmpty) {
+		t.Fatalf("Import() error = %v, want ErrStoreNotEmpty", err)
+	}
+	if !maps.Equal(contents(t, store), existing) {
+		t.Errorf("store after a refused import = %v, want %v", contents(t, store), existing)
+	}
+
+	// With force, the store contains exactly the archive
+	if _, err := Import(store, bytes.NewReader(archive.Bytes()), "passphrase", true); err != nil {
+		t.Fatalf("Import() with force error = %v", err)
+	}
+	want := map[string]string{"keypair": "new", "AA:BB.pairing": "new controller"}
+	if !maps.Equal(contents(t, store), want) {
+		t.Errorf("store after a forced import = %v, want %v", contents(t, store), want)
+	}
+}
+
+func TestImportKeepsStoreOnErrors(t *testing.T) {
+	existing := map[string]string{"keypair": "old", "CC:DD.pairing": "old controller"}
+	valid := new(bytes.Buffer)
+	if err := Export(memoryWith(t, map[string]string{"keypair": "new", "AA:BB.pairing": "new controller"}), valid, "passphrase"); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	damaged := slices.Clone(valid.Bytes())
+	damaged[len(damaged)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		archive []byte
+		store   func() Store
+		wantErr string
+	}{
+		{"not an archive", []byte("keypair=secret"), nil, "not an archive"},
+		{"truncated", valid.Bytes()[:len(archiveMagic)+saltSize+4], nil, "truncated"},
+		{"damaged", damaged, nil, "damaged archive"},
+		{"invalid entries", sealArchive(t, "passphrase", `["keypair"]`), nil, "invalid archive"},
+		{"no entries", sealArchive(t, "passphrase", `{}`), nil, "no entries"},
+		{"empty key", sealArchive(t, "passphrase", `{"": "AQ=="}`), nil, "without a key"},
+		{"missing value", sealArchive(t, "passphrase", `{"keypair": "bmV3", "uuid": null}`), nil, "no value for uuid"},
+		{
+			// The existing entries are only removed after all entries have been written
+			name:    "failed write",
+			archive: valid.Bytes(),
+			store: func() Store {
+				return failingStore{MemoryStorage: memoryWith(t, existing), key: "AA:BB.pairing"}
+			},
+			wantErr: "disk full",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var store Store = memoryWith(t, existing)
+			if tt.store != nil {
+				store = tt.store()
+			}
+
+			_, err := Import(store, bytes.NewReader(tt.archive), "passphrase", true)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Import() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if got := contents(t, store); got["CC:DD.pairing"] != "old controller" {
+				t.Errorf("store after a failed import = %v, want the existing pairing", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Package kvStorage provides a simple key-value storage implementation using SQLite.
+package kvStorage
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// encryptedMarker prefixes encrypted values, so they can be told apart from plaintext values.
+// Plaintext values never start with a zero byte, as all sensitive values are text or JSON.
+const encryptedMarker = "\x00enc1"
+
+// encryptionSaltKey is the key of the random salt the encryption key is derived with.
+// It is stored in the underlying store, but hidden from the keys of the encrypted storage,
+// so that it is neither exported nor removed by an import or a reset.
+const encryptionSaltKey = "encryption_salt"
+
+// ErrEncrypted is returned if a value is encrypted, but no encryption key is configured.
+var ErrEncrypted = errors.New("the value is encrypted, but no encryption key is configured")
+
+// isSensitiveKey reports whether the value of a key is a secret which is encrypted at rest.
+// These are the deCONZ API key, the long-term key pair of the bridge and the pairings of the controllers.
+//
+// Parameters:
+//   - key: The key of the value
+//
+// Returns:
+//   - bool: True if the value is encrypted if an encryption key is configured
+func isSensitiveKey(key string) bool {
+	return key == "deconz_api_key" || key == "keypair" || strings.HasSuffix(key, ".pairing")
+}
+
+// EncryptedStorage encrypts the sensitive values of another store with AES-256-GCM.
+// Each value is encrypted with a random nonce, which is stored in front of the ciphertext.
+// Without an encryption key, values are stored as plaintext, but encrypted values are still
+// detected, so that a missing key is reported instead of returning unreadable data.
+type EncryptedStorage struct {
+	// store is the underlying store
+	store Store
+
+	// aead is the cipher for the sensitive values (nil if no encryption key is configured)
+	aead cipher.AEAD
+}
+
+// Ensure that the encrypted storage implements the Store interface
+var _ Store = (*EncryptedStorage)(nil)
+
+// NewEncrypted creates a new EncryptedStorage on top of another store.
+// The 256-bit encryption key is derived from the secret with PBKDF2 like the key of an archive.
+// The random salt is created on the first start with a secret and kept in the store.
+//
+// Parameters:
+//   - store: The store the values are written to
+//   - secret: The secret to derive the encryption key from (empty to store plaintext values)
+//
+// Returns:
+//   - *EncryptedStorage: A pointer to the initialized EncryptedStorage
+//   - error: Any error encountered while reading or creating the salt or creating the cipher
+func NewEncrypted(store Store, secret string) (*EncryptedStorage, error) {
+	s := &EncryptedStorage{store: store}
+	if len(secret) == 0 {
+		return s, nil
+	}
+
+	salt, err := store.Get(encryptionSaltKey)
+	if errors.Is(err, ErrNotFound) {
+		salt = make([]byte, saltSize)
+		if _, err = rand.Read(salt); err != nil {
+			return nil, err
+		}
+		err = store.Set(encryptionSaltKey, salt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read or create the salt: %w", err)
+	}
+	if len(salt) != saltSize {
+		return nil, errors.New("the stored salt is invalid")
+	}
+
+	if s.aead, err = passphraseCipher(secret, salt); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Prepare checks that all sensitive values can be read and encrypts the values which
+// are still stored as plaintext, e.g. after the encryption key was configured for the first time.
+// It should be called on startup, so that a missing or wrong key is reported clearly.
+//
+// Returns:
+//   - error: An error if a value is encrypted but no or a different key is configured
+func (s *EncryptedStorage) Prepare() error {
+	keys, err := s.store.KeysWithSuffix("")
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if !isSensitiveKey(key) {
+			continue
+		}
+
+		raw, err := s.store.Get(key)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(raw, []byte(encryptedMarker)) {
+			if _, err = s.decrypt(key, raw); err != nil {
+				return fmt.Errorf("could not read %s: %w", key, err)
+			}
+			continue
+		}
+
+		// Encrypt the plaintext value if a key is configured
+		if s.aead != nil {
+			if err = s.Set(key, raw); err != nil {
+				return fmt.Errorf("could not encrypt %s: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Set stores a value for the given key, sensitive values are encrypted if a key is configured.
+//
+// Parameters:
+//   - key: The key to store the value under
+//   - value: The binary data to store
+//
+// Returns:
+//   - error: An error if the value could not be encrypted or stored
+func (s *EncryptedStorage) Set(key string, value []byte) error {
+	if s.aead == nil || !isSensitiveKey(key) {
+		return s.store.Set(key, value)
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// The key is authenticated as well, so that encrypted values can't be swapped
+	encrypted := append([]byte(encryptedMarker), nonce...)
+	encrypted = s.aead.Seal(encrypted, nonce, value, []byte(key))
+	return s.store.Set(key, encrypted)
+}
+
+// Get retrieves the value for the given key and decrypts it if it is encrypted.
+//
+// Parameters:
+//   - key: The key to retrieve the value for
+//
+// Returns:
+//   - []byte: The binary data stored for the key
+//   - error: ErrNotFound if the key doesn't exist, ErrEncrypted if no key is configured
+//     for an encrypted value, or an error if the value could not be decrypted
+func (s *EncryptedStorage) Get(key string) ([]byte, error) {
+	raw, err := s.store.Get(key)
+	if err != nil || !bytes.HasPrefix(raw, []byte(encryptedMarker)) {
+		return raw, err
+	}
+	return s.decrypt(key, raw)
+}
+
+// decrypt decrypts an encrypted value.
+//
+// Parameters:
+//   - key: The key of the value
+//   - raw: The stored value including the marker and the nonce
+//
+// Returns:
+//   - []byte: The decrypted value
+//   - error: ErrEncrypted if no key is configured, or an error if the value could not be decrypted
+func (s *EncryptedStorage) decrypt(key string, raw []byte) ([]byte, error) {
+	if s.aead == nil {
+		return nil, ErrEncrypted
+	}
+
+	raw = raw[len(encryptedMarker):]
+	if len(raw) < s.aead.NonceSize() {
+		return nil, errors.New("the encrypted value is truncated")
+	}
+	value, err := s.aead.Open(nil, raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, errors.New("the value could not be decrypted, the encryption key may be wrong")
+	}
+	return value, nil
+}
+
+// Delete removes the value for the given key.
+//
+// Parameters:
+//   - key: The key to remove
+//
+// Returns:
+//   - error: An error if the value could not be removed
+func (s *EncryptedStorage) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// KeysWithSuffix returns a list of keys that end with the given suffix.
+// The salt of the encryption key is not included.
+//
+// Parameters:
+//   - suffix: The suffix to search for
+//
+// Returns:
+//   - []string: A list of keys that end with the given suffix
+//   - error: An error if the keys could not be retrieved
+func (s *EncryptedStorage) KeysWithSuffix(suffix string) ([]string, error) {
+	keys, err := s.store.KeysWithSuffix(suffix)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(keys, func(key string) bool { return key == encryptionSaltKey }), nil
+}
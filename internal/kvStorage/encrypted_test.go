@@ -0,0 +1,176 @@
+package kvStorage
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newEncrypted creates an encrypted storage on top of a store and fails the test on errors.
+func newEncrypted(t *testing.T, store Store, secret string) *EncryptedStorage {
+	t.Helper()
+
+	s, err := NewEncrypted(store, secret)
+	if err != nil {
+		t.Fatalf("NewEncrypted() error = %v", err)
+	}
+	return s
+}
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	store := NewMemory()
+	s := newEncrypted(t, store, "secret")
+
+	if err := s.Set("deconz_api_key", []byte("0123456789")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set("uuid", []byte("AA:BB:CC:DD:EE:FF")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Sensitive values are encrypted at rest, other values are stored as they are
+	raw, _ := store.Get("deconz_api_key")
+	if !bytes.HasPrefix(raw, []byte(encryptedMarker)) || bytes.Contains(raw, []byte("0123456789")) {
+		t.Errorf("stored API key = %q, want an encrypted value", raw)
+	}
+	if raw, _ = store.Get("uuid"); string(raw) != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("stored uuid = %q, want the plaintext value", raw)
+	}
+
+	if value, err := s.Get("deconz_api_key"); err != nil || string(value) != "0123456789" {
+		t.Errorf("Get(deconz_api_key) = %q, %v, want %q", value, err, "0123456789")
+	}
+
+	// The values can be read after a restart, as the salt is kept in the store
+	s = newEncrypted(t, store, "secret")
+	if value, err := s.Get("deconz_api_key"); err != nil || string(value) != "0123456789" {
+		t.Errorf("Get(deconz_api_key) after a restart = %q, %v, want %q", value, err, "0123456789")
+	}
+
+	// The salt isn't listed, so it is neither exported nor removed
+	keys, err := s.KeysWithSuffix("")
+	if err != nil {
+		t.Fatalf("KeysWithSuffix() error = %v", err)
+	}
+	if slices.Contains(keys, encryptionSaltKey) {
+		t.Errorf("KeysWithSuffix() = %v, want the keys without the salt", keys)
+	}
+}
+
+func TestEncryptedStorageSalt(t *testing.T) {
+	// The same secret results in a different key for every store
+	first, second := NewMemory(), NewMemory()
+	_ = newEncrypted(t, first, "secret").Set("keypair", []byte("{}"))
+	_ = newEncrypted(t, second, "secret")
+
+	raw, _ := first.Get("keypair")
+	_ = second.Set("keypair", raw)
+	if _, err := newEncrypted(t, second, "secret").Get("keypair"); err == nil {
+		t.Error("Get() of a value encrypted with the salt of another store error = nil, want an error")
+	}
+
+	// A damaged salt is reported instead of deriving another key
+	_ = first.Set(encryptionSaltKey, []byte("short"))
+	if _, err := NewEncrypted(first, "secret"); err == nil {
+		t.Error("NewEncrypted() with an invalid salt error = nil, want an error")
+	}
+
+	// Without a secret, no salt is created
+	plain := NewMemory()
+	_ = newEncrypted(t, plain, "")
+	if _, err := plain.Get(encryptionSaltKey); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(salt) without a secret error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEncryptedStoragePrepareEncryptsPlaintext(t *testing.T) {
+	store := memoryWith(t, map[string]string{
+		"deconz_api_key": "0123456789",
+		"keypair":        `{"public":"a","private":"b"}`,
+		"AA:BB.pairing":  `{"name":"AA:BB"}`,
+		"uuid":           "AA:BB:CC:DD:EE:FF",
+	})
+	s := newEncrypted(t, store, "secret")
+
+	if err := s.Prepare(); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	for _, key := range []string{"deconz_api_key", "keypair", "AA:BB.pairing"} {
+		if raw, _ := store.Get(key); !bytes.HasPrefix(raw, []byte(encryptedMarker)) {
+			t.Errorf("stored %s = %q, want an encrypted value", key, raw)
+		}
+	}
+	if raw, _ := store.Get("uuid"); string(raw) != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("stored uuid = %q, want the plaintext value", raw)
+	}
+	if value, err := s.Get("AA:BB.pairing"); err != nil || string(value) != `{"name":"AA:BB"}` {
+		t.Errorf("Get(AA:BB.pairing) = %q, %v", value, err)
+	}
+
+	// Preparing again keeps the encrypted values readable
+	if err := s.Prepare(); err != nil {
+		t.Fatalf("second Prepare() error = %v", err)
+	}
+	if value, err := s.Get("deconz_api_key"); err != nil || string(value) != "0123456789" {
+		t.Errorf("Get(deconz_api_key) after the second Prepare() = %q, %v", value, err)
+	}
+}
+
+func TestEncryptedStorageMarker(t *testing.T) {
+	store := memoryWith(t, map[string]string{
+		// Plaintext values are returned as they are
+		"deconz_api_key": "enc1-but-not-encrypted",
+		// A value with the marker is always treated as encrypted
+		"keypair": encryptedMarker + "short",
+	})
+	s := newEncrypted(t, store, "secret")
+
+	if value, err := s.Get("deconz_api_key"); err != nil || string(value) != "enc1-but-not-encrypted" {
+		t.Errorf("Get(deconz_api_key) = %q, %v, want the plaintext value", value, err)
+	}
+	if _, err := s.Get("keypair"); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Get(keypair) error = %v, want a truncated value", err)
+	}
+	if err := s.Prepare(); err == nil {
+		t.Error("Prepare() with a truncated value error = nil, want an error")
+	}
+}
+
+func TestEncryptedStorageWrongKey(t *testing.T) {
+	store := NewMemory()
+	if err := newEncrypted(t, store, "secret").Set("deconz_api_key", []byte("0123456789")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	s := newEncrypted(t, store, "other secret")
+	if value, err := s.Get("deconz_api_key"); err == nil || value != nil {
+		t.Errorf("Get() with a wrong key = %q, %v, want an error", value, err)
+	}
+	if err := s.Prepare(); err == nil || !strings.Contains(err.Error(), "deconz_api_key") {
+		t.Errorf("Prepare() with a wrong key error = %v, want an error for deconz_api_key", err)
+	}
+}
+
+func TestEncryptedStorageMissingKey(t *testing.T) {
+	store := NewMemory()
+	if err := newEncrypted(t, store, "secret").Set("keypair", []byte("{}")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	s := newEncrypted(t, store, "")
+	if _, err := s.Get("keypair"); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Get() without a key error = %v, want ErrEncrypted", err)
+	}
+	if err := s.Prepare(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Prepare() without a key error = %v, want ErrEncrypted", err)
+	}
+
+	// Non-sensitive values are still readable
+	_ = store.Set("uuid", []byte("AA:BB:CC:DD:EE:FF"))
+	if value, err := s.Get("uuid"); err != nil || string(value) != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("Get(uuid) without a key = %q, %v", value, err)
+	}
+}
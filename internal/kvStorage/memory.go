@@ -0,0 +1,112 @@
+// Package kvStorage provides a simple key-value storage implementation using SQLite.
+package kvStorage
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryStorage represents a key-value storage which is kept in memory only.
+// It implements the Store interface and is used for ephemeral runs, where the
+// HomeKit pairing information is discarded when the application exits.
+type MemoryStorage struct {
+	// data contains the stored values by key
+	data map[string][]byte
+
+	// mu guards concurrent access to data
+	mu sync.RWMutex
+}
+
+// Ensure that the in-memory storage implements the Store interface
+var _ Store = (*MemoryStorage)(nil)
+
+// NewMemory creates a new, empty MemoryStorage instance.
+//
+// Returns:
+//   - *MemoryStorage: A pointer to the initialized MemoryStorage
+func NewMemory() *MemoryStorage {
+	return &MemoryStorage{
+		data: make(map[string][]byte),
+	}
+}
+
+// Set stores a value for the given key.
+// If the key already exists, its value will be updated.
+//
+// Parameters:
+//   - key: The key to store the value under
+//   - value: The binary data to store
+//
+// Returns:
+//   - error: Always nil
+func (s *MemoryStorage) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Store a copy, so that the caller can reuse the slice
+	s.data[key] = slices.Clone(value)
+	return nil
+}
+
+// Get retrieves the value for the given key.
+// If the key doesn't exist, it returns nil and ErrNotFound.
+//
+// Parameters:
+//   - key: The key to retrieve the value for
+//
+// Returns:
+//   - []byte: The stored binary data, or nil if the key doesn't exist
+//   - error: ErrNotFound if the key doesn't exist
+func (s *MemoryStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Return a copy, so that the caller can't modify the stored value
+	return slices.Clone(value), nil
+}
+
+// Delete removes the value for the given key.
+// If the key doesn't exist, this is a no-op.
+//
+// Parameters:
+//   - key: The key to delete the value for
+//
+// Returns:
+//   - error: Always nil
+func (s *MemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, key)
+	return nil
+}
+
+// KeysWithSuffix returns a list of keys that end with the given suffix.
+// This is used by the HAP library to find all keys related to a specific accessory.
+//
+// Parameters:
+//   - suffix: The suffix to search for
+//
+// Returns:
+//   - []string: A slice of keys that end with the given suffix
+//   - error: Always nil
+func (s *MemoryStorage) KeysWithSuffix(suffix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Collect all matching keys into a slice
+	var keys []string
+	for key := range s.data {
+		if strings.HasSuffix(key, suffix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,117 @@
+package kvStorage
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// stores returns a fresh instance of every Store implementation, so that the
+// in-memory storage is tested against the same contract as the SQLite storage.
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	sqlite, err := New(filepath.Join(t.TempDir(), "db", "test.db"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = sqlite.conn.Close() })
+
+	return map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": sqlite,
+	}
+}
+
+func TestStoreGetSetDelete(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Missing keys return nil and ErrNotFound
+			value, err := store.Get("missing")
+			if value != nil || !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(missing) = %q, %v, want nil, ErrNotFound", value, err)
+			}
+
+			if err = store.Set("key", []byte("first")); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if err = store.Set("key", []byte("second")); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if value, err = store.Get("key"); err != nil || string(value) != "second" {
+				t.Fatalf("Get(key) = %q, %v, want %q", value, err, "second")
+			}
+
+			if err = store.Delete("key"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if _, err = store.Get("key"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(key) after Delete() error = %v, want ErrNotFound", err)
+			}
+
+			// Deleting a missing key is a no-op
+			if err = store.Delete("key"); err != nil {
+				t.Fatalf("Delete() of a missing key error = %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreKeysWithSuffix(t *testing.T) {
+	keys := []string{"keypair", "uuid", "AA:BB.pairing", "CC:DD.pairing", "pairing.backup",
+		"EE:FF.PAIRING", "a_b", "axb", "a%b", "a%%b"}
+
+	tests := []struct {
+		name   string
+		suffix string
+		want   []string
+	}{
+		{"pairings", ".pairing", []string{"AA:BB.pairing", "CC:DD.pairing"}},
+		{"exact key", "uuid", []string{"uuid"}},
+		{"empty suffix matches all keys", "", keys},
+		{"no match", ".missing", nil},
+		{"case-sensitive", ".PAIRING", []string{"EE:FF.PAIRING"}},
+		{"underscore is no wildcard", "_b", []string{"a_b"}},
+		{"percent is no wildcard", "%b", []string{"a%b", "a%%b"}},
+		{"double percent", "%%b", []string{"a%%b"}},
+		{"longer than the keys", "prefix.keypair", nil},
+	}
+
+	for name, store := range stores(t) {
+		for _, key := range keys {
+			if err := store.Set(key, []byte(key)); err != nil {
+				t.Fatalf("%s: Set(%s) error = %v", name, key, err)
+			}
+		}
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				got, err := store.KeysWithSuffix(tt.suffix)
+				if err != nil {
+					t.Fatalf("KeysWithSuffix(%q) error = %v", tt.suffix, err)
+				}
+				slices.Sort(got)
+				want := slices.Sorted(slices.Values(tt.want))
+				if !slices.Equal(got, want) {
+					t.Errorf("KeysWithSuffix(%q) = %v, want %v", tt.suffix, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryStorageCopiesValues(t *testing.T) {
+	store := NewMemory()
+
+	value := []byte("value")
+	_ = store.Set("key", value)
+	value[0] = 'X'
+
+	got, _ := store.Get("key")
+	got[1] = 'X'
+
+	if got, _ = store.Get("key"); string(got) != "value" {
+		t.Errorf("Get() = %q, want %q", got, "value")
+	}
+}
@@ -6,12 +6,25 @@ package kvStorage
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	// Import SQLite driver
-	_ "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/go-sqlite"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockedRetries is the number of attempts for a write if the database is locked
+	lockedRetries = 5
+
+	// lockedRetryDelay is the delay between two attempts, multiplied by the attempt number
+	lockedRetryDelay = 100 * time.Millisecond
 )
 
 // Storage represents a key-value storage backed by SQLite.
-// It provides methods for storing, retrieving, and deleting binary data
+// It implements the Store interface and provides methods for storing, retrieving, and deleting binary data
 // associated with string keys.
 type Storage struct {
 	// conn is the database connection to the SQLite database
@@ -19,7 +32,7 @@ type Storage struct {
 }
 
 // New creates a new Storage instance with the specified database file.
-// If the database file doesn't exist, it will be created.
+// If the database file or its directory doesn't exist, it will be created.
 // If the kv_store table doesn't exist, it will be created.
 //
 // Parameters:
@@ -29,8 +42,22 @@ type Storage struct {
 //   - *Storage: A pointer to the initialized Storage
 //   - error: An error if the database could not be opened or the table could not be created
 func New(path string) (*Storage, error) {
+	// Create the directory of the database if it is missing, e.g. in a fresh container volume
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create the storage directory %s: %w", dir, err)
+	}
+
+	// Fail early if the directory isn't writable, SQLite only reports it with an unclear error
+	if err := checkWritable(dir); err != nil {
+		return nil, fmt.Errorf("the storage directory %s is not writable: %w", dir, err)
+	}
+
 	// Open the SQLite database
-	db, err := sql.Open("sqlite", path)
+	// The pragmas are applied to every connection of the pool: the write-ahead log allows
+	// concurrent reads while writing, and the busy timeout lets SQLite wait for a lock
+	// instead of failing immediately with "database is locked"
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -38,12 +65,29 @@ func New(path string) (*Storage, error) {
 	// Create the kv_store table if it doesn't exist
 	// The table has two columns: key (TEXT, primary key) and value (BLOB)
 	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS kv_store (key TEXT PRIMARY KEY, value BLOB);"); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not open the database %s: %w", path, err)
 	}
 
 	return &Storage{db}, nil
 }
 
+// checkWritable checks that files can be created in a directory by creating and removing a temporary file.
+// The database and its write-ahead log are created next to each other, so the directory must be writable.
+//
+// Parameters:
+//   - dir: The directory to check
+//
+// Returns:
+//   - error: An error if no file could be created in the directory
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return err
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
+
 // Set stores a value for the given key.
 // If the key already exists, its value will be updated.
 //
@@ -55,23 +99,25 @@ func New(path string) (*Storage, error) {
 //   - error: An error if the value could not be stored
 func (s *Storage) Set(key string, value []byte) error {
 	// Insert a new row or update an existing one if the key already exists
-	_, err := s.conn.Exec(`INSERT INTO kv_store(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value=excluded.value;`, key, value)
-	return err
+	return s.execWithRetry(`INSERT INTO kv_store(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value=excluded.value;`, key, value)
 }
 
 // Get retrieves the value for the given key.
-// If the key doesn't exist, it returns nil without an error.
+// If the key doesn't exist, it returns nil and ErrNotFound.
 //
 // Parameters:
 //   - key: The key to retrieve the value for
 //
 // Returns:
 //   - []byte: The stored binary data, or nil if the key doesn't exist
-//   - error: An error if the value could not be retrieved
+//   - error: ErrNotFound if the key doesn't exist, or an error if the value could not be retrieved
 func (s *Storage) Get(key string) ([]byte, error) {
 	var val []byte
 	// Query the value for the given key
 	err := s.conn.QueryRow(`SELECT value FROM kv_store WHERE key = ?;`, key).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	return val, err
 }
 
@@ -85,8 +131,7 @@ func (s *Storage) Get(key string) ([]byte, error) {
 //   - error: An error if the value could not be deleted
 func (s *Storage) Delete(key string) error {
 	// Delete the row for the given key
-	_, err := s.conn.Exec(`DELETE FROM kv_store WHERE key = ?;`, key)
-	return err
+	return s.execWithRetry(`DELETE FROM kv_store WHERE key = ?;`, key)
 }
 
 // KeysWithSuffix returns a list of keys that end with the given suffix.
@@ -99,12 +144,9 @@ func (s *Storage) Delete(key string) error {
 //   - []string: A slice of keys that end with the given suffix
 //   - error: An error if the keys could not be retrieved
 func (s *Storage) KeysWithSuffix(suffix string) ([]string, error) {
-	// Create a LIKE parameter for the SQL query
-	// The % is a wildcard that matches any characters before the suffix
-	likeParam := "%" + suffix
-
-	// Query all keys that match the LIKE parameter
-	rows, err := s.conn.Query(`SELECT key FROM kv_store WHERE key LIKE ?;`, likeParam)
+	// Compare the end of the keys instead of using LIKE, which ignores the case and
+	// treats "_" and "%" in the suffix as wildcards. An empty suffix matches all keys.
+	rows, err := s.conn.Query(`SELECT key FROM kv_store WHERE ?1 = '' OR substr(key, -length(?1)) = ?1;`, suffix)
 	if err != nil {
 		return nil, err
 	}
@@ -121,3 +163,42 @@ func (s *Storage) KeysWithSuffix(suffix string) ([]string, error) {
 
 	return keys, nil
 }
+
+// execWithRetry executes a write statement and retries it if the database is locked.
+// This can happen if several HomeKit controllers connect at the same time.
+//
+// Parameters:
+//   - query: The SQL statement to execute
+//   - args: The arguments for the placeholders in the statement
+//
+// Returns:
+//   - error: An error if the statement could not be executed
+func (s *Storage) execWithRetry(query string, args ...any) error {
+	var err error
+	for attempt := 1; attempt <= lockedRetries; attempt++ {
+		if _, err = s.conn.Exec(query, args...); !isLocked(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * lockedRetryDelay)
+	}
+
+	return err
+}
+
+// isLocked reports whether an error was caused by a busy or locked database.
+//
+// Parameters:
+//   - err: The error to check
+//
+// Returns:
+//   - bool: True if the database was busy (SQLITE_BUSY) or locked (SQLITE_LOCKED)
+func isLocked(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	// The lower 8 bits contain the primary result code
+	code := sqliteErr.Code() & 0xff
+	return code == 5 || code == 6
+}
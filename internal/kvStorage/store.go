@@ -0,0 +1,28 @@
+// Package kvStorage provides a simple key-value storage implementation using SQLite.
+package kvStorage
+
+import "errors"
+
+// ErrNotFound is returned by Get if no value is stored for the given key.
+// The HAP library relies on an error for missing keys (e.g. on the first start).
+var ErrNotFound = errors.New("key not found")
+
+// Store is the interface of a key-value storage backend.
+// It is compatible with the storage interface required by the HomeKit Accessory Protocol (HAP)
+// library, so any implementation can be used to persist the HomeKit pairing information.
+type Store interface {
+	// Set stores a value for the given key
+	Set(key string, value []byte) error
+
+	// Get retrieves the value for the given key, or ErrNotFound if the key doesn't exist
+	Get(key string) ([]byte, error)
+
+	// Delete removes the value for the given key
+	Delete(key string) error
+
+	// KeysWithSuffix returns a list of keys that end with the given suffix
+	KeysWithSuffix(suffix string) ([]string, error)
+}
+
+// Ensure that the SQLite storage implements the Store interface
+var _ Store = (*Storage)(nil)
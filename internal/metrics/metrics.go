@@ -0,0 +1,233 @@
+// Package metrics provides Prometheus metrics for the bridge.
+// The metrics are collected only after Enable has been called, so the
+// instrumentation doesn't cause any overhead when metrics are disabled.
+// The metrics are exposed in the Prometheus text format by Handler.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// enabled indicates whether metrics are collected
+var enabled atomic.Bool
+
+// Escaping of the Prometheus text format, which only knows a few escape sequences
+var (
+	// helpEscaper escapes the text of a HELP line
+	helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+	// labelEscaper escapes a label value, which is enclosed in double quotes
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// Metrics exposed by the bridge
+var (
+	// events counts the WebSocket events processed by event type
+	events = newCounterVec("deconz_events_total", "WebSocket events received from the deCONZ gateway.", "type")
+
+	// commands counts the commands sent to the gateway by kind
+	commands = newCounterVec("deconz_commands_total", "Commands sent to the deCONZ gateway.", "kind")
+
+	// commandErrors counts the failed commands by kind
+	commandErrors = newCounterVec("deconz_command_errors_total", "Commands to the deCONZ gateway which failed.", "kind")
+
+	// requestDuration measures the latency of HTTP requests to the gateway by method
+	requestDuration = newHistogramVec("deconz_request_duration_seconds", "Latency of HTTP requests to the deCONZ gateway.", "method",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5})
+)
+
+// Enable starts collecting metrics.
+func Enable() {
+	enabled.Store(true)
+}
+
+// Enabled reports whether metrics are collected.
+//
+// Returns:
+//   - bool: True if metrics are enabled
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// CountEvent counts a WebSocket event received from the gateway.
+//
+// Parameters:
+//   - eventType: The type of the event (e.g. "changed")
+func CountEvent(eventType string) {
+	if enabled.Load() {
+		events.inc(eventType)
+	}
+}
+
+// CountCommand counts a command sent to the gateway and whether it failed.
+//
+// Parameters:
+//   - kind: The kind of the command (e.g. "on", "bri", "ct")
+//   - err: The error returned by the command, if any
+func CountCommand(kind string, err error) {
+	if enabled.Load() {
+		commands.inc(kind)
+		if err != nil {
+			commandErrors.inc(kind)
+		}
+	}
+}
+
+// ObserveRequest records the latency of an HTTP request to the gateway.
+// It is meant to be deferred at the start of the request.
+//
+// Parameters:
+//   - method: The HTTP method of the request
+//   - start: The time the request was started
+func ObserveRequest(method string, start time.Time) {
+	if enabled.Load() {
+		requestDuration.observe(method, time.Since(start).Seconds())
+	}
+}
+
+// Handler returns an HTTP handler which exposes all metrics in the Prometheus text format.
+//
+// Returns:
+//   - http.Handler: The metrics handler
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		events.write(w)
+		commands.write(w)
+		commandErrors.write(w)
+		requestDuration.write(w)
+	})
+}
+
+// counterVec is a set of counters partitioned by a single label.
+type counterVec struct {
+	name   string
+	help   string
+	label  string
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// newCounterVec creates a new counter vector.
+//
+// Parameters:
+//   - name: The name of the metric
+//   - help: The description of the metric
+//   - label: The name of the label
+//
+// Returns:
+//   - *counterVec: A pointer to the initialized counter vector
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]uint64)}
+}
+
+// inc increments the counter for a label value.
+//
+// Parameters:
+//   - value: The label value
+func (c *counterVec) inc(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[value]++
+}
+
+// write writes the counters in the Prometheus text format.
+//
+// Parameters:
+//   - w: The writer to write to
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, helpEscaper.Replace(c.help), c.name)
+	for _, value := range slices.Sorted(maps.Keys(c.values)) {
+		_, _ = fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label, labelEscaper.Replace(value), c.values[value])
+	}
+}
+
+// histogram holds the observations of a single label value.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// histogramVec is a set of histograms partitioned by a single label.
+type histogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+// newHistogramVec creates a new histogram vector.
+//
+// Parameters:
+//   - name: The name of the metric
+//   - help: The description of the metric
+//   - label: The name of the label
+//   - buckets: The upper bounds of the buckets in ascending order
+//
+// Returns:
+//   - *histogramVec: A pointer to the initialized histogram vector
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+// observe records an observation for a label value.
+//
+// Parameters:
+//   - value: The label value
+//   - v: The observed value
+func (h *histogramVec) observe(value string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[value]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[value] = hist
+	}
+
+	// Bucket counts are cumulative
+	for i, bound := range h.buckets {
+		if v <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+// write writes the histograms in the Prometheus text format.
+//
+// Parameters:
+//   - w: The writer to write to
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, helpEscaper.Replace(h.help), h.name)
+	for _, value := range slices.Sorted(maps.Keys(h.values)) {
+		hist := h.values[value]
+		label := fmt.Sprintf("%s=\"%s\"", h.label, labelEscaper.Replace(value))
+		for i, bound := range h.buckets {
+			le := strconv.FormatFloat(bound, 'f', -1, 64)
+			_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.name, label, le, hist.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", h.name, label, hist.count)
+		_, _ = fmt.Fprintf(w, "%s_sum{%s} %g\n", h.name, label, hist.sum)
+		_, _ = fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, label, hist.count)
+	}
+}
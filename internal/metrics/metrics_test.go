@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	c := newCounterVec("test_total", "A counter with a \\ and a\nsecond line.", "kind")
+	c.inc("on")
+	c.inc("on")
+	c.inc("bri")
+	c.inc("a \"quoted\" \\ value\nwith a newline")
+	// Other characters, such as tabs, are written as they are
+	c.inc("tab\tü")
+
+	var out strings.Builder
+	c.write(&out)
+
+	want := `# HELP test_total A counter with a \\ and a\nsecond line.
+# TYPE test_total counter
+test_total{kind="a \"quoted\" \\ value\nwith a newline"} 1
+test_total{kind="bri"} 1
+test_total{kind="on"} 2
+test_total{kind="tab` + "\t" + `ü"} 1
+`
+	if out.String() != want {
+		t.Errorf("write() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestCounterVecWriteEmpty(t *testing.T) {
+	var out strings.Builder
+	newCounterVec("test_total", "An empty counter.", "kind").write(&out)
+
+	want := "# HELP test_total An empty counter.\n# TYPE test_total counter\n"
+	if out.String() != want {
+		t.Errorf("write() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	h := newHistogramVec("test_seconds", "A histogram.", "method", []float64{0.1, 1})
+	h.observe("GET", 0.05)
+	h.observe("GET", 0.5)
+	h.observe("GET", 2)
+	h.observe("P\"UT", 0.1)
+
+	var out strings.Builder
+	h.write(&out)
+
+	want := `# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="GET",le="0.1"} 1
+test_seconds_bucket{method="GET",le="1"} 2
+test_seconds_bucket{method="GET",le="+Inf"} 3
+test_seconds_sum{method="GET"} 2.55
+test_seconds_count{method="GET"} 3
+test_seconds_bucket{method="P\"UT",le="0.1"} 1
+test_seconds_bucket{method="P\"UT",le="1"} 1
+test_seconds_bucket{method="P\"UT",le="+Inf"} 1
+test_seconds_sum{method="P\"UT"} 0.1
+test_seconds_count{method="P\"UT"} 1
+`
+	if out.String() != want {
+		t.Errorf("write() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	// The metrics are global, so only values recorded by this test are checked
+	CountEvent("handler-test")
+	Enable()
+	CountEvent("handler-test")
+	CountEvent("handler-test")
+	CountCommand("handler-test", nil)
+	CountCommand("handler-test", errors.New("failed"))
+	ObserveRequest("HANDLER-TEST", time.Now())
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", got)
+	}
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE deconz_events_total counter",
+		"# TYPE deconz_commands_total counter",
+		"# TYPE deconz_command_errors_total counter",
+		"# TYPE deconz_request_duration_seconds histogram",
+		// Events before Enable are not counted
+		`deconz_events_total{type="handler-test"} 2`,
+		`deconz_commands_total{kind="handler-test"} 2`,
+		`deconz_command_errors_total{kind="handler-test"} 1`,
+		`deconz_request_duration_seconds_count{method="HANDLER-TEST"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics don't contain %q:\n%s", line, body)
+		}
+	}
+}
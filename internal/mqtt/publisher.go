@@ -0,0 +1,161 @@
+// Package mqtt provides an optional MQTT integration for the bridge.
+// It publishes the state changes received from the deCONZ gateway as JSON,
+// so that they can be consumed by other tools (e.g. Node-RED) independent of HomeKit.
+package mqtt
+
+import (
+	"deconz-homekit/internal/deconz"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/log"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// DefaultTopicPrefix is the prefix of the topics if none is configured.
+const DefaultTopicPrefix = "deconz-homekit"
+
+// publishTimeout is the maximum time to wait for a message to be delivered to the broker
+const publishTimeout = 10 * time.Second
+
+// Options contains the settings for connecting to the MQTT broker.
+type Options struct {
+	// Broker is the URL of the MQTT broker (e.g. "tcp://192.168.1.2:1883")
+	Broker string
+
+	// Username is the username for authenticating with the broker (optional)
+	Username string
+
+	// Password is the password for authenticating with the broker (optional)
+	Password string
+
+	// TopicPrefix is the prefix of the topics the states are published to
+	TopicPrefix string
+
+	// QoS is the MQTT quality of service level (0, 1 or 2)
+	QoS byte
+}
+
+// StateMessage is the JSON document published for each state change.
+type StateMessage struct {
+	// UniqueID is the unique identifier of the device
+	UniqueID string `json:"uniqueid"`
+
+	// ID is the identifier of the resource in deCONZ
+	ID string `json:"id"`
+
+	// Type is the type of the resource ("lights" or "sensors")
+	Type deconz.RessourceType `json:"type"`
+
+	// State contains the changed state values
+	State *deconz.ObjectMap `json:"state"`
+}
+
+// Publisher publishes the state changes of deCONZ devices to an MQTT broker.
+type Publisher struct {
+	// client is the MQTT client connected to the broker
+	client paho.Client
+
+	// options contains the settings for publishing the states
+	options Options
+
+	// log is the logger for the publisher
+	log *log.Logger
+}
+
+// NewPublisher creates a new MQTT publisher and connects to the broker.
+// The connection is established in the background and re-established if it is lost,
+// so the bridge keeps working while the broker is unavailable.
+//
+// Parameters:
+//   - options: The settings for connecting to the broker
+//   - log: The logger for connection and publishing errors
+//
+// Returns:
+//   - *Publisher: A pointer to the initialized Publisher
+//   - error: An error if the options are invalid
+func NewPublisher(options Options, log *log.Logger) (*Publisher, error) {
+	if len(options.Broker) == 0 {
+		return nil, errors.New("no broker configured")
+	}
+	if options.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d", options.QoS)
+	}
+	if len(options.TopicPrefix) == 0 {
+		options.TopicPrefix = DefaultTopicPrefix
+	}
+	options.TopicPrefix = strings.TrimSuffix(options.TopicPrefix, "/")
+
+	p := &Publisher{
+		options: options,
+		log:     log,
+	}
+
+	clientOptions := paho.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(fmt.Sprintf("deconz-homekit-%08x", rand.Uint32())).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(paho.Client) {
+			log.Infof("connected to %s", options.Broker)
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warnf("connection lost: %v", err)
+		})
+
+	// With ConnectRetry, the connection is retried in the background until it succeeds
+	p.client = paho.NewClient(clientOptions)
+	p.client.Connect()
+
+	return p, nil
+}
+
+// ObserveUpdate publishes the state of an update message to the topic of the device.
+// Messages without a state or a unique ID (e.g. group events) are ignored.
+// It is subscribed to the update messages of the accessory manager.
+//
+// Parameters:
+//   - msg: A pointer to the update message from the deCONZ gateway
+func (p *Publisher) ObserveUpdate(msg *deconz.Messsage) {
+	if msg.EventType != deconz.ChangedEvent || msg.State == nil || msg.UniqueID == nil {
+		return
+	}
+
+	message := StateMessage{
+		UniqueID: *msg.UniqueID,
+		Type:     msg.RessourceType,
+		State:    msg.State,
+	}
+	if msg.RessourceID != nil {
+		message.ID = *msg.RessourceID
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		p.log.Errorf("failed to encode the state of %s: %v", message.UniqueID, err)
+		return
+	}
+
+	// The state is retained, so that new subscribers receive the last state immediately
+	topic := p.options.TopicPrefix + "/" + message.UniqueID + "/state"
+	token := p.client.Publish(topic, p.options.QoS, true, payload)
+
+	// Wait for the delivery in the background, so that the event processing isn't blocked
+	go func() {
+		if !token.WaitTimeout(publishTimeout) {
+			p.log.Warnf("publishing to %s timed out", topic)
+		} else if err := token.Error(); err != nil {
+			p.log.Warnf("failed to publish to %s: %v", topic, err)
+		}
+	}()
+}
+
+// Close disconnects from the broker after the pending messages have been sent.
+func (p *Publisher) Close() {
+	p.client.Disconnect(250)
+}
@@ -0,0 +1,147 @@
+// Package status provides a read-only HTTP server for monitoring the bridge.
+// It exposes a health check for container orchestrators, a JSON summary
+// of the bridged devices and the connection to the deCONZ gateway, and
+// Prometheus metrics if they are enabled.
+package status
+
+import (
+	"context"
+	"deconz-homekit/internal/accessoryManager"
+	"deconz-homekit/internal/deconz"
+	"deconz-homekit/internal/metrics"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Status is the JSON document returned by the /status endpoint.
+type Status struct {
+	// Devices is the number of devices exposed to HomeKit
+	Devices int `json:"devices"`
+
+	// Services is the number of services exposed to HomeKit
+	Services int `json:"services"`
+
+	// Groups is the number of groups exposed to HomeKit
+	Groups int `json:"groups"`
+
+	// WebsocketConnected indicates whether the event stream of the gateway is connected
+	WebsocketConnected bool `json:"websocketConnected"`
+
+	// LastEvent is the time the last event was received from the gateway
+	LastEvent *time.Time `json:"lastEvent"`
+
+	// UnsupportedDevices is a list of the devices which are not exposed to HomeKit
+	UnsupportedDevices []accessoryManager.UnsupportedDevice `json:"unsupportedDevices"`
+}
+
+// Server is a read-only HTTP server exposing the state of the bridge.
+type Server struct {
+	// am is the accessory manager holding the bridged devices
+	am *accessoryManager.AccessoryManager
+
+	// api is the deCONZ API client used to check whether the gateway is reachable
+	api *deconz.ApiClient
+
+	// events is the client of the deCONZ event stream
+	events *deconz.EventClient
+
+	// server is the underlying HTTP server
+	server *http.Server
+}
+
+// NewServer creates a new status server.
+//
+// Parameters:
+//   - addr: The address to listen on (e.g. ":8080")
+//   - am: A pointer to the accessory manager
+//   - api: A pointer to the deCONZ API client
+//   - events: A pointer to the deCONZ event client
+//
+// Returns:
+//   - *Server: A pointer to the initialized Server
+func NewServer(addr string, am *accessoryManager.AccessoryManager, api *deconz.ApiClient, events *deconz.EventClient) *Server {
+	s := &Server{
+		am:     am,
+		api:    api,
+		events: events,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	if metrics.Enabled() {
+		mux.Handle("GET /metrics", metrics.Handler())
+	}
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// ListenAndServe starts the HTTP server and blocks until the context is cancelled.
+//
+// Parameters:
+//   - ctx: Context for stopping the server
+//
+// Returns:
+//   - error: Any error encountered while serving, nil after a graceful shutdown
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.server.Shutdown(shutdownCtx)
+	}()
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleHealth responds with 200 if the event stream is connected and the gateway
+// is reachable, and with 503 otherwise.
+//
+// Parameters:
+//   - w: The response writer
+//   - r: The HTTP request
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !s.events.Connected() {
+		http.Error(w, "websocket disconnected", http.StatusServiceUnavailable)
+		return
+	}
+
+	if _, err := s.api.GetConfiguration(); err != nil {
+		http.Error(w, "gateway unreachable", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleStatus responds with a JSON summary of the bridge state.
+//
+// Parameters:
+//   - w: The response writer
+//   - r: The HTTP request
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := Status{
+		Devices:            len(s.am.Devices),
+		Services:           len(s.am.Services),
+		Groups:             len(s.am.Groups),
+		WebsocketConnected: s.events.Connected(),
+		UnsupportedDevices: s.am.UnsupportedDevices(),
+	}
+	if lastEvent := s.events.LastEvent(); !lastEvent.IsZero() {
+		status.LastEvent = &lastEvent
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
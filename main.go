@@ -5,18 +5,30 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"deconz-homekit/internal/accessoryManager"
 	"deconz-homekit/internal/client"
 	"deconz-homekit/internal/deconz"
+	deviceConfiguration "deconz-homekit/internal/device_configuration"
 	"deconz-homekit/internal/kvStorage"
+	"deconz-homekit/internal/metrics"
+	"deconz-homekit/internal/mqtt"
+	"deconz-homekit/internal/status"
+	"errors"
 	"fmt"
 	"github.com/brutella/hap"
 	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
 	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -29,6 +41,9 @@ import (
 // It initializes the bridge, connects to the deCONZ gateway,
 // retrieves device information, and starts the HomeKit server.
 func main() {
+	// Apply the command-line flags, which override the environment variables
+	parseFlags(os.Args[1:])
+
 	// Create a context that can be cancelled on system signals
 	ctx := DefaultContext()
 
@@ -37,6 +52,13 @@ func main() {
 		ReportTimestamp: true,
 		TimeFormat:      time.DateTime,
 	})
+	if LOG_LEVEL := os.Getenv("LOG_LEVEL"); len(LOG_LEVEL) > 0 {
+		level, err := log.ParseLevel(LOG_LEVEL)
+		if err != nil {
+			l.Fatalf("Invalid LOG_LEVEL: %v", err)
+		}
+		l.SetLevel(level)
+	}
 
 	l.Info("Starting bridge...")
 
@@ -45,84 +67,411 @@ func main() {
 	if len(STORAGE_PATH) == 0 {
 		STORAGE_PATH = "./"
 	}
-	storage, err := kvStorage.New(STORAGE_PATH + "db.sqlite")
+	var storage kvStorage.Store
+	var err error
+	switch STORAGE_BACKEND := os.Getenv("STORAGE_BACKEND"); STORAGE_BACKEND {
+	case "", "sqlite":
+		storage, err = kvStorage.New(STORAGE_PATH + "db.sqlite")
+		if err != nil {
+			l.Fatalf("Error connecting to the database: %v", err)
+		}
+	case "memory":
+		// Nothing is persisted, so HomeKit has to be paired again after every restart
+		l.Warn("Using in-memory storage. The bridge has to be paired again after every restart")
+		storage = kvStorage.NewMemory()
+	default:
+		l.Fatalf("Unknown storage backend: %s", STORAGE_BACKEND)
+	}
+
+	// Encrypt the API key and the HomeKit keys at rest if an encryption key is configured
+	encryptedStorage, err := kvStorage.NewEncrypted(storage, os.Getenv("STORAGE_ENCRYPTION_KEY"))
 	if err != nil {
-		l.Fatalf("Error connecting to the database: %v", err)
+		l.Fatalf("Invalid STORAGE_ENCRYPTION_KEY: %v", err)
+	}
+	if err = encryptedStorage.Prepare(); err != nil {
+		l.Fatalf("Could not read the storage, check STORAGE_ENCRYPTION_KEY: %v", err)
+	}
+	storage = encryptedStorage
+
+	// Remove the HomeKit pairings, but keep the API key, and exit
+	if os.Getenv("HOMEKIT_RESET") == "true" {
+		keys, err := resetHomeKit(storage, os.Getenv("STORAGE_FORCE") == "true", os.Stdin, os.Stderr)
+		if err != nil {
+			l.Fatalf("Could not reset HomeKit: %v", err)
+		}
+		if keys == nil {
+			l.Info("HomeKit reset cancelled")
+			return
+		}
+		l.Infof("HomeKit reset, %d keys removed. Remove the bridge from the Home app before pairing it again", len(keys))
+		return
+	}
+
+	// Export or import the stored pairings and API key and exit, e.g. to move the bridge to a new host
+	if STORAGE_EXPORT := os.Getenv("STORAGE_EXPORT"); len(STORAGE_EXPORT) > 0 {
+		if err = exportStorage(storage, STORAGE_EXPORT, os.Getenv("STORAGE_PASSPHRASE")); err != nil {
+			l.Fatalf("Could not export the storage: %v", err)
+		}
+		l.Infof("Storage exported to %s", STORAGE_EXPORT)
+		return
+	}
+	if STORAGE_IMPORT := os.Getenv("STORAGE_IMPORT"); len(STORAGE_IMPORT) > 0 {
+		count, err := importStorage(storage, STORAGE_IMPORT, os.Getenv("STORAGE_PASSPHRASE"), os.Getenv("STORAGE_FORCE") == "true")
+		if err != nil {
+			l.Fatalf("Could not import the storage: %v", err)
+		}
+		l.Infof("%d entries imported from %s", count, STORAGE_IMPORT)
+		return
 	}
 
-	// Get deCONZ gateway IP address from environment variables
+	// Get deCONZ gateway IP address and port from environment variables
 	var PHOSCON_IP = os.Getenv("DECONZ_IP")
+	var PHOSCON_PORT = os.Getenv("DECONZ_PORT")
+
+	// Search for the deCONZ gateway in the local network if no IP address is set
 	if len(PHOSCON_IP) == 0 {
-		l.Fatalf("Please provide the ip address of the deCONZ gateway (DECONZ_IP not set)")
+		l.Info("DECONZ_IP not set. Searching for a deCONZ gateway...")
+		host, port, err := deconz.Discover(ctx, 10*time.Second)
+		if err != nil {
+			l.Fatalf("Please provide the ip address of the deCONZ gateway (DECONZ_IP not set, discovery failed: %v)", err)
+		}
+		l.Infof("Discovered deCONZ gateway at %s:%d", host, port)
+
+		PHOSCON_IP = host
+		if len(PHOSCON_PORT) == 0 {
+			PHOSCON_PORT = strconv.Itoa(port)
+		}
 	}
 
-	// Get deCONZ gateway port from environment variables, default to 80 if not set
-	var PHOSCON_PORT = os.Getenv("DECONZ_PORT")
+	// Default to port 80 if not set
 	if len(PHOSCON_PORT) == 0 {
 		PHOSCON_PORT = "80"
 	}
 
 	// Retrieve or generate the deCONZ API key for authentication
-	apiKeyRaw, err := storage.Get("deconz_api_key")
-	if err != nil {
-		l.Fatalf("Error querying to the database: %v", err)
-	}
+	// The key is taken from the environment, the storage, or requested from the gateway (in this order)
+	var apiKeyRaw []byte
+	if API_KEY := os.Getenv("DECONZ_API_KEY"); len(API_KEY) > 0 {
+		l.Info("Using API key from environment variable DECONZ_API_KEY")
+		apiKeyRaw = []byte(API_KEY)
+
+		// Cache the API key in the storage, so that subsequent runs work without the environment variable
+		if err = storage.Set("deconz_api_key", apiKeyRaw); err != nil {
+			l.Warnf("Could not store API key: %v", err)
+		}
+	} else {
+		apiKeyRaw, err = storage.Get("deconz_api_key")
+		if err != nil && !errors.Is(err, kvStorage.ErrNotFound) {
+			l.Fatalf("Error querying to the database: %v", err)
+		}
 
-	// If no API key exists, request a new one from the deCONZ gateway
-	if apiKeyRaw == nil {
-		l.Infof("No API key found. Requesting a new one...")
+		if apiKeyRaw != nil {
+			l.Info("Using stored API key")
+		} else {
+			// If no API key exists, request a new one from the deCONZ gateway
+			l.Infof("No API key found. Requesting a new one...")
 
-		// Request a new API key from the deCONZ gateway
-		apiKeyRaw, err = getApiKey(l, fmt.Sprintf("http://%s:%s", PHOSCON_IP, PHOSCON_PORT))
-		if err != nil {
-			l.Fatalf("Could not obtain API key: %v", err)
+			// Get the maximum duration to wait for the link button, default to 5 minutes if not set
+			timeout := 5 * time.Minute
+			if API_KEY_TIMEOUT := os.Getenv("DECONZ_API_KEY_TIMEOUT"); len(API_KEY_TIMEOUT) > 0 {
+				if timeout, err = time.ParseDuration(API_KEY_TIMEOUT); err != nil {
+					l.Fatalf("Invalid DECONZ_API_KEY_TIMEOUT: %v", err)
+				}
+			}
+
+			// Request a new API key from the deCONZ gateway
+			apiKeyRaw, err = getApiKey(ctx, l, fmt.Sprintf("http://%s:%s", PHOSCON_IP, PHOSCON_PORT), timeout)
+			if err != nil {
+				l.Fatalf("Could not obtain API key: %v", err)
+			}
+
+			// Save the new API key to the storage for future use
+			if err = storage.Set("deconz_api_key", apiKeyRaw); err != nil {
+				l.Fatalf("Could not store API key: %v", err)
+			}
 		}
+	}
 
-		// Save the new API key to the storage for future use
-		if err = storage.Set("deconz_api_key", apiKeyRaw); err != nil {
-			l.Fatalf("Could not store API key: %v", err)
+	// Collect Prometheus metrics if enabled, they are exposed by the status server
+	if os.Getenv("METRICS") == "true" {
+		if len(os.Getenv("STATUS_PORT")) == 0 {
+			l.Warn("METRICS is enabled, but the metrics are only exposed if STATUS_PORT is set")
 		}
+		metrics.Enable()
 	}
 
 	// Connect to the deCONZ API and retrieve gateway configuration
 	l.Info("Connecting to deCONZ gateway...")
-	api := deconz.NewApiClient(fmt.Sprintf("http://%s:%s", PHOSCON_IP, PHOSCON_PORT), string(apiKeyRaw))
-	config, err := api.GetConfiguration()
+	api := deconz.NewApiClient(ctx, fmt.Sprintf("http://%s:%s", PHOSCON_IP, PHOSCON_PORT), string(apiKeyRaw))
+	api.SetLogger(l.WithPrefix("API"))
+	if RATE_LIMIT := os.Getenv("DECONZ_RATE_LIMIT"); len(RATE_LIMIT) > 0 {
+		rateLimit, err := strconv.ParseFloat(RATE_LIMIT, 64)
+		if err != nil {
+			l.Fatalf("Invalid DECONZ_RATE_LIMIT: %v", err)
+		}
+		api.SetRateLimit(rateLimit)
+	}
+	if MAX_REQUESTS := os.Getenv("DECONZ_MAX_REQUESTS"); len(MAX_REQUESTS) > 0 {
+		maxRequests, err := strconv.Atoi(MAX_REQUESTS)
+		if err != nil || maxRequests < 1 {
+			l.Fatalf("Invalid DECONZ_MAX_REQUESTS: %s must be a positive number", MAX_REQUESTS)
+		}
+		api.SetConcurrencyLimit(maxRequests)
+	}
+
+	// The gateway may still be starting (e.g. if both are started together), so the
+	// initial requests are retried until the startup timeout expires
+	startupTimeout := defaultStartupTimeout
+	if STARTUP_TIMEOUT := os.Getenv("DECONZ_STARTUP_TIMEOUT"); len(STARTUP_TIMEOUT) > 0 {
+		if startupTimeout, err = time.ParseDuration(STARTUP_TIMEOUT); err != nil {
+			l.Fatalf("Invalid DECONZ_STARTUP_TIMEOUT: %v", err)
+		}
+	}
+
+	// Retrieve the configuration and the details of all lights and groups with a single request,
+	// so that the capabilities of the lights don't have to be requested for each light individually
+	gatewayState, err := retryStartup(ctx, l, "gateway state", startupTimeout, api.GetFullState)
 	if err != nil {
-		l.Fatalf("Error getting configuration: %v", err)
+		l.Fatalf("Error getting gateway state: %v", err)
 	}
+	config := &gatewayState.Config
 
 	// Retrieve all devices from the deCONZ gateway
 	l.Info("Retrieving devices from deCONZ gateway...")
-	devices, err := api.GetAllDevices()
+	devices, err := retryStartup(ctx, l, "devices", startupTimeout, api.GetAllDevices)
 	if err != nil {
 		l.Fatalf("Failed to get all devices: %+v", err)
 	}
 
 	// Create HomeKit accessories for each supported device
 	l.Info("Creating HomeKit accessories...")
-	am := accessoryManager.NewAccessoryManager(api, devices)
+	lowBatteryThreshold := accessoryManager.DefaultLowBatteryThreshold
+	if LOW_BATTERY_THRESHOLD := os.Getenv("LOW_BATTERY_THRESHOLD"); len(LOW_BATTERY_THRESHOLD) > 0 {
+		if lowBatteryThreshold, err = strconv.Atoi(LOW_BATTERY_THRESHOLD); err != nil {
+			l.Fatalf("Invalid LOW_BATTERY_THRESHOLD: %v", err)
+		}
+	}
+	var presenceCooldown time.Duration
+	if PRESENCE_COOLDOWN := os.Getenv("PRESENCE_COOLDOWN"); len(PRESENCE_COOLDOWN) > 0 {
+		if presenceCooldown, err = time.ParseDuration(PRESENCE_COOLDOWN); err != nil {
+			l.Fatalf("Invalid PRESENCE_COOLDOWN: %v", err)
+		}
+	}
+	vibrationTimeout := accessoryManager.DefaultVibrationTimeout
+	if VIBRATION_TIMEOUT := os.Getenv("VIBRATION_TIMEOUT"); len(VIBRATION_TIMEOUT) > 0 {
+		if vibrationTimeout, err = time.ParseDuration(VIBRATION_TIMEOUT); err != nil {
+			l.Fatalf("Invalid VIBRATION_TIMEOUT: %v", err)
+		}
+	}
+	temperatureDisplayUnits := characteristic.TemperatureDisplayUnitsCelsius
+	switch TEMPERATURE_UNIT := os.Getenv("TEMPERATURE_UNIT"); TEMPERATURE_UNIT {
+	case "", "C":
+	case "F":
+		temperatureDisplayUnits = characteristic.TemperatureDisplayUnitsFahrenheit
+	default:
+		l.Fatalf("Invalid TEMPERATURE_UNIT: %s", TEMPERATURE_UNIT)
+	}
+	feedbackWindow := accessoryManager.DefaultFeedbackWindow
+	if FEEDBACK_WINDOW := os.Getenv("FEEDBACK_WINDOW"); len(FEEDBACK_WINDOW) > 0 {
+		feedbackWindow, err = time.ParseDuration(FEEDBACK_WINDOW)
+		if err != nil {
+			l.Fatalf("Invalid FEEDBACK_WINDOW: %v", err)
+		}
+	}
+	var staleThreshold time.Duration
+	if SENSOR_STALE_THRESHOLD := os.Getenv("SENSOR_STALE_THRESHOLD"); len(SENSOR_STALE_THRESHOLD) > 0 {
+		staleThreshold, err = time.ParseDuration(SENSOR_STALE_THRESHOLD)
+		if err != nil {
+			l.Fatalf("Invalid SENSOR_STALE_THRESHOLD: %v", err)
+		}
+	}
+	var overrides map[string]deviceConfiguration.Override
+	if DEVICE_OVERRIDES := os.Getenv("DEVICE_OVERRIDES"); len(DEVICE_OVERRIDES) > 0 {
+		overrides, err = deviceConfiguration.LoadOverrides(DEVICE_OVERRIDES)
+		if err != nil {
+			l.Fatalf("Invalid DEVICE_OVERRIDES: %v", err)
+		}
+	}
+	am := accessoryManager.NewAccessoryManager(api, devices, accessoryManager.Options{
+		EveCharacteristics:      os.Getenv("EVE_CHARACTERISTICS") == "true",
+		LowBatteryThreshold:     lowBatteryThreshold,
+		PresenceCooldown:        presenceCooldown,
+		VibrationTimeout:        vibrationTimeout,
+		TemperatureDisplayUnits: temperatureDisplayUnits,
+		Include:                 splitList(os.Getenv("DECONZ_INCLUDE")),
+		Exclude:                 splitList(os.Getenv("DECONZ_EXCLUDE")),
+		Overrides:               overrides,
+		ColorLoopSwitch:         os.Getenv("COLORLOOP_SWITCH") == "true",
+		FeedbackWindow:          feedbackWindow,
+		StaleThreshold:          staleThreshold,
+	})
+
+	// Report all subdevices which can't be exposed to HomeKit in a single summary
+	if unsupported := am.UnsupportedDevices(); len(unsupported) > 0 {
+		pairs := []string{}
+		for _, device := range unsupported {
+			pair := fmt.Sprintf("%s (%s)", device.Model, device.Type)
+			if !slices.Contains(pairs, pair) {
+				pairs = append(pairs, pair)
+				// The reported values help to add support for the type
+				l.Infof("%s reports the state values: %s", pair, strings.Join(device.StateFields, ", "))
+			}
+		}
+		l.Warnf("%d unsupported subdevices skipped: %s", len(unsupported), strings.Join(pairs, ", "))
+	}
+
+	// Expose deCONZ groups as HomeKit accessories if enabled
+	// This is opt-in, because the lights of a group are usually exposed individually as well
+	if os.Getenv("EXPOSE_GROUPS") == "true" {
+		am.AddGroups(api, gatewayState.Groups)
+	}
+
+	// Only print the accessories which would be exposed in dry-run mode
+	if os.Getenv("DRY_RUN") == "true" {
+		am.WritePlan(os.Stdout)
+		return
+	}
+
+	// Apply the powerup settings of the overrides in the background, so that the start isn't delayed
+	go am.ApplyPowerUp()
+
+	// Publish the state changes to an MQTT broker if configured
+	var publisher *mqtt.Publisher
+	if MQTT_BROKER := os.Getenv("MQTT_BROKER"); len(MQTT_BROKER) > 0 {
+		qos := 0
+		if MQTT_QOS := os.Getenv("MQTT_QOS"); len(MQTT_QOS) > 0 {
+			if qos, err = strconv.Atoi(MQTT_QOS); err != nil || qos < 0 || qos > 2 {
+				l.Fatalf("Invalid MQTT_QOS: %s must be 0, 1 or 2", MQTT_QOS)
+			}
+		}
+		l.Infof("Connecting to MQTT broker %s...", MQTT_BROKER)
+		publisher, err = mqtt.NewPublisher(mqtt.Options{
+			Broker:      MQTT_BROKER,
+			Username:    os.Getenv("MQTT_USERNAME"),
+			Password:    os.Getenv("MQTT_PASSWORD"),
+			TopicPrefix: os.Getenv("MQTT_TOPIC_PREFIX"),
+			QoS:         byte(qos),
+		}, l.WithPrefix("MQTT"))
+		if err != nil {
+			l.Fatalf("MQTT error: %+v", err)
+		}
+		am.Subscribe(publisher.ObserveUpdate)
+	}
 
 	// Connect to the deCONZ WebSocket event stream for real-time updates
 	l.Info("Connecting to deCONZ event stream...")
-	_, err = deconz.NewEventClient(ctx, fmt.Sprintf("ws://%s:%d", PHOSCON_IP, config.WebsocketPort), am.ProcessUpdate)
+	eventBufferSize := deconz.DefaultEventBufferSize
+	if EVENT_BUFFER_SIZE := os.Getenv("EVENT_BUFFER_SIZE"); len(EVENT_BUFFER_SIZE) > 0 {
+		eventBufferSize, err = strconv.Atoi(EVENT_BUFFER_SIZE)
+		if err != nil || eventBufferSize < 1 {
+			l.Fatalf("Invalid EVENT_BUFFER_SIZE: %s must be a positive number", EVENT_BUFFER_SIZE)
+		}
+	}
+	wsPort, reason, err := websocketPort(os.Getenv("DECONZ_WS_PORT"), config.WebsocketPort)
+	if err != nil {
+		l.Fatalf("Invalid DECONZ_WS_PORT: %v", err)
+	}
+	l.Infof("Using WebSocket port %d (%s)", wsPort, reason)
+	wsInsecure := os.Getenv("DECONZ_WS_INSECURE") == "true"
+	wsScheme, wsDialer, err := websocketDialer(os.Getenv("DECONZ_WS_TLS") == "true", os.Getenv("DECONZ_WS_CA"), wsInsecure)
+	if err != nil {
+		l.Fatalf("Invalid DECONZ_WS_CA: %v", err)
+	}
+	if wsInsecure {
+		l.Warn("DECONZ_WS_INSECURE is set, the certificate of the event stream is not verified")
+	}
+	eventClient, err := deconz.NewEventClient(ctx, fmt.Sprintf("%s://%s:%d", wsScheme, PHOSCON_IP, wsPort), wsDialer, am.ProcessUpdate, eventBufferSize, l.WithPrefix("Events"))
 	if err != nil {
 		l.Fatalf("WebSocket connection error: %+v", err)
 	}
 
+	// Reconnect the event stream if it stalls and exit if it can't be restored,
+	// so that a supervisor (e.g. Docker) restarts the bridge
+	watchdogTimeout := deconz.DefaultWatchdogTimeout
+	if WATCHDOG_TIMEOUT := os.Getenv("EVENT_WATCHDOG_TIMEOUT"); len(WATCHDOG_TIMEOUT) > 0 {
+		if watchdogTimeout, err = time.ParseDuration(WATCHDOG_TIMEOUT); err != nil {
+			l.Fatalf("Invalid EVENT_WATCHDOG_TIMEOUT: %v", err)
+		}
+	}
+	watchdogRetries := deconz.DefaultWatchdogRetries
+	if WATCHDOG_RETRIES := os.Getenv("EVENT_WATCHDOG_RETRIES"); len(WATCHDOG_RETRIES) > 0 {
+		watchdogRetries, err = strconv.Atoi(WATCHDOG_RETRIES)
+		if err != nil || watchdogRetries < 0 {
+			l.Fatalf("Invalid EVENT_WATCHDOG_RETRIES: %s must be a number", WATCHDOG_RETRIES)
+		}
+	}
+	if watchdogTimeout > 0 {
+		go func() {
+			if err := eventClient.Watch(ctx, watchdogTimeout, watchdogRetries); err != nil {
+				l.Fatalf("WebSocket watchdog: %v", err)
+			}
+		}()
+	}
+
+	// Poll the device states as a backstop for dropped WebSocket events if enabled
+	if POLL_INTERVAL := os.Getenv("POLL_INTERVAL"); len(POLL_INTERVAL) > 0 {
+		pollInterval, err := time.ParseDuration(POLL_INTERVAL)
+		if err != nil {
+			l.Fatalf("Invalid POLL_INTERVAL: %v", err)
+		}
+		if pollInterval > 0 {
+			go am.Poll(ctx, pollInterval, l.WithPrefix("Poll"))
+		}
+	}
+
+	// Report devices which were paired after the start if enabled
+	if DISCOVERY_INTERVAL := os.Getenv("DISCOVERY_INTERVAL"); len(DISCOVERY_INTERVAL) > 0 {
+		discoveryInterval, err := time.ParseDuration(DISCOVERY_INTERVAL)
+		if err != nil {
+			l.Fatalf("Invalid DISCOVERY_INTERVAL: %v", err)
+		}
+		if discoveryInterval > 0 {
+			go am.Discover(ctx, discoveryInterval, l.WithPrefix("Discovery"))
+		}
+	}
+
+	// Report sensors which stopped reporting as faulted
+	if staleThreshold > 0 {
+		go am.MonitorSensorStatus(ctx)
+	}
+
+	// Start the status server if a port is configured
+	if STATUS_PORT := os.Getenv("STATUS_PORT"); len(STATUS_PORT) > 0 {
+		l.Infof("Starting status server on port %s...", STATUS_PORT)
+		statusServer := status.NewServer(":"+STATUS_PORT, am, api, eventClient)
+		go func() {
+			if err := statusServer.ListenAndServe(ctx); err != nil {
+				l.Errorf("Status server error: %+v", err)
+			}
+		}()
+	}
+
 	// Initialize and start the HomeKit server
 	l.Info("Starting HomeKit server...")
 
 	// Create a bridge accessory to represent the deCONZ gateway in HomeKit
-	bridgeName := fmt.Sprintf("%s %s Bridge", config.Name, config.BridgeId[:4])
+	// The name is shown while pairing, so it can be replaced like the other information
+	bridgeName := fmt.Sprintf("%s %s Bridge", config.Name, bridgeIdPrefix(config.BridgeId))
+	if BRIDGE_NAME, ok := os.LookupEnv("BRIDGE_NAME"); ok {
+		if bridgeName = strings.TrimSpace(BRIDGE_NAME); len(bridgeName) == 0 {
+			l.Fatal("Invalid BRIDGE_NAME: the name must not be empty")
+		}
+	}
 	b := accessory.NewBridge(accessory.Info{
-		Manufacturer: "0x2321",
-		Name:         bridgeName,
-		SerialNumber: config.BridgeId,
-		Model:        config.DeviceName,
+		Manufacturer: homeKitString(cmp.Or(os.Getenv("BRIDGE_MANUFACTURER"), "0x2321")),
+		Name:         homeKitString(bridgeName),
+		SerialNumber: homeKitString(cmp.Or(os.Getenv("BRIDGE_SERIAL"), config.BridgeId, defaultBridgeIdPrefix)),
+		Model:        homeKitString(cmp.Or(os.Getenv("BRIDGE_MODEL"), config.DeviceName)),
 		Firmware:     config.SwVersion,
 	})
 
+	// HomeKit refuses to pair bridges with too many accessories, so only a part of them is exposed
+	if count := am.AccessoryCount(); count > accessoryManager.MaxAccessories {
+		l.Errorf("%d accessories exceed the HomeKit limit of %d accessories per bridge, %d accessories are not exposed. "+
+			"Use DECONZ_INCLUDE or DECONZ_EXCLUDE to select the devices to expose",
+			count, accessoryManager.MaxAccessories, count-accessoryManager.MaxAccessories)
+	}
+
 	// Create a new HomeKit server with the bridge and all device accessories
 	server, err := hap.NewServer(storage, b.A, am.GetAccessories()...)
 	if err != nil {
@@ -132,31 +481,79 @@ func main() {
 	// set port
 	server.Addr = ":51826"
 
-	// Generate a random 8-digit pairing code for HomeKit setup
+	// Set the setup id, which is announced via mDNS and links the QR code to the bridge
+	if server.SetupId, err = getSetupId(storage); err != nil {
+		l.Fatalf("Could not obtain the HomeKit setup id: %v", err)
+	}
+
+	// Use the configured pairing code or generate a random 8-digit code for HomeKit setup
 	if !server.IsPaired() {
-		code := uint32(rand.Intn(90000000) + 10000000)
-		server.Pin = fmt.Sprintf("%d", code)
+		if PIN := strings.ReplaceAll(os.Getenv("HOMEKIT_PIN"), "-", ""); len(PIN) > 0 {
+			if _, err := strconv.ParseUint(PIN, 10, 32); err != nil || len(PIN) != 8 || hap.InvalidPins[PIN] {
+				l.Fatalf("Invalid HOMEKIT_PIN: %s must consist of 8 digits and must not be trivial", PIN)
+			}
+			server.Pin = PIN
+		} else {
+			code := uint32(rand.Intn(90000000) + 10000000)
+			server.Pin = fmt.Sprintf("%d", code)
+		}
 		l.Infof("HomeKit pairing code: %s-%s", server.Pin[0:4], server.Pin[4:8])
+
+		// Print the setup payload as QR code, which can be scanned in the Home app
+		uri, err := setupURI(server.Pin, server.SetupId, accessory.TypeBridge)
+		if err != nil {
+			l.Fatalf("Could not create the HomeKit setup payload: %v", err)
+		}
+		l.Infof("HomeKit setup payload: %s", uri)
+		qr, err := setupQRCode(uri)
+		if err != nil {
+			l.Fatalf("Could not create the HomeKit QR code: %v", err)
+		}
+		_, _ = fmt.Fprint(os.Stderr, qr.ToSmallString(false))
+
+		// Write the QR code as image if enabled
+		if os.Getenv("PAIRING_QR_PNG") == "true" {
+			if err := qr.WriteFile(256, STORAGE_PATH+"pairing.png"); err != nil {
+				l.Warnf("Could not write the HomeKit QR code: %v", err)
+			} else {
+				l.Infof("HomeKit QR code written to %spairing.png", STORAGE_PATH)
+			}
+		}
 	}
 
-	// Start the HomeKit server and listen for connections
-	if err = server.ListenAndServe(ctx); err != nil {
+	// Start the HomeKit server and listen for connections until the context is cancelled
+	if err = server.ListenAndServe(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		l.Fatalf("HomeKit server error: %+v", err)
 	}
+
+	// Stop receiving events from the deCONZ gateway
+	l.Info("Stopping bridge...")
+	if err = eventClient.Stop(); err != nil {
+		l.Warnf("Error closing the event stream: %+v", err)
+	}
+	if publisher != nil {
+		publisher.Close()
+	}
 }
 
 // getApiKey requests and retrieves an API key from the deCONZ gateway.
 // It repeatedly attempts to obtain the key until successful, prompting the user
-// to press the link button on the gateway when necessary.
+// to press the link button on the gateway when necessary. It gives up when the
+// timeout expires or the context is cancelled.
 //
 // Parameters:
+//   - ctx: Context for cancelling the process
 //   - log: Logger for output messages
 //   - addr: The base URL of the deCONZ gateway
+//   - timeout: The maximum duration to wait for the link button to be pressed
 //
 // Returns:
 //   - []byte: The API key as a byte slice
 //   - error: Any error encountered during the process
-func getApiKey(log *log.Logger, addr string) ([]byte, error) {
+func getApiKey(ctx context.Context, log *log.Logger, addr string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	// Define request and response types for the API key request
 	type Request struct {
 		DeviceName string `json:"devicetype"`
@@ -166,7 +563,7 @@ func getApiKey(log *log.Logger, addr string) ([]byte, error) {
 	// Loop until an API key is successfully obtained
 	for {
 		// Send a POST request to the deCONZ API to request an API key
-		data, err := client.Post[Response](addr+"/api", Request{DeviceName: "HomeKit Bridge"})
+		data, err := client.Post[Response](ctx, addr+"/api", Request{DeviceName: "HomeKit Bridge"})
 		if err != nil {
 			// Return any HTTP or network errors
 			return nil, err
@@ -183,8 +580,110 @@ func getApiKey(log *log.Logger, addr string) ([]byte, error) {
 		// If no key was provided (likely because the link button wasn't pressed),
 		// wait and try again
 		log.Warn("Please press the link button on your deCONZ gateway to obtain an API key. Retrying in 15s...")
-		time.Sleep(15 * time.Second)
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("the link button was not pressed within %s", timeout)
+			}
+			return nil, ctx.Err()
+		case <-time.After(15 * time.Second):
+		}
+	}
+}
+
+// defaultStartupTimeout is the default time the initial requests to the gateway are retried.
+const defaultStartupTimeout = 2 * time.Minute
+
+// maxStartupRetryDelay is the maximum delay between two attempts of an initial request.
+const maxStartupRetryDelay = 30 * time.Second
+
+// retryStartup performs an initial request to the deCONZ gateway and retries it with an
+// increasing delay until it succeeds, so that a gateway which is still starting doesn't
+// cause the bridge to exit. It gives up when the timeout expires or the context is cancelled.
+//
+// Parameters:
+//   - ctx: Context for cancelling the retries
+//   - log: Logger for the progress messages
+//   - what: A description of the requested data (for logging)
+//   - timeout: The maximum duration to retry the request
+//   - fetch: The function performing the request
+//
+// Returns:
+//   - T: The result of the first successful request
+//   - error: The error of the last attempt if the request didn't succeed in time
+func retryStartup[T any](ctx context.Context, log *log.Logger, what string, timeout time.Duration, fetch func() (T, error)) (T, error) {
+	deadline := time.Now().Add(timeout)
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		result, err := fetch()
+		if err == nil || time.Now().Add(delay).After(deadline) {
+			return result, err
+		}
+
+		log.Warnf("Failed to get the %s (attempt %d), the gateway may still be starting. Retrying in %s: %v", what, attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxStartupRetryDelay)
+	}
+}
+
+// defaultWebsocketPort is the default WebSocket port of deCONZ, which is used if the gateway doesn't report a valid port.
+const defaultWebsocketPort = 443
+
+// websocketPort determines the port of the deCONZ event stream.
+// The port reported by the gateway is used unless it is overridden, e.g. because the gateway runs
+// behind a reverse proxy. Some setups report 0 or an invalid port, in which case the default is used.
+//
+// Parameters:
+//   - override: The port set in DECONZ_WS_PORT (empty to use the reported port)
+//   - reported: The port reported by the gateway configuration
+//
+// Returns:
+//   - int: The port to connect to
+//   - string: The reason why the port is used (for logging)
+//   - error: An error if the override is not a valid port
+func websocketPort(override string, reported int) (int, string, error) {
+	if len(override) > 0 {
+		port, err := strconv.Atoi(override)
+		if err != nil || port < 1 || port > 65535 {
+			return 0, "", fmt.Errorf("%s must be a port between 1 and 65535", override)
+		}
+		return port, "set by DECONZ_WS_PORT", nil
+	}
+
+	if reported < 1 || reported > 65535 {
+		return defaultWebsocketPort, fmt.Sprintf("the gateway reported the invalid port %d, using the default", reported), nil
 	}
+	return reported, "reported by the gateway", nil
+}
+
+// websocketDialer determines the scheme and the dialer of the deCONZ event stream.
+// The event stream uses plain ws:// with the default dialer, unless TLS is enabled, e.g. because
+// the gateway runs behind a reverse proxy with a self-signed certificate. Setting a CA file or
+// disabling the verification enables TLS as well.
+//
+// Parameters:
+//   - enabled: Whether DECONZ_WS_TLS is set
+//   - caFile: The CA file set in DECONZ_WS_CA (empty to use the system roots)
+//   - insecure: Whether DECONZ_WS_INSECURE is set
+//
+// Returns:
+//   - string: The scheme of the WebSocket URL, ws or wss
+//   - *websocket.Dialer: The dialer for the event client (nil for the default dialer)
+//   - error: An error if the CA file could not be used
+func websocketDialer(enabled bool, caFile string, insecure bool) (string, *websocket.Dialer, error) {
+	if !enabled && len(caFile) == 0 && !insecure {
+		return "ws", nil, nil
+	}
+
+	dialer, err := deconz.NewTLSDialer(caFile, insecure)
+	if err != nil {
+		return "", nil, err
+	}
+	return "wss", dialer, nil
 }
 
 // DefaultContext creates a context that can be cancelled when the application
@@ -193,24 +692,70 @@ func getApiKey(log *log.Logger, addr string) ([]byte, error) {
 // Returns:
 //   - context.Context: A cancellable context tied to system signals
 func DefaultContext() context.Context {
-	// Create a channel to receive OS signals
-	c := make(chan os.Signal)
-
-	// Register for interrupt (Ctrl+C) and termination signals
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, syscall.SIGTERM)
-
-	// Create a cancellable context
-	ctx, cancel := context.WithCancel(context.Background())
+	// Create a context that is cancelled on interrupt (Ctrl+C) and termination signals
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
-	// Start a goroutine that will cancel the context when a signal is received
+	// Start a goroutine that stops the signal delivery after the first signal
 	go func() {
-		<-c
-		// Stop delivering signals to prevent multiple cancellations
-		signal.Stop(c)
-		// Cancel the context to initiate graceful shutdown
-		cancel()
+		<-ctx.Done()
+		// Restore the default behavior, so that a second signal terminates immediately
+		stop()
 	}()
 
 	return ctx
 }
+
+// defaultBridgeIdPrefix is used in the bridge name if the gateway reports no bridge ID,
+// e.g. some virtual gateways or gateways behind proxies.
+const defaultBridgeIdPrefix = "0000"
+
+// bridgeIdPrefix returns the beginning of the bridge ID, which is added to the name of the bridge.
+// Bridge IDs shorter than four characters are used completely.
+//
+// Parameters:
+//   - id: The bridge ID reported by the gateway
+//
+// Returns:
+//   - string: The first four characters of the ID, or defaultBridgeIdPrefix if the ID is empty
+func bridgeIdPrefix(id string) string {
+	if len(id) == 0 {
+		return defaultBridgeIdPrefix
+	}
+	return id[:min(len(id), 4)]
+}
+
+// maxHomeKitStringLength is the maximum length of string characteristics (e.g. the name) in HomeKit.
+const maxHomeKitStringLength = 64
+
+// homeKitString trims a value for a string characteristic to the maximum length of HomeKit.
+// The value is trimmed by characters, so that multibyte characters aren't split.
+//
+// Parameters:
+//   - value: The value of the characteristic
+//
+// Returns:
+//   - string: The value with at most 64 characters
+func homeKitString(value string) string {
+	if runes := []rune(value); len(runes) > maxHomeKitStringLength {
+		return strings.TrimSpace(string(runes[:maxHomeKitStringLength]))
+	}
+	return value
+}
+
+// splitList splits a comma-separated list from an environment variable.
+// Empty entries and surrounding whitespace are removed.
+//
+// Parameters:
+//   - value: The comma-separated list
+//
+// Returns:
+//   - []string: The entries of the list
+func splitList(value string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
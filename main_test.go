@@ -0,0 +1,76 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDefaultContextCancelledBySignal(t *testing.T) {
+	ctx := DefaultContext()
+
+	// The signal is delivered to the context, so it doesn't terminate the test
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the context was not cancelled by SIGTERM")
+	}
+}
+
+func TestBridgeIdPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"empty", "", defaultBridgeIdPrefix},
+		{"one character", "0", "0"},
+		{"three characters", "00A", "00A"},
+		{"four characters", "00A1", "00A1"},
+		{"bridge ID", "00212EFFFF0A1B2C", "0021"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bridgeIdPrefix(tt.id); got != tt.want {
+				t.Errorf("bridgeIdPrefix(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebsocketDialer(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		caFile     string
+		insecure   bool
+		wantScheme string
+		wantDialer bool
+		wantErr    bool
+	}{
+		{"plain", false, "", false, "ws", false, false},
+		{"tls", true, "", false, "wss", true, false},
+		{"insecure", false, "", true, "wss", true, false},
+		{"missing CA file", false, "missing.pem", false, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme, dialer, err := websocketDialer(tt.enabled, tt.caFile, tt.insecure)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("websocketDialer() error = %v, want an error: %t", err, tt.wantErr)
+			}
+			if scheme != tt.wantScheme || (dialer != nil) != tt.wantDialer {
+				t.Errorf("websocketDialer() = %q, %v, want %q with a dialer: %t", scheme, dialer, tt.wantScheme, tt.wantDialer)
+			}
+			if tt.insecure && !dialer.TLSClientConfig.InsecureSkipVerify {
+				t.Error("the certificate is verified, want no verification")
+			}
+		})
+	}
+}
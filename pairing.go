@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/rand"
+	"deconz-homekit/internal/kvStorage"
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"strconv"
+	"strings"
+)
+
+// setupIdAlphabet contains the characters allowed in a HomeKit setup id
+const setupIdAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// setupFlagIP indicates that the accessory supports pairing via IP
+const setupFlagIP = 1 << 28
+
+// getSetupId returns the HomeKit setup id of the bridge.
+// The setup id is part of the setup payload and announced via mDNS, so that
+// controllers can find the bridge after scanning the QR code. It is generated
+// once and kept in the storage, so that printed codes stay valid across restarts.
+// A new setup id is only generated if the stored one is missing or malformed.
+//
+// Parameters:
+//   - storage: The key-value storage to keep the setup id in
+//
+// Returns:
+//   - string: The 4-character setup id
+//   - error: Any error encountered while reading or storing the setup id
+func getSetupId(storage kvStorage.Store) (string, error) {
+	raw, err := storage.Get("homekit_setup_id")
+	if err != nil && !errors.Is(err, kvStorage.ErrNotFound) {
+		return "", err
+	}
+	if err == nil && isValidSetupId(string(raw)) {
+		return string(raw), nil
+	}
+
+	// Generate a new random setup id
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = setupIdAlphabet[int(b[i])%len(setupIdAlphabet)]
+	}
+
+	return string(b), storage.Set("homekit_setup_id", b)
+}
+
+// isValidSetupId checks whether a setup id consists of 4 digits or uppercase letters.
+//
+// Parameters:
+//   - setupId: The setup id to check
+//
+// Returns:
+//   - bool: True if the setup id is valid
+func isValidSetupId(setupId string) bool {
+	if len(setupId) != 4 {
+		return false
+	}
+	for _, c := range setupId {
+		if !strings.ContainsRune(setupIdAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// setupURI builds the HomeKit setup payload (X-HM:// URI) which is encoded in the
+// QR code of HomeKit accessories. The payload contains the accessory category,
+// the pairing code and the setup id in base 36.
+//
+// Parameters:
+//   - pin: The 8-digit pairing code
+//   - setupId: The 4-character setup id
+//   - category: The accessory category (e.g. accessory.TypeBridge)
+//
+// Returns:
+//   - string: The setup URI
+//   - error: An error if the pairing code is invalid
+func setupURI(pin string, setupId string, category byte) (string, error) {
+	code, err := strconv.ParseUint(pin, 10, 27)
+	if err != nil || len(pin) != 8 {
+		return "", fmt.Errorf("invalid pairing code %s", pin)
+	}
+
+	// The payload consists of the category (bits 31-38), the flags (bits 27-30) and the pairing code (bits 0-26)
+	payload := uint64(category)<<31 | setupFlagIP | code
+	encoded := strings.ToUpper(strconv.FormatUint(payload, 36))
+	return "X-HM://" + strings.Repeat("0", max(9-len(encoded), 0)) + encoded + setupId, nil
+}
+
+// setupQRCode creates the QR code of a setup URI.
+//
+// Parameters:
+//   - uri: The setup URI
+//
+// Returns:
+//   - *qrcode.QRCode: The QR code which can be printed or written as PNG
+//   - error: Any error encountered while encoding the QR code
+func setupQRCode(uri string) (*qrcode.QRCode, error) {
+	return qrcode.New(uri, qrcode.Medium)
+}
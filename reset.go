@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"deconz-homekit/internal/kvStorage"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// homeKitKeys are the keys the HAP library stores: the identifier of the bridge, its
+// long-term key pair and the version and hash of the accessory configuration.
+var homeKitKeys = []string{"uuid", "keypair", "version", "configHash"}
+
+// homeKitKeySuffixes are the suffixes of the keys the HAP library stores for each
+// paired controller (".pairing") and for imported entities (".entity").
+var homeKitKeySuffixes = []string{".pairing", ".entity"}
+
+// resetHomeKit removes the HomeKit pairings and identity of the bridge from the storage,
+// so that it can be paired again as a new accessory. The deCONZ API key and the setup id
+// (which keeps printed QR codes valid) are kept. Unless force is set, the user has to confirm the reset.
+//
+// Parameters:
+//   - storage: The storage to remove the keys from
+//   - force: Whether the reset is done without confirmation
+//   - in: The reader the confirmation is read from
+//   - out: The writer the prompt is written to
+//
+// Returns:
+//   - []string: The removed keys, or nil if the reset was cancelled
+//   - error: Any error encountered while removing the keys
+func resetHomeKit(storage kvStorage.Store, force bool, in io.Reader, out io.Writer) ([]string, error) {
+	keys := []string{}
+	for _, key := range homeKitKeys {
+		if _, err := storage.Get(key); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	for _, suffix := range homeKitKeySuffixes {
+		found, err := storage.KeysWithSuffix(suffix)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	if !force {
+		_, _ = fmt.Fprintf(out, "This removes %d HomeKit keys (%s), all controllers have to pair the bridge again. Continue? [y/N] ",
+			len(keys), strings.Join(keys, ", "))
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			return nil, nil
+		}
+	}
+
+	for _, key := range keys {
+		if err := storage.Delete(key); err != nil {
+			return nil, fmt.Errorf("could not remove %s: %w", key, err)
+		}
+	}
+	return keys, nil
+}
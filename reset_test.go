@@ -0,0 +1,143 @@
+package main
+
+import (
+	"deconz-homekit/internal/kvStorage"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// resetStorage creates a storage with the keys of a paired bridge.
+func resetStorage(t *testing.T) *kvStorage.MemoryStorage {
+	t.Helper()
+
+	storage := kvStorage.NewMemory()
+	for key, value := range map[string]string{
+		"deconz_api_key":   "0123456789",
+		"homekit_setup_id": "AB12",
+		"uuid":             "AA:BB:CC:DD:EE:FF",
+		"keypair":          `{"public":"a","private":"b"}`,
+		"version":          "3",
+		"configHash":       "abc",
+		"ctrl-1.pairing":   `{"name":"ctrl-1"}`,
+		"ctrl-2.pairing":   `{"name":"ctrl-2"}`,
+		"light.entity":     `{"name":"light"}`,
+	} {
+		if err := storage.Set(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return storage
+}
+
+// homeKitResetKeys are the keys of resetStorage which are removed by a reset.
+var homeKitResetKeys = []string{"configHash", "ctrl-1.pairing", "ctrl-2.pairing", "keypair", "light.entity", "uuid", "version"}
+
+// assertKept fails the test if the deCONZ API key or the setup id were removed.
+func assertKept(t *testing.T, storage kvStorage.Store) {
+	t.Helper()
+
+	for key, want := range map[string]string{"deconz_api_key": "0123456789", "homekit_setup_id": "AB12"} {
+		if value, err := storage.Get(key); err != nil || string(value) != want {
+			t.Errorf("Get(%s) = %q, %v, want %q", key, value, err, want)
+		}
+	}
+}
+
+func TestResetHomeKitForce(t *testing.T) {
+	storage := resetStorage(t)
+	var out strings.Builder
+
+	keys, err := resetHomeKit(storage, true, strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatalf("resetHomeKit() error = %v", err)
+	}
+	if !slices.Equal(keys, homeKitResetKeys) {
+		t.Errorf("resetHomeKit() = %v, want %v", keys, homeKitResetKeys)
+	}
+	if out.Len() != 0 {
+		t.Errorf("resetHomeKit() with force prompted %q, want no prompt", out.String())
+	}
+
+	for _, key := range homeKitResetKeys {
+		if _, err := storage.Get(key); !errors.Is(err, kvStorage.ErrNotFound) {
+			t.Errorf("Get(%s) error = %v, want ErrNotFound", key, err)
+		}
+	}
+	assertKept(t, storage)
+}
+
+func TestResetHomeKitConfirm(t *testing.T) {
+	for _, answer := range []string{"y\n", "yes\n", " Y \n", "YES"} {
+		t.Run(strings.TrimSpace(answer), func(t *testing.T) {
+			storage := resetStorage(t)
+			var out strings.Builder
+
+			keys, err := resetHomeKit(storage, false, strings.NewReader(answer), &out)
+			if err != nil {
+				t.Fatalf("resetHomeKit() error = %v", err)
+			}
+			if !slices.Equal(keys, homeKitResetKeys) {
+				t.Errorf("resetHomeKit() = %v, want %v", keys, homeKitResetKeys)
+			}
+
+			// The prompt lists the keys which are removed
+			if prompt := out.String(); !strings.Contains(prompt, "7 HomeKit keys") || !strings.Contains(prompt, strings.Join(homeKitResetKeys, ", ")) {
+				t.Errorf("prompt = %q, want the removed keys", prompt)
+			}
+
+			if _, err := storage.Get("uuid"); !errors.Is(err, kvStorage.ErrNotFound) {
+				t.Errorf("Get(uuid) error = %v, want ErrNotFound", err)
+			}
+			assertKept(t, storage)
+		})
+	}
+}
+
+func TestResetHomeKitCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+	}{
+		{"no", "n\n"},
+		{"empty", "\n"},
+		{"end of input", ""},
+		{"other answer", "reset\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := resetStorage(t)
+			var out strings.Builder
+
+			keys, err := resetHomeKit(storage, false, strings.NewReader(tt.answer), &out)
+			if err != nil || keys != nil {
+				t.Fatalf("resetHomeKit() = %v, %v, want nil, nil", keys, err)
+			}
+			if !strings.HasSuffix(out.String(), "[y/N] ") {
+				t.Errorf("prompt = %q, want a confirmation prompt", out.String())
+			}
+
+			// Nothing is removed
+			for _, key := range homeKitResetKeys {
+				if _, err := storage.Get(key); err != nil {
+					t.Errorf("Get(%s) error = %v, want the key to be kept", key, err)
+				}
+			}
+			assertKept(t, storage)
+		})
+	}
+}
+
+func TestResetHomeKitUnpaired(t *testing.T) {
+	storage := kvStorage.NewMemory()
+	_ = storage.Set("deconz_api_key", []byte("0123456789"))
+	_ = storage.Set("homekit_setup_id", []byte("AB12"))
+
+	keys, err := resetHomeKit(storage, true, strings.NewReader(""), &strings.Builder{})
+	if err != nil || len(keys) != 0 {
+		t.Errorf("resetHomeKit() = %v, %v, want no keys", keys, err)
+	}
+	assertKept(t, storage)
+}